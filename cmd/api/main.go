@@ -2,11 +2,15 @@ package main
 
 import (
 	"context"
-	"log"
+	"errors"
+	"flag"
+	"log/slog"
+	"os"
 	"os/signal"
 	"syscall"
 
 	application "github.com/Stckrz/villageApi/internal/app"
+	"github.com/Stckrz/villageApi/internal/config"
 	"github.com/joho/godotenv"
     _ "github.com/Stckrz/villageApi/docs"
 
@@ -18,12 +22,38 @@ import (
 // @BasePath /api
 func main() {
     godotenv.Load(".env") // loads env vars
-	app, err := application.New()
-	if err != nil {log.Fatal(err)}
+	//the subcommand takes its own flags, so its config comes from the file and env only
+	if len(os.Args) > 1 && os.Args[1] == "import-tiled" {
+		cfg := loadConfig(config.LoadDB())
+		if err := application.ImportTiled(cfg.DB, os.Args[2:], os.Stdout, os.Stderr); err != nil {
+			fatal(err)
+		}
+		return
+	}
+	cfg := loadConfig(config.Load(os.Args[1:], os.Stderr))
+	app, err := application.New(cfg)
+	if err != nil {fatal(err)}
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
 	if err := app.Start(ctx); err != nil {
-		log.Println("server exited:", err)
+		slog.Error("server exited", "error", err)
+	}
+}
+
+// loadConfig exits on a config that failed to load, or sets up logging with it.
+func loadConfig(cfg config.Config, err error) config.Config {
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
 	}
+	if err != nil {
+		fatal(err)
+	}
+	application.SetupLogging(cfg)
+	return cfg
+}
+
+func fatal(err error) {
+	slog.Error(err.Error())
+	os.Exit(1)
 }
@@ -15,8 +15,66 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/blueprints": {
+            "get": {
+                "description": "Every blueprint in the catalogue, with its defaults, starter tasks and the cost, build time and production of each level.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "blueprints"
+                ],
+                "summary": "Get blueprints",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/catalog.BuildingType"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/blueprints/{id}": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "blueprints"
+                ],
+                "summary": "Get blueprint by id",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Blueprint ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/catalog.BuildingType"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
         "/buildings": {
             "get": {
+                "description": "Without include or fields every building comes with every field and relation. Once either is given, only the fields listed (all if fields is left out) and the relations included (none if include is left out) are read and sent, along with the id. Including summary adds the task counts without reading the tasks.",
                 "produces": [
                     "application/json"
                 ],
@@ -24,19 +82,62 @@ const docTemplate = `{
                     "buildings"
                 ],
                 "summary": "Get buildings",
+                "parameters": [
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Only buildings carrying every listed category, matched case-insensitively",
+                        "name": "category",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Viewport x1,y1,x2,y2; only placed buildings whose footprint intersects it",
+                        "name": "bbox",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated relations to include: categories, rates, tasks, summary",
+                        "name": "include",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated fields to send, e.g. name,thumbnailPath",
+                        "name": "fields",
+                        "in": "query"
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/models.Building"
+                                "$ref": "#/definitions/httpx.BuildingResponse"
                             }
                         }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
                     }
                 }
             },
             "post": {
+                "description": "Creates the building with its categories and any nested tasks in one transaction and returns it with everything attached. With a blueprintId, blank fields are filled from the blueprint and its default categories and starter tasks are added. If the blueprint has levels, the first level's cost is reserved from the village and the building stays under construction until its construction tasks are completed.",
                 "produces": [
                     "application/json"
                 ],
@@ -59,23 +160,86 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/httpx.BuildingResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Insufficient Stock or Overlapping Placement",
+                        "schema": {
+                            "type": "string"
                         }
                     },
                     "500": {
-                        "description": "Internal Service Error",
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/buildings/summary": {
+            "get": {
+                "description": "Counts each building's open, completed and overdue tasks and reports when one last changed, without reading the tasks. An open task is overdue once its estimate has run out since it was created.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "buildings"
+                ],
+                "summary": "Summarize buildings",
+                "parameters": [
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Only buildings carrying every listed category, matched case-insensitively",
+                        "name": "category",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Viewport x1,y1,x2,y2; only placed buildings whose footprint intersects it",
+                        "name": "bbox",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/httpx.BuildingSummaryResponse"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
                         "schema": {
                             "type": "string"
                         }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
                     }
                 }
             }
         },
         "/buildings/{id}": {
             "get": {
+                "description": "Without include or fields the building comes with every field and relation. Once either is given, only the fields listed (all if fields is left out) and the relations included (none if include is left out) are read and sent, along with the id. Including summary adds the task counts without reading the tasks.",
                 "produces": [
                     "application/json"
                 ],
@@ -90,18 +254,49 @@ const docTemplate = `{
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated relations to include: categories, rates, tasks, summary",
+                        "name": "include",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated fields to send, e.g. name,thumbnailPath",
+                        "name": "fields",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "Building"
+                            "$ref": "#/definitions/httpx.BuildingResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
                         }
                     }
                 }
             },
             "put": {
+                "description": "Replaces the building's fields and categories. villageId and rates are only changed when sent, so clients that predate them don't detach buildings or drop their rates.",
                 "produces": [
                     "application/json"
                 ],
@@ -137,11 +332,23 @@ const docTemplate = `{
                             }
                         }
                     },
-                    "500": {
-                        "description": "Internal Service Error",
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Overlapping Placement",
                         "schema": {
                             "type": "string"
                         }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
                     }
                 }
             },
@@ -173,31 +380,38 @@ const docTemplate = `{
                         }
                     },
                     "500": {
-                        "description": "Internal Service Error",
+                        "description": "Internal Server Error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/httpx.ErrorResponse"
                         }
                     }
                 }
             }
         },
-        "/tasks": {
+        "/buildings/{id}/clone": {
             "post": {
+                "description": "Creates a copy with the same village, description, images, categories and rates and a fresh copy of every open chore. Without x and y the copy is kept off the grid. A blueprint building with levels starts construction of level 1, reserving its cost.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "tasks"
+                    "buildings"
                 ],
-                "summary": "Create new task",
+                "summary": "Clone a building",
                 "parameters": [
                     {
-                        "description": "Create task payload",
+                        "type": "integer",
+                        "description": "Building ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Clone options",
                         "name": "request",
                         "in": "body",
-                        "required": true,
                         "schema": {
-                            "$ref": "#/definitions/httpx.CreateTaskRequest"
+                            "$ref": "#/definitions/httpx.CloneBuildingRequest"
                         }
                     }
                 ],
@@ -205,45 +419,61 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/httpx.BuildingResponse"
                         }
                     },
-                    "500": {
-                        "description": "Internal Service Error",
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Insufficient Stock or Overlapping Placement",
                         "schema": {
                             "type": "string"
                         }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
                     }
                 }
             }
         },
-        "/tasks/{id}": {
-            "put": {
+        "/buildings/{id}/merge": {
+            "post": {
+                "description": "Moves the source building's chores and categories to this building and deletes the source, with its rates and road segments. A source under construction is refused.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "tasks"
+                    "buildings"
                 ],
-                "summary": "Update a task",
+                "summary": "Merge another building into this one",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Task ID",
+                        "description": "Building ID to merge into",
                         "name": "id",
                         "in": "path",
                         "required": true
                     },
                     {
-                        "description": "Update task payload",
+                        "description": "Building to merge away",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/httpx.UpdateTaskRequest"
+                            "$ref": "#/definitions/httpx.MergeBuildingRequest"
                         }
                     }
                 ],
@@ -251,32 +481,50 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/httpx.BuildingResponse"
                         }
                     },
-                    "500": {
-                        "description": "Internal Service Error",
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Under Construction",
                         "schema": {
                             "type": "string"
                         }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
                     }
                 }
-            },
-            "delete": {
+            }
+        },
+        "/buildings/{id}/upgrade": {
+            "post": {
+                "description": "Reserves the next level's cost and spawns its construction tasks. The building is active at the new level once they are completed.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "tasks"
+                    "buildings"
                 ],
-                "summary": "Delete a task",
+                "summary": "Upgrade a building",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Task ID",
+                        "description": "Building ID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -286,64 +534,2458 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/httpx.BuildingResponse"
                         }
                     },
-                    "500": {
-                        "description": "Internal Service Error",
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
                         "schema": {
                             "type": "string"
                         }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "httpx.CreateBuildingRequest": {
-            "type": "object",
-            "properties": {
-                "categories": {
-                    "type": "array",
-                    "items": {
-                        "type": "string"
+        },
+        "/categories": {
+            "get": {
+                "description": "Every category with the number of buildings carrying it.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "categories"
+                ],
+                "summary": "Get categories",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/services.CategoryCount"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
                     }
-                },
-                "description": {
-                    "type": "string"
-                },
-                "imagePath": {
-                    "type": "string"
-                },
-                "name": {
-                    "type": "string"
-                },
-                "thumbnailPath": {
-                    "type": "string"
                 }
             }
         },
-        "httpx.CreateTaskRequest": {
+        "/categories/{id}": {
+            "put": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "categories"
+                ],
+                "summary": "Rename a category",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Category ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Rename category payload",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/httpx.RenameCategoryRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.CategoryResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Name Taken",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/categories/{id}/merge": {
+            "post": {
+                "description": "Moves every building from this category to the target category and deletes this one.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "categories"
+                ],
+                "summary": "Merge a category into another",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Category ID to merge away",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Merge target",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/httpx.MergeCategoryRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.CategoryResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/import/tiled": {
+            "post": {
+                "description": "The body is a Tiled map, JSON or TMX. Every named object of its object layers becomes a building of the village, matched by name; nothing is written unless commit is true, so the default response is a dry-run diff.",
+                "consumes": [
+                    "application/json",
+                    "text/xml"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "import"
+                ],
+                "summary": "Import buildings from a Tiled map",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Village to import into",
+                        "name": "villageId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Apply the import instead of only reporting it",
+                        "name": "commit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.ImportReport"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/paths": {
+            "get": {
+                "description": "Dijkstra over the road network, weighted by travel time.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "roads"
+                ],
+                "summary": "Shortest route between two buildings",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Start building ID",
+                        "name": "from",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Destination building ID",
+                        "name": "to",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.Path"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found or No Route",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/roads": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "roads"
+                ],
+                "summary": "Get road segments",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Only roads of this village",
+                        "name": "villageId",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/httpx.RoadResponse"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Connects two buildings of the road's village, or tiles. Without travelMinutes, the travel time is the straight distance between the ends at one minute per tile; 0 is a free road.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "roads"
+                ],
+                "summary": "Create a road segment",
+                "parameters": [
+                    {
+                        "description": "Create road payload",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/httpx.CreateRoadRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.RoadResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/roads/{id}": {
+            "delete": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "roads"
+                ],
+                "summary": "Delete a road segment",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Road ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/search": {
+            "get": {
+                "description": "Full-text search over building names, descriptions and categories and task names and descriptions. Every word must match, as a prefix. Hits are ranked and grouped by type; names and snippets are HTML escaped, with matched terms wrapped in \u003cmark\u003e\u003c/mark\u003e.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "search"
+                ],
+                "summary": "Search buildings and tasks",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Search text",
+                        "name": "q",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Only these types (building, task)",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Only results from this village",
+                        "name": "villageId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Hits per type, at most 100 (default 20)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.SearchResults"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Search Unavailable",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/stats": {
+            "get": {
+                "description": "Tasks created and completed per bucket, mean minutes from creating to completing a task per building and category, and streaks of buckets with a task completed. Days are UTC and weeks start on Monday; a week range starts on the Monday of from. Only tasks still stored are counted.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "stats"
+                ],
+                "summary": "Get village statistics",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "First day, YYYY-MM-DD (default 30 days before to)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Last day, YYYY-MM-DD (default today)",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "day or week (default day)",
+                        "name": "bucket",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Only tasks of this village's buildings",
+                        "name": "villageId",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.Stats"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/stats/history": {
+            "get": {
+                "description": "Daily snapshots of building counts, open and completed tasks and, per village, resource stock (metrics named resource.wood and so on), oldest first. Snapshots are taken in the background, so they outlive deleted rows; the current day holds the latest snapshot. Days without a snapshot are left out.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "stats"
+                ],
+                "summary": "Get daily metric history",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "First day, YYYY-MM-DD (default 30 days before to)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Last day, YYYY-MM-DD (default today)",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "One village's metrics instead of the whole database's",
+                        "name": "villageId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Only these metrics",
+                        "name": "metric",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/services.MetricSeries"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/tasks": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tasks"
+                ],
+                "summary": "Get tasks",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Only tasks of this building",
+                        "name": "buildingId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Only tasks of buildings in this village",
+                        "name": "villageId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only completed or only open tasks",
+                        "name": "completed",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only tasks of this kind (chore or construction)",
+                        "name": "kind",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/httpx.TaskResponse"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tasks"
+                ],
+                "summary": "Create new task",
+                "parameters": [
+                    {
+                        "description": "Create task payload",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/httpx.CreateTaskRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.TaskResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/tasks.csv": {
+            "get": {
+                "description": "Takes the same filters as the task list. Text cells starting with =, +, -, @, a tab, a carriage return or an apostrophe get a leading apostrophe, so spreadsheets show them as text; the import strips it again.",
+                "produces": [
+                    "text/csv"
+                ],
+                "tags": [
+                    "tasks"
+                ],
+                "summary": "Export tasks as CSV",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Only tasks of this building",
+                        "name": "buildingId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Only tasks of buildings in this village",
+                        "name": "villageId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only completed or only open tasks",
+                        "name": "completed",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only tasks of this kind (chore or construction)",
+                        "name": "kind",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "CSV with a header row",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/tasks/bulk": {
+            "post": {
+                "description": "Operations run in order in one transaction. Every operation is attempted and reported; if any fails, none of them are applied and the response is 422.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tasks"
+                ],
+                "summary": "Create, update, delete or complete many tasks at once",
+                "parameters": [
+                    {
+                        "description": "Bulk operations",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/httpx.BulkTasksRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.BulkTaskReport"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/services.BulkTaskReport"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/tasks/import": {
+            "post": {
+                "description": "Rows without an id create a task, rows with one update it. The building is given by building_id or by name in the building column. Every row is validated in the transaction that writes them; if any is invalid, nothing is imported and the errors are returned per row.",
+                "consumes": [
+                    "text/csv"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tasks"
+                ],
+                "summary": "Import tasks from CSV",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.TaskImportReport"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/services.TaskImportReport"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/tasks/{id}": {
+            "put": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tasks"
+                ],
+                "summary": "Update a task",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Task ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Update task payload",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/httpx.UpdateTaskRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Open Construction Task",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tasks"
+                ],
+                "summary": "Delete a task",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Task ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Open Construction Task",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/tasks/{id}/estimate": {
+            "get": {
+                "description": "Task time plus the walk from the servitor's home building to the task's building and back.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tasks"
+                ],
+                "summary": "Estimate a task for a servitor",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Task ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Servitor home building ID",
+                        "name": "home",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.TaskEstimate"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found or No Route",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/villages": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "villages"
+                ],
+                "summary": "Get villages",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/httpx.VillageResponse"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Starts a new simulation. The seed is random unless given.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "villages"
+                ],
+                "summary": "Create new village",
+                "parameters": [
+                    {
+                        "description": "Create village payload",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/httpx.CreateVillageRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.VillageResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/villages/import": {
+            "post": {
+                "description": "Recreates an exported snapshot as a new village with fresh ids. The body is either the JSON snapshot or the gzipped archive; images from an archive are written below the image root unless a file is already there. If the village is imported but its images can't be moved into place, the response carries a Warning header.",
+                "consumes": [
+                    "application/json",
+                    "application/gzip"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "villages"
+                ],
+                "summary": "Import a village",
+                "parameters": [
+                    {
+                        "description": "Village snapshot",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/services.Snapshot"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.VillageResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/villages/{id}": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "villages"
+                ],
+                "summary": "Get village by id",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Village ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.VillageResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "villages"
+                ],
+                "summary": "Delete a village",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Village ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/villages/{id}/actions": {
+            "post": {
+                "description": "Appends the action to the village log and applies it to the saved state. Supported kinds: advance {\"ticks\": n} runs production and events; adjust {\"resource\", \"delta\", \"reason\"} moves stock by hand.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "villages"
+                ],
+                "summary": "Record and apply a simulation action",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Village ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Action payload",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/httpx.RecordActionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.VillageResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid Action",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Insufficient Stock",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/villages/{id}/export": {
+            "get": {
+                "description": "A versioned snapshot of the village with its buildings, categories, tasks, rates, roads, ledger and action log. With format=archive it is a gzipped tar that also carries the referenced images.",
+                "produces": [
+                    "application/json",
+                    "application/gzip"
+                ],
+                "tags": [
+                    "villages"
+                ],
+                "summary": "Export a village",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Village ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "json (default) or archive",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.Snapshot"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/villages/{id}/inventory": {
+            "get": {
+                "description": "Current amount of every resource, summed from the inventory ledger.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "inventory"
+                ],
+                "summary": "Get village stock levels",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Village ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/services.StockLevel"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/villages/{id}/inventory/history": {
+            "get": {
+                "description": "Ledger entries newest first.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "inventory"
+                ],
+                "summary": "Get village inventory ledger",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Village ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only entries for this resource",
+                        "name": "resource",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size, default 100, max 500",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Entries to skip",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/httpx.InventoryEntryResponse"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/villages/{id}/replay": {
+            "post": {
+                "description": "Re-runs the simulation from the stored seed, or the seed in the body, and the action log and reports divergence from the saved state.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "villages"
+                ],
+                "summary": "Replay a village from its seed",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Village ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Seed override",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ReplayVillageRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.ReplayReport"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httpx.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "catalog.BuildingType": {
+            "type": "object",
+            "properties": {
+                "categories": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "description": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "imagePath": {
+                    "type": "string"
+                },
+                "levels": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/catalog.Level"
+                    }
+                },
+                "name": {
+                    "type": "string"
+                },
+                "starterTasks": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/catalog.StarterTask"
+                    }
+                },
+                "thumbnailPath": {
+                    "type": "string"
+                }
+            }
+        },
+        "catalog.Level": {
+            "type": "object",
+            "properties": {
+                "buildMinutes": {
+                    "type": "integer"
+                },
+                "cost": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer",
+                        "format": "int64"
+                    }
+                },
+                "level": {
+                    "type": "integer"
+                },
+                "rates": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer",
+                        "format": "int64"
+                    }
+                },
+                "tasks": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "catalog.StarterTask": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "httpx.BuildingResponse": {
+            "type": "object",
+            "properties": {
+                "categories": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/httpx.CategoryResponse"
+                    }
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "height": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "imagePath": {
+                    "type": "string"
+                },
+                "level": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "rates": {
+                    "description": "Rates maps a resource to what the building produces (positive) or\nconsumes (negative) per tick, the same shape the requests take.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer",
+                        "format": "int64"
+                    }
+                },
+                "rotation": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "summary": {
+                    "description": "Summary is only sent when asked for with include=summary.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/httpx.BuildingSummaryResponse"
+                        }
+                    ]
+                },
+                "targetLevel": {
+                    "type": "integer"
+                },
+                "tasks": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/httpx.TaskResponse"
+                    }
+                },
+                "thumbnailPath": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                },
+                "updatedAt": {
+                    "type": "string"
+                },
+                "villageId": {
+                    "type": "integer"
+                },
+                "width": {
+                    "type": "integer"
+                },
+                "x": {
+                    "type": "integer"
+                },
+                "y": {
+                    "type": "integer"
+                }
+            }
+        },
+        "httpx.BuildingSummaryResponse": {
+            "type": "object",
+            "properties": {
+                "buildingId": {
+                    "type": "integer"
+                },
+                "completed": {
+                    "type": "integer"
+                },
+                "lastActivity": {
+                    "type": "string"
+                },
+                "open": {
+                    "type": "integer"
+                },
+                "overdue": {
+                    "type": "integer"
+                }
+            }
+        },
+        "httpx.BulkTaskOperationRequest": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "integer"
+                },
+                "op": {
+                    "type": "string"
+                },
+                "task": {
+                    "$ref": "#/definitions/httpx.UpdateTaskRequest"
+                }
+            }
+        },
+        "httpx.BulkTasksRequest": {
+            "type": "object",
+            "properties": {
+                "operations": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/httpx.BulkTaskOperationRequest"
+                    }
+                }
+            }
+        },
+        "httpx.CategoryResponse": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "slug": {
+                    "type": "string"
+                }
+            }
+        },
+        "httpx.CloneBuildingRequest": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string"
+                },
+                "x": {
+                    "type": "integer"
+                },
+                "y": {
+                    "type": "integer"
+                }
+            }
+        },
+        "httpx.CreateBuildingRequest": {
+            "type": "object",
+            "properties": {
+                "blueprintId": {
+                    "type": "string"
+                },
+                "categories": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "description": {
+                    "type": "string"
+                },
+                "height": {
+                    "type": "integer"
+                },
+                "imagePath": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "rates": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer",
+                        "format": "int64"
+                    }
+                },
+                "rotation": {
+                    "type": "integer"
+                },
+                "tasks": {
+                    "description": "Tasks are created with the building, in the same transaction.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/httpx.CreateBuildingTaskRequest"
+                    }
+                },
+                "thumbnailPath": {
+                    "type": "string"
+                },
+                "villageId": {
+                    "type": "integer"
+                },
+                "width": {
+                    "type": "integer"
+                },
+                "x": {
+                    "type": "integer"
+                },
+                "y": {
+                    "type": "integer"
+                }
+            }
+        },
+        "httpx.CreateBuildingTaskRequest": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "estimatedMinutes": {
+                    "type": "integer"
+                },
+                "isCompleted": {
+                    "type": "boolean"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "httpx.CreateRoadRequest": {
+            "type": "object",
+            "properties": {
+                "from": {
+                    "$ref": "#/definitions/httpx.RoadEndRequest"
+                },
+                "to": {
+                    "$ref": "#/definitions/httpx.RoadEndRequest"
+                },
+                "travelMinutes": {
+                    "description": "TravelMinutes is worked out from the distance when left out.",
+                    "type": "number"
+                },
+                "villageId": {
+                    "type": "integer"
+                }
+            }
+        },
+        "httpx.CreateTaskRequest": {
+            "type": "object",
+            "properties": {
+                "buildingId": {
+                    "type": "integer"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "isCompleted": {
+                    "type": "boolean"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "httpx.CreateVillageRequest": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string"
+                },
+                "seed": {
+                    "type": "integer"
+                }
+            }
+        },
+        "httpx.ErrorResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "requestId": {
+                    "type": "string"
+                },
+                "traceId": {
+                    "type": "string"
+                }
+            }
+        },
+        "httpx.InventoryEntryResponse": {
+            "type": "object",
+            "properties": {
+                "balance": {
+                    "type": "integer"
+                },
+                "buildingId": {
+                    "type": "integer"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "delta": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "resource": {
+                    "type": "string"
+                },
+                "tick": {
+                    "type": "integer"
+                },
+                "villageId": {
+                    "type": "integer"
+                }
+            }
+        },
+        "httpx.MergeBuildingRequest": {
+            "type": "object",
+            "properties": {
+                "sourceId": {
+                    "type": "integer"
+                }
+            }
+        },
+        "httpx.MergeCategoryRequest": {
+            "type": "object",
+            "properties": {
+                "targetId": {
+                    "type": "integer"
+                }
+            }
+        },
+        "httpx.RecordActionRequest": {
+            "type": "object",
+            "properties": {
+                "kind": {
+                    "type": "string"
+                },
+                "payload": {
+                    "type": "object",
+                    "additionalProperties": {}
+                }
+            }
+        },
+        "httpx.RenameCategoryRequest": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "httpx.ReplayVillageRequest": {
+            "type": "object",
+            "properties": {
+                "seed": {
+                    "type": "integer"
+                }
+            }
+        },
+        "httpx.RoadEndRequest": {
+            "type": "object",
+            "properties": {
+                "buildingId": {
+                    "type": "integer"
+                },
+                "x": {
+                    "type": "integer"
+                },
+                "y": {
+                    "type": "integer"
+                }
+            }
+        },
+        "httpx.RoadEndResponse": {
+            "type": "object",
+            "properties": {
+                "buildingId": {
+                    "type": "integer"
+                },
+                "x": {
+                    "type": "integer"
+                },
+                "y": {
+                    "type": "integer"
+                }
+            }
+        },
+        "httpx.RoadResponse": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "from": {
+                    "$ref": "#/definitions/httpx.RoadEndResponse"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "to": {
+                    "$ref": "#/definitions/httpx.RoadEndResponse"
+                },
+                "travelMinutes": {
+                    "type": "number"
+                },
+                "updatedAt": {
+                    "type": "string"
+                },
+                "villageId": {
+                    "type": "integer"
+                }
+            }
+        },
+        "httpx.TaskResponse": {
+            "type": "object",
+            "properties": {
+                "buildingId": {
+                    "type": "integer"
+                },
+                "completedAt": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "estimatedMinutes": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "isCompleted": {
+                    "type": "boolean"
+                },
+                "kind": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "updatedAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "httpx.UpdateBuildingRequest": {
+            "type": "object",
+            "properties": {
+                "categories": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "description": {
+                    "type": "string"
+                },
+                "height": {
+                    "type": "integer"
+                },
+                "imagePath": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "rates": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer",
+                        "format": "int64"
+                    }
+                },
+                "rotation": {
+                    "type": "integer"
+                },
+                "thumbnailPath": {
+                    "type": "string"
+                },
+                "villageId": {
+                    "type": "integer"
+                },
+                "width": {
+                    "type": "integer"
+                },
+                "x": {
+                    "type": "integer"
+                },
+                "y": {
+                    "type": "integer"
+                }
+            }
+        },
+        "httpx.UpdateTaskRequest": {
+            "type": "object",
+            "properties": {
+                "buildingId": {
+                    "type": "integer"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "isCompleted": {
+                    "type": "boolean"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "httpx.VillageResponse": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "seed": {
+                    "type": "integer"
+                },
+                "stateHash": {
+                    "type": "string"
+                },
+                "tick": {
+                    "type": "integer"
+                },
+                "updatedAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "services.BulkTaskReport": {
+            "type": "object",
+            "properties": {
+                "committed": {
+                    "type": "boolean"
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.BulkTaskResult"
+                    }
+                }
+            }
+        },
+        "services.BulkTaskResult": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "index": {
+                    "type": "integer"
+                },
+                "ok": {
+                    "type": "boolean"
+                },
+                "op": {
+                    "type": "string"
+                }
+            }
+        },
+        "services.CategoryCount": {
+            "type": "object",
+            "properties": {
+                "buildings": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "slug": {
+                    "type": "string"
+                }
+            }
+        },
+        "services.CompletionStreaks": {
+            "type": "object",
+            "properties": {
+                "current": {
+                    "type": "integer"
+                },
+                "longest": {
+                    "type": "integer"
+                },
+                "longestStart": {
+                    "type": "string"
+                }
+            }
+        },
+        "services.CompletionTime": {
+            "type": "object",
+            "properties": {
+                "completed": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "meanMinutes": {
+                    "type": "number"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "services.FieldChange": {
+            "type": "object",
+            "properties": {
+                "field": {
+                    "type": "string"
+                },
+                "from": {
+                    "type": "string"
+                },
+                "to": {
+                    "type": "string"
+                }
+            }
+        },
+        "services.ImportChange": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string"
+                },
+                "buildingId": {
+                    "type": "integer"
+                },
+                "fields": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.FieldChange"
+                    }
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "services.ImportReport": {
+            "type": "object",
+            "properties": {
+                "changes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.ImportChange"
+                    }
+                },
+                "committed": {
+                    "type": "boolean"
+                },
+                "created": {
+                    "type": "integer"
+                },
+                "skipped": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.ImportSkip"
+                    }
+                },
+                "unchanged": {
+                    "type": "integer"
+                },
+                "untouched": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "updated": {
+                    "type": "integer"
+                },
+                "villageId": {
+                    "type": "integer"
+                }
+            }
+        },
+        "services.ImportSkip": {
+            "type": "object",
+            "properties": {
+                "layer": {
+                    "type": "string"
+                },
+                "objectId": {
+                    "type": "integer"
+                },
+                "reason": {
+                    "type": "string"
+                }
+            }
+        },
+        "services.MetricPoint": {
+            "type": "object",
+            "properties": {
+                "day": {
+                    "type": "string"
+                },
+                "value": {
+                    "type": "integer"
+                }
+            }
+        },
+        "services.MetricSeries": {
+            "type": "object",
+            "properties": {
+                "metric": {
+                    "type": "string"
+                },
+                "points": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.MetricPoint"
+                    }
+                }
+            }
+        },
+        "services.Path": {
+            "type": "object",
+            "properties": {
+                "fromBuildingId": {
+                    "type": "integer"
+                },
+                "nodes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.PathNode"
+                    }
+                },
+                "segments": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "toBuildingId": {
+                    "type": "integer"
+                },
+                "travelMinutes": {
+                    "type": "number"
+                }
+            }
+        },
+        "services.PathNode": {
             "type": "object",
             "properties": {
-                "building_id": {
+                "buildingId": {
                     "type": "integer"
                 },
-                "description": {
-                    "type": "string"
+                "x": {
+                    "type": "integer"
+                },
+                "y": {
+                    "type": "integer"
+                }
+            }
+        },
+        "services.ReplayReport": {
+            "type": "object",
+            "properties": {
+                "actions": {
+                    "type": "integer"
                 },
-                "is_completed": {
+                "diverged": {
                     "type": "boolean"
                 },
+                "divergences": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/sim.Divergence"
+                    }
+                },
+                "replayedHash": {
+                    "type": "string"
+                },
+                "replayedTick": {
+                    "type": "integer"
+                },
+                "savedHash": {
+                    "type": "string"
+                },
+                "savedTick": {
+                    "type": "integer"
+                },
+                "seed": {
+                    "type": "integer"
+                },
+                "villageId": {
+                    "type": "integer"
+                }
+            }
+        },
+        "services.SearchHit": {
+            "type": "object",
+            "properties": {
+                "buildingId": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "integer"
+                },
                 "name": {
                     "type": "string"
+                },
+                "score": {
+                    "type": "number"
+                },
+                "snippet": {
+                    "type": "string"
+                },
+                "villageId": {
+                    "type": "integer"
                 }
             }
         },
-        "httpx.UpdateBuildingRequest": {
+        "services.SearchResults": {
+            "type": "object",
+            "properties": {
+                "buildings": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.SearchHit"
+                    }
+                },
+                "query": {
+                    "type": "string"
+                },
+                "tasks": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.SearchHit"
+                    }
+                }
+            }
+        },
+        "services.Snapshot": {
+            "type": "object",
+            "properties": {
+                "buildings": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.SnapshotBuilding"
+                    }
+                },
+                "exportedAt": {
+                    "type": "string"
+                },
+                "inventory": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.SnapshotInventoryEntry"
+                    }
+                },
+                "roads": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.SnapshotRoad"
+                    }
+                },
+                "version": {
+                    "type": "integer"
+                },
+                "village": {
+                    "$ref": "#/definitions/services.SnapshotVillage"
+                }
+            }
+        },
+        "services.SnapshotAction": {
+            "type": "object",
+            "properties": {
+                "kind": {
+                    "type": "string"
+                },
+                "payload": {
+                    "type": "string"
+                },
+                "seq": {
+                    "type": "integer"
+                }
+            }
+        },
+        "services.SnapshotBuilding": {
             "type": "object",
             "properties": {
                 "categories": {
@@ -355,123 +2997,312 @@ const docTemplate = `{
                 "description": {
                     "type": "string"
                 },
+                "height": {
+                    "type": "integer"
+                },
                 "imagePath": {
                     "type": "string"
                 },
+                "level": {
+                    "type": "integer"
+                },
                 "name": {
                     "type": "string"
                 },
+                "rates": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer",
+                        "format": "int64"
+                    }
+                },
+                "ref": {
+                    "type": "integer"
+                },
+                "rotation": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "targetLevel": {
+                    "type": "integer"
+                },
+                "tasks": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.SnapshotTask"
+                    }
+                },
                 "thumbnailPath": {
                     "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                },
+                "width": {
+                    "type": "integer"
+                },
+                "x": {
+                    "type": "integer"
+                },
+                "y": {
+                    "type": "integer"
                 }
             }
         },
-        "httpx.UpdateTaskRequest": {
+        "services.SnapshotInventoryEntry": {
+            "type": "object",
+            "properties": {
+                "balance": {
+                    "type": "integer"
+                },
+                "buildingRef": {
+                    "type": "integer"
+                },
+                "delta": {
+                    "type": "integer"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "resource": {
+                    "type": "string"
+                },
+                "tick": {
+                    "type": "integer"
+                }
+            }
+        },
+        "services.SnapshotRoad": {
             "type": "object",
             "properties": {
-                "building_id": {
+                "fromRef": {
+                    "type": "integer"
+                },
+                "fromX": {
+                    "type": "integer"
+                },
+                "fromY": {
+                    "type": "integer"
+                },
+                "toRef": {
+                    "type": "integer"
+                },
+                "toX": {
+                    "type": "integer"
+                },
+                "toY": {
                     "type": "integer"
                 },
+                "travelComputed": {
+                    "description": "TravelComputed was added after version 1 was published; older snapshots\nimport their travel times as given.",
+                    "type": "boolean"
+                },
+                "travelMinutes": {
+                    "type": "number"
+                }
+            }
+        },
+        "services.SnapshotTask": {
+            "type": "object",
+            "properties": {
+                "completedAt": {
+                    "type": "string"
+                },
                 "description": {
                     "type": "string"
                 },
-                "is_completed": {
+                "estimatedMinutes": {
+                    "type": "integer"
+                },
+                "isCompleted": {
                     "type": "boolean"
                 },
+                "kind": {
+                    "type": "string"
+                },
                 "name": {
                     "type": "string"
                 }
             }
         },
-        "models.Building": {
+        "services.SnapshotVillage": {
             "type": "object",
             "properties": {
-                "categories": {
+                "actions": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/models.BuildingCategory"
+                        "$ref": "#/definitions/services.SnapshotAction"
                     }
                 },
-                "createdAt": {
-                    "type": "string"
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/sim.Event"
+                    }
                 },
-                "description": {
+                "name": {
                     "type": "string"
                 },
-                "id": {
+                "seed": {
                     "type": "integer"
                 },
-                "imagePath": {
+                "stateHash": {
                     "type": "string"
                 },
-                "name": {
+                "tick": {
+                    "type": "integer"
+                }
+            }
+        },
+        "services.Stats": {
+            "type": "object",
+            "properties": {
+                "activity": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.StatsBucket"
+                    }
+                },
+                "bucket": {
                     "type": "string"
                 },
-                "tasks": {
+                "buildings": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/models.Task"
+                        "$ref": "#/definitions/services.CompletionTime"
                     }
                 },
-                "thumbnailPath": {
+                "categories": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.CompletionTime"
+                    }
+                },
+                "from": {
                     "type": "string"
                 },
-                "updatedAt": {
+                "streaks": {
+                    "$ref": "#/definitions/services.CompletionStreaks"
+                },
+                "to": {
                     "type": "string"
                 }
             }
         },
-        "models.BuildingCategory": {
+        "services.StatsBucket": {
             "type": "object",
             "properties": {
-                "buildingID": {
+                "completed": {
                     "type": "integer"
                 },
-                "createdAt": {
+                "created": {
+                    "type": "integer"
+                },
+                "start": {
                     "type": "string"
+                }
+            }
+        },
+        "services.StockLevel": {
+            "type": "object",
+            "properties": {
+                "audited": {
+                    "type": "boolean"
                 },
-                "id": {
+                "balance": {
                     "type": "integer"
                 },
-                "text": {
-                    "type": "string"
+                "entries": {
+                    "type": "integer"
                 },
-                "updatedAt": {
+                "quantity": {
+                    "type": "integer"
+                },
+                "resource": {
                     "type": "string"
                 }
             }
         },
-        "models.Task": {
+        "services.TaskEstimate": {
             "type": "object",
             "properties": {
-                "building": {
-                    "$ref": "#/definitions/models.Building"
+                "homeBuildingId": {
+                    "type": "integer"
                 },
-                "buildingId": {
+                "route": {
+                    "$ref": "#/definitions/services.Path"
+                },
+                "taskId": {
                     "type": "integer"
                 },
-                "completedAt": {
-                    "type": "string"
+                "taskMinutes": {
+                    "type": "number"
                 },
-                "createdAt": {
+                "totalMinutes": {
+                    "type": "number"
+                },
+                "travelMinutes": {
+                    "type": "number"
+                }
+            }
+        },
+        "services.TaskImportError": {
+            "type": "object",
+            "properties": {
+                "column": {
                     "type": "string"
                 },
-                "description": {
+                "message": {
                     "type": "string"
                 },
-                "id": {
+                "row": {
+                    "type": "integer"
+                }
+            }
+        },
+        "services.TaskImportReport": {
+            "type": "object",
+            "properties": {
+                "created": {
                     "type": "integer"
                 },
-                "isCompleted": {
-                    "type": "boolean"
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.TaskImportError"
+                    }
                 },
-                "name": {
+                "updated": {
+                    "type": "integer"
+                }
+            }
+        },
+        "sim.Divergence": {
+            "type": "object",
+            "properties": {
+                "field": {
                     "type": "string"
                 },
-                "updatedAt": {
+                "replayed": {
+                    "type": "string"
+                },
+                "saved": {
                     "type": "string"
                 }
             }
+        },
+        "sim.Event": {
+            "type": "object",
+            "properties": {
+                "kind": {
+                    "type": "string"
+                },
+                "tick": {
+                    "type": "integer"
+                }
+            }
         }
     }
 }`
@@ -3,43 +3,55 @@ package application
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
-	"os"
 	"time"
 
+	"github.com/Stckrz/villageApi/internal/catalog"
+	"github.com/Stckrz/villageApi/internal/config"
 	"github.com/Stckrz/villageApi/internal/db"
 	"github.com/Stckrz/villageApi/internal/httpx"
+	"github.com/Stckrz/villageApi/internal/services"
+	"github.com/Stckrz/villageApi/internal/tracing"
 	"github.com/go-chi/chi/v5"
 	"gorm.io/gorm"
 )
 
-// defined struct for config items
-type Config struct {
-	Port        string
-	jwtSecret   string
-}
-
 // Our APP item which will have our config, database, router, and server. Eventually, the websocket hub too.
 type App struct {
-	Cfg    Config
+	Cfg    config.Config
 	Db     *gorm.DB
 	Router *chi.Mux
 	// Hub *ws.Hub
 	srv *http.Server
+	// cancelRequests cancels the context of every request still running, which
+	// aborts their queries.
+	cancelRequests context.CancelFunc
+	snapshots      SnapshotJob
+	// shutdownTracing flushes the spans still buffered.
+	shutdownTracing func(context.Context) error
 }
 
-func New() (*App, error) {
-	//create the cfg object
-	cfg := Config{
-		Port:        env("APP_PORT", ":8080"),
-		jwtSecret:   env("JWT_SECRET", "devsecret"),
+func New(cfg config.Config) (*App, error) {
+	//tracing first, so the db plugin and router pick up the provider
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.TraceExporter)
+	if err != nil {
+		return nil, fmt.Errorf("setup tracing: %w", err)
 	}
 
 	//create the DB connection
-	database, err := db.ConnectDb()
+	database, err := db.ConnectDb(cfg.DB)
 	if err != nil {
-		return nil, fmt.Errorf("Connect db returned nil without error")
+		return nil, fmt.Errorf("connect db: %w", err)
+	}
+
+	//load the blueprint catalogue, from a file if one is configured
+	buildingTypes := catalog.Default()
+	if cfg.CatalogPath != "" {
+		if buildingTypes, err = catalog.Load(cfg.CatalogPath); err != nil {
+			return nil, fmt.Errorf("load catalog %s: %w", cfg.CatalogPath, err)
+		}
 	}
 
 	// hub := ws.NewHub(database)
@@ -49,16 +61,32 @@ func New() (*App, error) {
 		Cfg: cfg,
 		Db:  database,
 		// hub: hub,
+		shutdownTracing: shutdownTracing,
+		snapshots: SnapshotJob{
+			Service:   services.TraceMetricHistoryService(services.NewMetricHistoryService(database)),
+			Interval:  cfg.Snapshots.Interval,
+			Retention: time.Duration(cfg.Snapshots.RetentionDays) * 24 * time.Hour,
+		},
 	}
 	app.Router = httpx.BuildRouter(httpx.RouterDeps{
-		DB: app.Db,
+		DB:              app.Db,
+		BuildingTypes:   buildingTypes,
+		ImageRoot:       cfg.ImageRoot,
+		CORS:            cfg.CORS,
+		RequestTimeout:  cfg.Server.RequestTimeout,
+		TransferTimeout: cfg.Server.TransferTimeout,
 	})
+	//every request context derives from this one, so shutdown can cancel the stragglers
+	requestCtx, cancelRequests := context.WithCancel(context.Background())
+	app.cancelRequests = cancelRequests
 	app.srv = &http.Server{
 		Addr:         cfg.Port,
 		Handler:      app.Router,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  15 * time.Second,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+		ErrorLog:     slog.NewLogLogger(slog.Default().Handler(), slog.LevelError),
+		BaseContext:  func(net.Listener) context.Context { return requestCtx },
 	}
 
 	return app, nil
@@ -68,20 +96,35 @@ func (a *App) Start(ctx context.Context) error {
 	//create buffered channel to capture errors. Buffer size is 1.
 	errCh := make(chan error, 1)
 
+	//flush the last spans once everything has stopped
+	defer func() {
+		flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := a.shutdownTracing(flushCtx); err != nil {
+			slog.Error("flush traces", "error", err)
+		}
+	}()
+
+	//background jobs stop with the server
+	jobCtx, stopJobs := context.WithCancel(ctx)
+	defer stopJobs()
+	go a.snapshots.Run(jobCtx)
+
 	//start http server in goroutine, so that we can keep listening for shutdown signals or fatal server error.
 	go func() {
 		//blocks until the server is shut down gracefully, or server error
-		log.Printf("listening on %s\n", a.Cfg.Port)
+		slog.Info("listening", "addr", a.Cfg.Port)
 		errCh <- a.srv.ListenAndServe()
 	}()
 
 	//graceful shutdown on ctx.done(). This is waiting for either the context to be cancelled, or an error from the errorchannel errCh
 	select {
-	//this case is when the parent cancels the context, like a ctrlC. Then, it gives the existingn requests 10 seconds to finish.
-	//If they do not finish, they are cancelled.
-	case <-ctx.Done(): 
-		shutCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	//this case is when the parent cancels the context, like a ctrlC. Then, it gives the existingn requests the shutdown timeout to finish.
+	//If they do not finish, they are cancelled, which also aborts their database queries.
+	case <-ctx.Done():
+		shutCtx, cancel := context.WithTimeout(context.Background(), a.Cfg.Server.ShutdownTimeout)
 		defer cancel()
+		defer a.cancelRequests()
 		if err := a.srv.Shutdown(shutCtx); err != nil {
 			return err
 		}
@@ -91,19 +134,3 @@ func (a *App) Start(ctx context.Context) error {
 		return err
 	}
 }
-
-func env(k, def string) string {
-	if v := os.Getenv(k); v != "" {
-		return v
-	}
-	return def
-}
-func mustEnv(k string) string {
-	v := os.Getenv(k)
-	if v == "" {
-		log.Fatalf("%s not set", k)
-	}
-	return v
-}
-
-
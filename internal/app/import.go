@@ -0,0 +1,60 @@
+package application
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/Stckrz/villageApi/internal/config"
+	"github.com/Stckrz/villageApi/internal/db"
+	"github.com/Stckrz/villageApi/internal/services"
+	"github.com/Stckrz/villageApi/internal/tiled"
+)
+
+// ImportTiled is the import-tiled subcommand. It prints the import report as
+// JSON to out and only writes to the database with -commit; usage and the
+// dry run notice go to errOut.
+//
+//	api import-tiled [-village id] [-commit] map.tmx
+func ImportTiled(cfg config.DB, args []string, out, errOut io.Writer) error {
+	flags := flag.NewFlagSet("import-tiled", flag.ContinueOnError)
+	flags.SetOutput(errOut)
+	village := flags.Uint("village", 0, "village to import into (0 for none)")
+	commit := flags.Bool("commit", false, "apply the import instead of only reporting it")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: import-tiled [-village id] [-commit] <map.tmx|map.json>")
+	}
+
+	tiledMap, err := tiled.Load(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	database, err := db.ConnectDb(cfg)
+	if err != nil {
+		return err
+	}
+
+	var villageID *uint
+	if *village != 0 {
+		villageID = village
+	}
+	report, err := services.NewImportService(database).ImportTiled(context.Background(), villageID, tiledMap, *commit)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return err
+	}
+	if !*commit {
+		fmt.Fprintln(errOut, "dry run, nothing was written; rerun with -commit to apply")
+	}
+	return nil
+}
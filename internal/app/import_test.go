@@ -0,0 +1,36 @@
+package application
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Stckrz/villageApi/internal/config"
+	"github.com/Stckrz/villageApi/internal/services"
+)
+
+func TestImportTiledDryRun(t *testing.T) {
+	cfg := config.DB{Path: filepath.Join(t.TempDir(), "app.db")}
+	var out, errOut bytes.Buffer
+
+	if err := ImportTiled(cfg, []string{"../tiled/testdata/village.tmj"}, &out, &errOut); err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	var report services.ImportReport
+	if err := json.Unmarshal(out.Bytes(), &report); err != nil {
+		t.Fatalf("the report isn't JSON: %v\n%s", err, out.String())
+	}
+	if report.Committed {
+		t.Fatal("a dry run committed")
+	}
+	if !strings.Contains(errOut.String(), "dry run") {
+		t.Fatalf("no dry run notice, got %q", errOut.String())
+	}
+
+	errOut.Reset()
+	if err := ImportTiled(cfg, nil, &out, &errOut); err == nil || !strings.Contains(err.Error(), "usage") {
+		t.Fatalf("import without a map: %v", err)
+	}
+}
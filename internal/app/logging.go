@@ -0,0 +1,20 @@
+package application
+
+import (
+	"log/slog"
+	"os"
+
+	"github.com/Stckrz/villageApi/internal/config"
+)
+
+// SetupLogging makes slog's default logger, which the log package writes
+// through as well, log JSON to stdout, or text in dev, at the configured level.
+func SetupLogging(cfg config.Config) {
+	options := &slog.HandlerOptions{Level: cfg.LogLevel}
+
+	var handler slog.Handler = slog.NewJSONHandler(os.Stdout, options)
+	if cfg.Environment == config.EnvironmentDev {
+		handler = slog.NewTextHandler(os.Stdout, options)
+	}
+	slog.SetDefault(slog.New(handler))
+}
@@ -0,0 +1,60 @@
+package application
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/Stckrz/villageApi/internal/metrics"
+	"github.com/Stckrz/villageApi/internal/services"
+)
+
+// SnapshotJob writes the daily metric snapshots. It snapshots on start and then
+// every Interval, overwriting the current day, so each day keeps what it looked
+// like at its last run. Days older than Retention are pruned; a zero Retention
+// keeps them all.
+type SnapshotJob struct {
+	Service   services.MetricHistoryService
+	Interval  time.Duration
+	Retention time.Duration
+}
+
+// Run snapshots until ctx is done. Failed runs are logged and retried on the
+// next tick.
+func (j SnapshotJob) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.Interval)
+	defer ticker.Stop()
+	for {
+		j.runOnce(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runOnce snapshots and prunes, counting each outcome in the job metrics. Runs
+// cut short by shutdown aren't counted.
+func (j SnapshotJob) runOnce(ctx context.Context, now time.Time) {
+	err := j.Service.TakeSnapshot(ctx, now)
+	if ctx.Err() != nil {
+		return
+	}
+	metrics.JobRun("metric_snapshot", err)
+	if err != nil {
+		slog.Error("metric snapshot failed", "error", err)
+		return
+	}
+	if j.Retention <= 0 {
+		return
+	}
+	_, err = j.Service.PruneSnapshots(ctx, now.Add(-j.Retention))
+	if ctx.Err() != nil {
+		return
+	}
+	metrics.JobRun("metric_prune", err)
+	if err != nil {
+		slog.Error("pruning metric snapshots failed", "error", err)
+	}
+}
@@ -0,0 +1,222 @@
+// Package catalog holds the blueprints buildings are created from: default
+// name, description, images and categories, the chores a new building starts
+// with, and for buildings that have to be constructed, what each level costs,
+// how long it takes and what it produces once finished.
+//
+// The catalogue is built in, but can be replaced at startup by a YAML or JSON
+// file so designers can edit it without touching code.
+package catalog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Stckrz/villageApi/internal/db/models"
+	"gopkg.in/yaml.v3"
+)
+
+var ErrInvalidCatalog = errors.New("invalid catalog")
+
+type Level struct {
+	Level        int              `json:"level" yaml:"level"`
+	Cost         map[string]int64 `json:"cost" yaml:"cost"`
+	BuildMinutes uint             `json:"buildMinutes" yaml:"buildMinutes"`
+	Rates        map[string]int64 `json:"rates" yaml:"rates"`
+	Tasks        []string         `json:"tasks" yaml:"tasks"`
+}
+
+type StarterTask struct {
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description" yaml:"description"`
+}
+
+// BuildingType is one blueprint. A blueprint with Levels has to be constructed
+// and can be upgraded; one without is created active.
+type BuildingType struct {
+	ID            string        `json:"id" yaml:"id"`
+	Name          string        `json:"name" yaml:"name"`
+	Description   string        `json:"description" yaml:"description"`
+	Categories    []string      `json:"categories" yaml:"categories"`
+	ThumbnailPath string        `json:"thumbnailPath" yaml:"thumbnailPath"`
+	ImagePath     string        `json:"imagePath" yaml:"imagePath"`
+	StarterTasks  []StarterTask `json:"starterTasks" yaml:"starterTasks"`
+	Levels        []Level       `json:"levels" yaml:"levels"`
+}
+
+type Catalog struct {
+	types map[string]BuildingType
+}
+
+type catalogFile struct {
+	Blueprints []BuildingType `json:"blueprints" yaml:"blueprints"`
+}
+
+func New(types []BuildingType) (*Catalog, error) {
+	catalog := &Catalog{types: make(map[string]BuildingType, len(types))}
+	for _, buildingType := range types {
+		if err := buildingType.validate(); err != nil {
+			return nil, err
+		}
+		if _, exists := catalog.types[buildingType.ID]; exists {
+			return nil, fmt.Errorf("%w: duplicate blueprint %q", ErrInvalidCatalog, buildingType.ID)
+		}
+		catalog.types[buildingType.ID] = buildingType
+	}
+	return catalog, nil
+}
+
+// Load reads a catalogue file. The format follows the extension: .yaml/.yml or
+// .json, with the blueprints listed under a top level "blueprints" key.
+func Load(path string) (*Catalog, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file catalogFile
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(contents, &file)
+	case ".json":
+		err = json.Unmarshal(contents, &file)
+	default:
+		return nil, fmt.Errorf("%w: unsupported file type %q", ErrInvalidCatalog, filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCatalog, err)
+	}
+	return New(file.Blueprints)
+}
+
+func (c *Catalog) Lookup(id string) (BuildingType, bool) {
+	buildingType, ok := c.types[id]
+	return buildingType, ok
+}
+
+// List returns every blueprint ordered by id.
+func (c *Catalog) List() []BuildingType {
+	types := make([]BuildingType, 0, len(c.types))
+	for _, buildingType := range c.types {
+		types = append(types, buildingType)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i].ID < types[j].ID })
+	return types
+}
+
+// LevelAt returns the definition of the given level, counting from 1.
+func (t BuildingType) LevelAt(level int) (Level, bool) {
+	if level < 1 || level > len(t.Levels) {
+		return Level{}, false
+	}
+	return t.Levels[level-1], true
+}
+
+func (t BuildingType) MaxLevel() int {
+	return len(t.Levels)
+}
+
+func (t BuildingType) validate() error {
+	if t.ID == "" {
+		return fmt.Errorf("%w: blueprint without id", ErrInvalidCatalog)
+	}
+	if t.Name == "" {
+		return fmt.Errorf("%w: blueprint %q has no name", ErrInvalidCatalog, t.ID)
+	}
+	for index, level := range t.Levels {
+		if level.Level != index+1 {
+			return fmt.Errorf("%w: blueprint %q lists level %d in position %d", ErrInvalidCatalog, t.ID, level.Level, index+1)
+		}
+		for _, amounts := range []map[string]int64{level.Cost, level.Rates} {
+			for resource := range amounts {
+				if !models.IsResource(resource) {
+					return fmt.Errorf("%w: blueprint %q level %d uses unknown resource %q", ErrInvalidCatalog, t.ID, level.Level, resource)
+				}
+			}
+		}
+		for resource, amount := range level.Cost {
+			if amount < 0 {
+				return fmt.Errorf("%w: blueprint %q level %d has a negative %s cost", ErrInvalidCatalog, t.ID, level.Level, resource)
+			}
+		}
+	}
+	for _, task := range t.StarterTasks {
+		if task.Name == "" {
+			return fmt.Errorf("%w: blueprint %q has a starter task without a name", ErrInvalidCatalog, t.ID)
+		}
+	}
+	return nil
+}
+
+// Default is the built-in catalogue, used when no catalogue file is configured.
+func Default() *Catalog {
+	catalog, err := New([]BuildingType{
+		{
+			ID:            "farm",
+			Name:          "Farm",
+			Description:   "Fields and a barn that keep the village fed.",
+			Categories:    []string{"Food", "Production"},
+			ThumbnailPath: "/images/buildings/farm_thumb.png",
+			ImagePath:     "/images/buildings/farm.png",
+			StarterTasks:  []StarterTask{{Name: "Sow the first crop", Description: "Plant the fields before the season turns."}},
+			Levels: []Level{
+				{Level: 1, Cost: map[string]int64{"wood": 20}, BuildMinutes: 30, Rates: map[string]int64{"food": 2}, Tasks: []string{"Clear the field", "Raise the barn"}},
+				{Level: 2, Cost: map[string]int64{"wood": 40, "stone": 10}, BuildMinutes: 60, Rates: map[string]int64{"food": 4}, Tasks: []string{"Dig irrigation"}},
+				{Level: 3, Cost: map[string]int64{"wood": 60, "stone": 30, "gold": 5}, BuildMinutes: 120, Rates: map[string]int64{"food": 7}, Tasks: []string{"Build a granary"}},
+			},
+		},
+		{
+			ID:            "lumber_mill",
+			Name:          "Lumber Mill",
+			Description:   "Turns the nearby forest into planks.",
+			Categories:    []string{"Production"},
+			ThumbnailPath: "/images/buildings/lumber_mill_thumb.png",
+			ImagePath:     "/images/buildings/lumber_mill.png",
+			Levels: []Level{
+				{Level: 1, Cost: map[string]int64{}, BuildMinutes: 20, Rates: map[string]int64{"wood": 2}, Tasks: []string{"Fell the first trees"}},
+				{Level: 2, Cost: map[string]int64{"wood": 30, "stone": 10}, BuildMinutes: 60, Rates: map[string]int64{"wood": 4}, Tasks: []string{"Install the saw"}},
+			},
+		},
+		{
+			ID:            "quarry",
+			Name:          "Quarry",
+			Description:   "A stone pit worked by hungry hands.",
+			Categories:    []string{"Production"},
+			ThumbnailPath: "/images/buildings/quarry_thumb.png",
+			ImagePath:     "/images/buildings/quarry.png",
+			Levels: []Level{
+				{Level: 1, Cost: map[string]int64{"wood": 30}, BuildMinutes: 45, Rates: map[string]int64{"stone": 1, "food": -1}, Tasks: []string{"Open the pit"}},
+				{Level: 2, Cost: map[string]int64{"wood": 50, "stone": 20}, BuildMinutes: 90, Rates: map[string]int64{"stone": 3, "food": -1}, Tasks: []string{"Build a crane"}},
+			},
+		},
+		{
+			ID:            "market",
+			Name:          "Market",
+			Description:   "Stalls where surplus food is sold for gold.",
+			Categories:    []string{"Trade"},
+			ThumbnailPath: "/images/buildings/market_thumb.png",
+			ImagePath:     "/images/buildings/market.png",
+			StarterTasks:  []StarterTask{{Name: "Post market days", Description: "Let the neighbouring villages know when to come."}},
+			Levels: []Level{
+				{Level: 1, Cost: map[string]int64{"wood": 40, "stone": 20}, BuildMinutes: 60, Rates: map[string]int64{"gold": 1, "food": -2}, Tasks: []string{"Set up stalls", "Invite merchants"}},
+			},
+		},
+		{
+			ID:            "shrine",
+			Name:          "Shrine",
+			Description:   "A quiet place at the edge of the village.",
+			Categories:    []string{"Culture"},
+			ThumbnailPath: "/images/buildings/shrine_thumb.png",
+			ImagePath:     "/images/buildings/shrine.png",
+			StarterTasks:  []StarterTask{{Name: "Light the candles"}},
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+	return catalog
+}
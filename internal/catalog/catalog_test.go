@@ -0,0 +1,132 @@
+package catalog
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	hut := BuildingType{
+		ID:   "hut",
+		Name: "Hut",
+		Levels: []Level{
+			{Level: 1, Cost: map[string]int64{"wood": 5}, BuildMinutes: 10, Rates: map[string]int64{"food": -1}},
+			{Level: 2, Cost: map[string]int64{"wood": 10, "stone": 2}, BuildMinutes: 20},
+		},
+	}
+	hutYAML := `
+blueprints:
+  - id: hut
+    name: Hut
+    levels:
+      - {level: 1, cost: {wood: 5}, buildMinutes: 10, rates: {food: -1}}
+      - {level: 2, cost: {wood: 10, stone: 2}, buildMinutes: 20}
+`
+
+	for _, test := range []struct {
+		name     string
+		file     string
+		contents string
+		want     []BuildingType
+		// wantErr is a fragment of the error, which always wraps ErrInvalidCatalog
+		wantErr string
+	}{
+		{name: "yaml", file: "catalog.yaml", contents: hutYAML, want: []BuildingType{hut}},
+		{name: "yml", file: "catalog.YML", contents: hutYAML, want: []BuildingType{hut}},
+		{
+			name: "json", file: "catalog.json",
+			contents: `{"blueprints": [{"id": "hut", "name": "Hut", "levels": [
+				{"level": 1, "cost": {"wood": 5}, "buildMinutes": 10, "rates": {"food": -1}},
+				{"level": 2, "cost": {"wood": 10, "stone": 2}, "buildMinutes": 20}]}]}`,
+			want: []BuildingType{hut},
+		},
+		{name: "bad yaml", file: "catalog.yaml", contents: "blueprints: [\n  - id: hut", wantErr: "yaml"},
+		{name: "bad json", file: "catalog.json", contents: `{"blueprints": [`, wantErr: "unexpected end of JSON"},
+		{name: "yaml in a json file", file: "catalog.json", contents: hutYAML, wantErr: "invalid character"},
+		{name: "unsupported extension", file: "catalog.toml", contents: hutYAML, wantErr: `unsupported file type ".toml"`},
+		{
+			name: "duplicate id", file: "catalog.yaml",
+			contents: "blueprints:\n  - {id: hut, name: Hut}\n  - {id: hut, name: Other Hut}\n",
+			wantErr:  `duplicate blueprint "hut"`,
+		},
+		{name: "missing id", file: "catalog.yaml", contents: "blueprints:\n  - {name: Hut}\n", wantErr: "blueprint without id"},
+		{name: "missing name", file: "catalog.yaml", contents: "blueprints:\n  - {id: hut}\n", wantErr: `"hut" has no name`},
+		{
+			name: "levels out of order", file: "catalog.yaml",
+			contents: "blueprints:\n  - id: hut\n    name: Hut\n    levels: [{level: 2}, {level: 1}]\n",
+			wantErr:  "lists level 2 in position 1",
+		},
+		{
+			name: "repeated level", file: "catalog.yaml",
+			contents: "blueprints:\n  - id: hut\n    name: Hut\n    levels: [{level: 1}, {level: 1}]\n",
+			wantErr:  "lists level 1 in position 2",
+		},
+		{
+			name: "skipped level", file: "catalog.yaml",
+			contents: "blueprints:\n  - id: hut\n    name: Hut\n    levels: [{level: 1}, {level: 3}]\n",
+			wantErr:  "lists level 3 in position 2",
+		},
+		{
+			name: "negative cost", file: "catalog.yaml",
+			contents: "blueprints:\n  - id: hut\n    name: Hut\n    levels: [{level: 1, cost: {wood: -5}}]\n",
+			wantErr:  "negative wood cost",
+		},
+		{
+			name: "unknown cost resource", file: "catalog.yaml",
+			contents: "blueprints:\n  - id: hut\n    name: Hut\n    levels: [{level: 1, cost: {iron: 5}}]\n",
+			wantErr:  `unknown resource "iron"`,
+		},
+		{
+			name: "unknown rate resource", file: "catalog.yaml",
+			contents: "blueprints:\n  - id: hut\n    name: Hut\n    levels: [{level: 1, rates: {mana: 1}}]\n",
+			wantErr:  `unknown resource "mana"`,
+		},
+		{
+			name: "unnamed starter task", file: "catalog.yaml",
+			contents: "blueprints:\n  - id: hut\n    name: Hut\n    starterTasks: [{description: Sweep}]\n",
+			wantErr:  "starter task without a name",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), test.file)
+			if err := os.WriteFile(path, []byte(test.contents), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			catalog, err := Load(path)
+			if test.wantErr != "" {
+				if !errors.Is(err, ErrInvalidCatalog) || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("error %v, want %v mentioning %q", err, ErrInvalidCatalog, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("load: %v", err)
+			}
+			if got := catalog.List(); !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("loaded\n%+v\nwant\n%+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	_, err := Load(filepath.Join(t.TempDir(), "catalog.yaml"))
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("error %v, want a missing file", err)
+	}
+}
+
+// The sample catalogue in data/ is what the README points designers at.
+func TestLoadSampleCatalog(t *testing.T) {
+	catalog, err := Load("../../data/catalog.yaml")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(catalog.List()) == 0 {
+		t.Fatal("the sample catalogue has no blueprints")
+	}
+}
@@ -0,0 +1,375 @@
+// Package config loads the API's settings. Each setting has a default and can
+// be overridden, in increasing order of precedence, by a JSON config file, an
+// environment variable and a command line flag. The result is validated once at
+// startup and passed down to whatever needs it; nothing else reads the
+// environment.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Stckrz/villageApi/internal/tracing"
+)
+
+const (
+	EnvironmentDev        = "dev"
+	EnvironmentProduction = "production"
+
+	// DevSecret is the default JWT secret, only accepted in dev.
+	DevSecret = "devsecret"
+
+	// FileEnv names the config file when the -config flag doesn't.
+	FileEnv = "CONFIG_FILE"
+)
+
+type Config struct {
+	// Environment is dev or production. Dev logs text instead of JSON and
+	// accepts DevSecret.
+	Environment string
+	Port        string
+	JWTSecret   string
+	LogLevel    slog.Level
+	// TraceExporter is one of the tracing package's exporters.
+	TraceExporter string
+	// CatalogPath is the blueprint catalogue to load; empty uses the built in one.
+	CatalogPath string
+	// ImageRoot is the directory building image paths are resolved against.
+	ImageRoot string
+	DB        DB
+	Server    Server
+	CORS      CORS
+	Snapshots Snapshots
+}
+
+type DB struct {
+	Path string
+}
+
+type Server struct {
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+	// ShutdownTimeout is how long running requests get to finish on shutdown.
+	ShutdownTimeout time.Duration
+	// RequestTimeout bounds each request's context. It has to stay under
+	// WriteTimeout so a slow request still gets its 504.
+	RequestTimeout time.Duration
+	// TransferTimeout replaces RequestTimeout, and the read and write
+	// timeouts, for snapshot uploads and downloads, which can be large.
+	TransferTimeout time.Duration
+}
+
+type CORS struct {
+	AllowedOrigins []string
+}
+
+type Snapshots struct {
+	Interval time.Duration
+	// RetentionDays is how many days of snapshots are kept; 0 keeps them all.
+	RetentionDays int
+}
+
+// Default is the configuration before any file, variable or flag is applied.
+func Default() Config {
+	return Config{
+		Environment:   EnvironmentProduction,
+		Port:          ":8080",
+		JWTSecret:     DevSecret,
+		LogLevel:      slog.LevelInfo,
+		TraceExporter: tracing.ExporterNone,
+		ImageRoot:     "data/images",
+		DB:            DB{Path: "data/app.db"},
+		Server: Server{
+			ReadTimeout:     15 * time.Second,
+			WriteTimeout:    15 * time.Second,
+			IdleTimeout:     15 * time.Second,
+			ShutdownTimeout: 10 * time.Second,
+			RequestTimeout:  10 * time.Second,
+			TransferTimeout: 10 * time.Minute,
+		},
+		CORS: CORS{AllowedOrigins: []string{
+			"http://127.0.0.1:5173",
+			"http://localhost:5173",
+			"http://localhost:8080",
+		}},
+		Snapshots: Snapshots{
+			Interval:      time.Hour,
+			RetentionDays: 365,
+		},
+	}
+}
+
+// setting is one configurable value: its key in the config file, its variable,
+// its flag and how to set it from text. Lists are comma separated.
+type setting struct {
+	key   string
+	env   string
+	flag  string
+	usage string
+	set   func(*Config, string) error
+}
+
+var settings = []setting{
+	{"environment", "ENVIRONMENT", "env", "dev or production", func(c *Config, v string) error {
+		c.Environment = v
+		return nil
+	}},
+	{"port", "APP_PORT", "port", "address to listen on", func(c *Config, v string) error {
+		c.Port = v
+		return nil
+	}},
+	{"jwtSecret", "JWT_SECRET", "jwt-secret", "secret signing tokens", func(c *Config, v string) error {
+		c.JWTSecret = v
+		return nil
+	}},
+	{"logLevel", "LOG_LEVEL", "log-level", "debug, info, warn or error", func(c *Config, v string) error {
+		return c.LogLevel.UnmarshalText([]byte(v))
+	}},
+	{"traceExporter", "TRACE_EXPORTER", "trace-exporter", "none, otlp or stdout", func(c *Config, v string) error {
+		c.TraceExporter = v
+		return nil
+	}},
+	{"catalogPath", "CATALOG_PATH", "catalog", "blueprint catalogue file", func(c *Config, v string) error {
+		c.CatalogPath = v
+		return nil
+	}},
+	{"imageRoot", "IMAGE_ROOT", "image-root", "directory of building images", func(c *Config, v string) error {
+		c.ImageRoot = v
+		return nil
+	}},
+	{"dbPath", "DB_PATH", "db", "SQLite database file", func(c *Config, v string) error {
+		c.DB.Path = v
+		return nil
+	}},
+	{"readTimeout", "READ_TIMEOUT", "read-timeout", "server read timeout", duration(func(c *Config) *time.Duration {
+		return &c.Server.ReadTimeout
+	})},
+	{"writeTimeout", "WRITE_TIMEOUT", "write-timeout", "server write timeout", duration(func(c *Config) *time.Duration {
+		return &c.Server.WriteTimeout
+	})},
+	{"idleTimeout", "IDLE_TIMEOUT", "idle-timeout", "server idle timeout", duration(func(c *Config) *time.Duration {
+		return &c.Server.IdleTimeout
+	})},
+	{"shutdownTimeout", "SHUTDOWN_TIMEOUT", "shutdown-timeout", "grace period for requests on shutdown", duration(func(c *Config) *time.Duration {
+		return &c.Server.ShutdownTimeout
+	})},
+	{"requestTimeout", "REQUEST_TIMEOUT", "request-timeout", "deadline of each request", duration(func(c *Config) *time.Duration {
+		return &c.Server.RequestTimeout
+	})},
+	{"transferTimeout", "TRANSFER_TIMEOUT", "transfer-timeout", "deadline of snapshot uploads and downloads", duration(func(c *Config) *time.Duration {
+		return &c.Server.TransferTimeout
+	})},
+	{"corsAllowedOrigins", "CORS_ALLOWED_ORIGINS", "cors-origins", "comma separated origins allowed by CORS", func(c *Config, v string) error {
+		c.CORS.AllowedOrigins = []string{}
+		for _, origin := range strings.Split(v, ",") {
+			if origin = strings.TrimSpace(origin); origin != "" {
+				c.CORS.AllowedOrigins = append(c.CORS.AllowedOrigins, origin)
+			}
+		}
+		return nil
+	}},
+	{"snapshotInterval", "SNAPSHOT_INTERVAL", "snapshot-interval", "how often metric snapshots are taken", duration(func(c *Config) *time.Duration {
+		return &c.Snapshots.Interval
+	})},
+	{"snapshotRetentionDays", "SNAPSHOT_RETENTION_DAYS", "snapshot-retention-days", "days of metric snapshots kept, 0 for all", func(c *Config, v string) error {
+		days, err := strconv.Atoi(v)
+		c.Snapshots.RetentionDays = days
+		return err
+	}},
+}
+
+func duration(field func(*Config) *time.Duration) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		value, err := time.ParseDuration(v)
+		*field(c) = value
+		return err
+	}
+}
+
+// Load builds the configuration from the defaults, the config file, the
+// environment and args, the command line flags. The file is JSON, an object
+// keyed by the settings' file keys, named by -config or CONFIG_FILE; without
+// one only the other sources apply. Help for the flags returns flag.ErrHelp.
+func Load(args []string, output io.Writer) (Config, error) {
+	cfg, err := load(args, output)
+	if err != nil {
+		return Config{}, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// LoadDB builds the configuration like Load, from the file and environment
+// only, for offline commands that just open the database: only the DB section
+// is validated, so they run without the server's settings, like a JWT secret.
+func LoadDB() (Config, error) {
+	cfg, err := load(nil, io.Discard)
+	if err != nil {
+		return Config{}, err
+	}
+	if err := cfg.DB.Validate(); err != nil {
+		return Config{}, fmt.Errorf("invalid config: %w", err)
+	}
+	return cfg, nil
+}
+
+func load(args []string, output io.Writer) (Config, error) {
+	flags := flag.NewFlagSet("api", flag.ContinueOnError)
+	flags.SetOutput(output)
+	file := flags.String("config", os.Getenv(FileEnv), "JSON config file")
+	values := make(map[string]*string, len(settings))
+	for _, s := range settings {
+		values[s.flag] = flags.String(s.flag, "", fmt.Sprintf("%s (%s)", s.usage, s.env))
+	}
+	if err := flags.Parse(args); err != nil {
+		return Config{}, err
+	}
+	if flags.NArg() > 0 {
+		return Config{}, fmt.Errorf("unexpected argument %q", flags.Arg(0))
+	}
+
+	cfg := Default()
+	if *file != "" {
+		if err := cfg.loadFile(*file); err != nil {
+			return Config{}, err
+		}
+	}
+	for _, s := range settings {
+		if v, ok := os.LookupEnv(s.env); ok && v != "" {
+			if err := s.set(&cfg, v); err != nil {
+				return Config{}, fmt.Errorf("invalid %s %q: %w", s.env, v, err)
+			}
+		}
+	}
+	var err error
+	flags.Visit(func(f *flag.Flag) {
+		s, ok := settingByFlag(f.Name)
+		if !ok || err != nil {
+			return
+		}
+		if setErr := s.set(&cfg, *values[f.Name]); setErr != nil {
+			err = fmt.Errorf("invalid -%s %q: %w", f.Name, *values[f.Name], setErr)
+		}
+	})
+	if err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+func settingByFlag(name string) (setting, bool) {
+	index := slices.IndexFunc(settings, func(s setting) bool { return s.flag == name })
+	if index < 0 {
+		return setting{}, false
+	}
+	return settings[index], true
+}
+
+// loadFile applies the settings in the JSON file at path. Values may be strings,
+// numbers or, for lists, arrays of strings; unknown keys are refused so a typo
+// doesn't go unnoticed.
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("parse config file %s: %w", path, err)
+	}
+	for _, s := range settings {
+		message, ok := raw[s.key]
+		if !ok {
+			continue
+		}
+		delete(raw, s.key)
+		v, err := fileValue(message)
+		if err != nil {
+			return fmt.Errorf("config file %s: %s: %w", path, s.key, err)
+		}
+		if err := s.set(c, v); err != nil {
+			return fmt.Errorf("config file %s: invalid %s %q: %w", path, s.key, v, err)
+		}
+	}
+	for key := range raw {
+		return fmt.Errorf("config file %s: unknown setting %q", path, key)
+	}
+	return nil
+}
+
+// fileValue is a file value as the text a variable would hold.
+func fileValue(message json.RawMessage) (string, error) {
+	var text string
+	if err := json.Unmarshal(message, &text); err == nil {
+		return text, nil
+	}
+	var list []string
+	if err := json.Unmarshal(message, &list); err == nil {
+		return strings.Join(list, ","), nil
+	}
+	var number json.Number
+	decoder := json.NewDecoder(bytes.NewReader(message))
+	decoder.UseNumber()
+	if err := decoder.Decode(&number); err == nil {
+		return number.String(), nil
+	}
+	return "", errors.New("must be a string, a number or a list of strings")
+}
+
+// Validate reports every problem with the configuration at once.
+func (c Config) Validate() error {
+	var problems []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			problems = append(problems, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Environment == EnvironmentDev || c.Environment == EnvironmentProduction,
+		"environment must be %s or %s, not %q", EnvironmentDev, EnvironmentProduction, c.Environment)
+	check(c.Port != "", "port is empty")
+	check(c.JWTSecret != "", "jwt secret is empty")
+	check(c.JWTSecret != DevSecret || c.Environment == EnvironmentDev,
+		"the default jwt secret is only allowed in %s; set JWT_SECRET", EnvironmentDev)
+	check(slices.Contains([]string{tracing.ExporterNone, tracing.ExporterOTLP, tracing.ExporterStdout}, c.TraceExporter),
+		"unknown trace exporter %q", c.TraceExporter)
+	if err := c.DB.Validate(); err != nil {
+		problems = append(problems, err)
+	}
+	check(c.Server.ReadTimeout > 0, "read timeout must be positive")
+	check(c.Server.WriteTimeout > 0, "write timeout must be positive")
+	check(c.Server.IdleTimeout > 0, "idle timeout must be positive")
+	check(c.Server.ShutdownTimeout > 0, "shutdown timeout must be positive")
+	check(c.Server.RequestTimeout > 0 && c.Server.RequestTimeout < c.Server.WriteTimeout,
+		"request timeout must be positive and under the write timeout")
+	check(c.Server.TransferTimeout > 0, "transfer timeout must be positive")
+	check(len(c.CORS.AllowedOrigins) > 0, "no CORS origins allowed")
+	check(c.Snapshots.Interval > 0, "snapshot interval must be positive")
+	check(c.Snapshots.RetentionDays >= 0, "snapshot retention days must not be negative")
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid config: %w", errors.Join(problems...))
+	}
+	return nil
+}
+
+// Validate reports a problem with the database settings.
+func (d DB) Validate() error {
+	if d.Path == "" {
+		return errors.New("db path is empty")
+	}
+	return nil
+}
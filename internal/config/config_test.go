@@ -0,0 +1,134 @@
+package config
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clearEnv keeps variables set outside the test from leaking into it.
+func clearEnv(t *testing.T) {
+	t.Helper()
+	t.Setenv(FileEnv, "")
+	for _, s := range settings {
+		t.Setenv(s.env, "")
+	}
+}
+
+func writeFile(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	clearEnv(t)
+	file := writeFile(t, `{"environment": "dev", "port": ":1000", "dbPath": "file.db", "logLevel": "warn"}`)
+	t.Setenv(FileEnv, file)
+	t.Setenv("APP_PORT", ":2000")
+	t.Setenv("DB_PATH", "env.db")
+
+	cfg, err := Load([]string{"-db", "flag.db"}, io.Discard)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.Environment != EnvironmentDev || cfg.LogLevel.String() != "WARN" {
+		t.Fatalf("file settings not applied: %+v", cfg)
+	}
+	if cfg.Port != ":2000" {
+		t.Fatalf("port %q, want the variable's over the file's", cfg.Port)
+	}
+	if cfg.DB.Path != "flag.db" {
+		t.Fatalf("db path %q, want the flag's over the variable's", cfg.DB.Path)
+	}
+	if cfg.Server.WriteTimeout != Default().Server.WriteTimeout {
+		t.Fatalf("write timeout %s, want the default", cfg.Server.WriteTimeout)
+	}
+}
+
+func TestLoadFileValues(t *testing.T) {
+	clearEnv(t)
+	file := writeFile(t, `{
+		"environment": "dev",
+		"corsAllowedOrigins": ["http://a.example", "http://b.example"],
+		"snapshotRetentionDays": 30,
+		"snapshotInterval": "15m"
+	}`)
+
+	cfg, err := Load([]string{"-config", file}, io.Discard)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if !slices.Equal(cfg.CORS.AllowedOrigins, []string{"http://a.example", "http://b.example"}) {
+		t.Fatalf("origins %v", cfg.CORS.AllowedOrigins)
+	}
+	if cfg.Snapshots.RetentionDays != 30 || cfg.Snapshots.Interval != 15*time.Minute {
+		t.Fatalf("snapshots %+v", cfg.Snapshots)
+	}
+
+	for contents, want := range map[string]string{
+		`{"environment": "dev", "dbPth": "typo.db"}`: `unknown setting "dbPth"`,
+		`{"readTimeout": "soon"}`:                    "invalid readTimeout",
+		`{"port": {"number": 80}}`:                   "must be a string, a number or a list of strings",
+		`not json`:                                   "parse config file",
+	} {
+		if _, err := Load([]string{"-config", writeFile(t, contents)}, io.Discard); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("file %s: error %v, want %q", contents, err, want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Default().Validate(); err == nil || !strings.Contains(err.Error(), "default jwt secret") {
+		t.Fatalf("default secret in production: %v", err)
+	}
+
+	cfg := Default()
+	cfg.Environment = EnvironmentDev
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("default secret in dev: %v", err)
+	}
+
+	cfg.Server.RequestTimeout = cfg.Server.WriteTimeout
+	cfg.Server.TransferTimeout = 0
+	cfg.DB.Path = ""
+	cfg.TraceExporter = "jaeger"
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected problems")
+	}
+	for _, want := range []string{"request timeout", "transfer timeout", "db path is empty", `unknown trace exporter "jaeger"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("error %q doesn't report %q", err, want)
+		}
+	}
+}
+
+func TestLoadDBOnlyChecksTheDatabase(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("DB_PATH", "import.db")
+
+	if _, err := Load(nil, io.Discard); err == nil {
+		t.Fatal("the server loaded with the default secret in production")
+	}
+	cfg, err := LoadDB()
+	if err != nil {
+		t.Fatalf("load db: %v", err)
+	}
+	if cfg.DB.Path != "import.db" {
+		t.Fatalf("db path %q", cfg.DB.Path)
+	}
+
+	t.Setenv(FileEnv, writeFile(t, `{"dbPath": ""}`))
+	t.Setenv("DB_PATH", "")
+	if _, err := LoadDB(); err == nil || !strings.Contains(err.Error(), "db path is empty") {
+		t.Fatalf("empty db path: %v", err)
+	}
+}
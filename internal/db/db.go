@@ -2,40 +2,56 @@ package db
 
 import (
 	"fmt"
-	"log"
-	"os"
+	"strings"
 
+	"github.com/Stckrz/villageApi/internal/config"
 	"github.com/Stckrz/villageApi/internal/db/models"
+	"github.com/Stckrz/villageApi/internal/metrics"
+	"github.com/Stckrz/villageApi/internal/tracing"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
-func ConnectDb() (*gorm.DB, error) {
-	//try to get dbPath from env, otherwise next to main.go
-	dbPath := os.Getenv("DB_PATH")
-	if dbPath == "" {
-		// dbPath = "/data/app.db"
-		dbPath = "data/app.db"
-	}
-
+func ConnectDb(cfg config.DB) (*gorm.DB, error) {
 	dsn := fmt.Sprintf(
 		"%s?_pragma=journal_mode(WAL)&_pragma=busy_timeout=5000",
-		dbPath,
+		cfg.Path,
 	)
 	// dsn := "app.db?_pragma=journal_mode(WAL)&_pragma=busy_timeout=5000"
 
 	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
 	if err != nil {
-		log.Fatal("Failed to connect to database: ", err)
+		return nil, err
+	}
+	if err := db.Use(metrics.GormPlugin{}); err != nil {
+		return nil, err
+	}
+	if err := db.Use(tracing.GormPlugin{}); err != nil {
 		return nil, err
 	}
 	if err := db.AutoMigrate(
 		&models.Building{},
-		&models.BuildingCategory{},
+		&models.Category{},
 		&models.Task{},
+		&models.Village{},
+		&models.VillageAction{},
+		&models.VillageEvent{},
+		&models.BuildingRate{},
+		&models.InventoryEntry{},
+		&models.RoadSegment{},
+		&models.MetricSnapshot{},
 	); err != nil {
 		return nil, err
 	}
+	if err := migrateLegacyCategories(db); err != nil {
+		return nil, err
+	}
+	if err := pruneCategoryLinks(db); err != nil {
+		return nil, err
+	}
+	if err := migrateSearch(db); err != nil {
+		return nil, err
+	}
 	// if err := db.Exec(`
 	// 	CREATE UNIQUE INDEX IF NOT EXISTS unique_open_round_per_dealer
 	// 	ON betting_rounds (dealer_id)
@@ -43,9 +59,63 @@ func ConnectDb() (*gorm.DB, error) {
 	// `).Error; err != nil {
 	// 		return nil, err
 	// }
-	if os.Getenv("ENVIRONMENT") == "dev" {
-	}
-
 	return db, nil
 }
 
+// pruneCategoryLinks deletes the category links of buildings that no longer
+// exist. SQLite doesn't enforce the cascade, and buildings used to be deleted
+// without their links.
+func pruneCategoryLinks(db *gorm.DB) error {
+	return db.Exec("DELETE FROM building_category_links WHERE building_id NOT IN (SELECT id FROM buildings)").Error
+}
+
+// migrateLegacyCategories moves the old per-building free text categories into
+// the shared categories table, linking each building still there to the
+// category with the same slug, then drops the old table. It is a no-op once that
+// table is gone.
+func migrateLegacyCategories(db *gorm.DB) error {
+	if !db.Migrator().HasTable("building_categories") {
+		return nil
+	}
+
+	return db.Transaction(func(transaction *gorm.DB) error {
+		var legacy []struct {
+			BuildingID uint
+			Text       string
+		}
+		// rows of buildings deleted since are left behind, foreign keys being off
+		if err := transaction.
+			Table("building_categories").
+			Select("building_categories.building_id, building_categories.text").
+			Joins("JOIN buildings ON buildings.id = building_categories.building_id").
+			Order("building_categories.id").
+			Scan(&legacy).Error; err != nil {
+			return err
+		}
+
+		categoryIDs := map[string]uint{}
+		for _, row := range legacy {
+			slug := models.CategorySlug(row.Text)
+			if slug == "" {
+				continue
+			}
+			if _, ok := categoryIDs[slug]; !ok {
+				category := models.Category{Name: strings.TrimSpace(row.Text), Slug: slug}
+				if err := transaction.
+					Where(models.Category{Slug: slug}).
+					FirstOrCreate(&category).Error; err != nil {
+					return err
+				}
+				categoryIDs[slug] = category.ID
+			}
+			if err := transaction.Exec(
+				"INSERT OR IGNORE INTO building_category_links (building_id, category_id) VALUES (?, ?)",
+				row.BuildingID, categoryIDs[slug],
+			).Error; err != nil {
+				return err
+			}
+		}
+
+		return transaction.Migrator().DropTable("building_categories")
+	})
+}
@@ -0,0 +1,56 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/Stckrz/villageApi/internal/config"
+	"github.com/Stckrz/villageApi/internal/db/models"
+)
+
+func TestLegacyCategoriesSkipDeletedBuildings(t *testing.T) {
+	cfg := config.DB{Path: filepath.Join(t.TempDir(), "test.db")}
+	database, err := ConnectDb(cfg)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	building := models.Building{Name: "Farm"}
+	if err := database.Create(&building).Error; err != nil {
+		t.Fatalf("create building: %v", err)
+	}
+	for _, statement := range []string{
+		"CREATE TABLE building_categories (id INTEGER PRIMARY KEY, building_id INTEGER, text TEXT)",
+		"INSERT INTO building_categories (building_id, text) VALUES (1, 'Food'), (99, 'Ghost')",
+		"INSERT INTO categories (name, slug) VALUES ('Stale', 'stale')",
+		"INSERT INTO building_category_links (building_id, category_id) VALUES (98, 1)",
+	} {
+		if err := database.Exec(statement).Error; err != nil {
+			t.Fatalf("%s: %v", statement, err)
+		}
+	}
+
+	if database, err = ConnectDb(cfg); err != nil {
+		t.Fatalf("reconnect: %v", err)
+	}
+	var links []struct {
+		BuildingID uint
+		Slug       string
+	}
+	if err := database.
+		Table("building_category_links").
+		Select("building_category_links.building_id, categories.slug").
+		Joins("JOIN categories ON categories.id = building_category_links.category_id").
+		Scan(&links).Error; err != nil {
+		t.Fatalf("links: %v", err)
+	}
+	if len(links) != 1 || links[0].BuildingID != building.ID || links[0].Slug != "food" {
+		t.Fatalf("links %+v, want only building %d to food", links, building.ID)
+	}
+	var ghosts int64
+	if err := database.Model(&models.Category{}).Where("slug = ?", "ghost").Count(&ghosts).Error; err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	if ghosts != 0 {
+		t.Fatal("a category was created for a deleted building")
+	}
+}
@@ -2,22 +2,51 @@ package models
 
 import "time"
 
+const (
+	BuildingStatusActive            = "active"
+	BuildingStatusUnderConstruction = "under_construction"
+)
+
 type Building struct {
 	ID            uint               `gorm:"primaryKey"`
+	VillageID     *uint              `gorm:"index"`
 	Name          string             `gorm:"not null"`
 	Description   string             `gorm:"not null"`
-	Categories    []BuildingCategory `gorm:"foreignKey:BuildingID;constraint:OnDelete:CASCADE;"`
+	Categories    []Category         `gorm:"many2many:building_category_links;constraint:OnDelete:CASCADE;"`
 	Tasks         []Task             `gorm:"foreignKey:BuildingID;constraint:OnDelete:CASCADE;"`
+	Rates         []BuildingRate     `gorm:"foreignKey:BuildingID;constraint:OnDelete:CASCADE;"`
 	ThumbnailPath string             `gorm:"not null"`
 	ImagePath     string             `gorm:"not null"`
+	Type          string             `gorm:"index;not null;default:''"`
+	Level         int                `gorm:"not null;default:0"`
+	TargetLevel   int                `gorm:"not null;default:0"`
+	Status        string             `gorm:"index;not null;default:active"`
+	X             int                `gorm:"index:idx_building_position;not null;default:0"`
+	Y             int                `gorm:"index:idx_building_position;not null;default:0"`
+	Width         int                `gorm:"not null;default:0"`
+	Height        int                `gorm:"not null;default:0"`
+	Rotation      int                `gorm:"not null;default:0"`
 	CreatedAt     time.Time          
 	UpdatedAt     time.Time         
 }
 
-type BuildingCategory struct {
-	ID         uint   `gorm:"primaryKey"`
-	BuildingID uint   `gorm:"index;not null"`
-	Text       string `gorm:"not null"`
-	CreatedAt     time.Time          
-	UpdatedAt     time.Time         
+// Placed reports whether the building has a footprint on the map. Buildings
+// without one are kept off the grid and never collide.
+func (b Building) Placed() bool {
+	return b.Width > 0 && b.Height > 0
+}
+
+// Extent is the footprint size on the grid once rotation is applied: a quarter
+// turn swaps width and height, anchored at X, Y.
+func (b Building) Extent() (width int, height int) {
+	if b.Rotation == 90 || b.Rotation == 270 {
+		return b.Height, b.Width
+	}
+	return b.Width, b.Height
+}
+
+// Centre is the middle of the rotated footprint, where roads meet the building.
+func (b Building) Centre() (x float64, y float64) {
+	width, height := b.Extent()
+	return float64(b.X) + float64(width)/2, float64(b.Y) + float64(height)/2
 }
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Category is a tag shared by every building that carries it. Slug is the
+// normalized name used for uniqueness, so "Farm" and " farm" are one category;
+// Name keeps the casing it was first created (or last renamed) with.
+type Category struct {
+	ID        uint   `gorm:"primaryKey"`
+	Name      string `gorm:"not null"`
+	Slug      string `gorm:"uniqueIndex;not null"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func CategorySlug(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
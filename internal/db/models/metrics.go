@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+const (
+	MetricBuildings      = "buildings"
+	MetricOpenTasks      = "open_tasks"
+	MetricCompletedTasks = "completed_tasks"
+	// MetricResourcePrefix is followed by a resource name for that resource's
+	// stock, e.g. "resource.wood".
+	MetricResourcePrefix = "resource."
+)
+
+// MetricSnapshot is the value one metric had at the end of a UTC day, or so far
+// for the current day. VillageID 0 is the whole village database; resource
+// stock is only kept per village. Snapshots outlive the rows they counted.
+type MetricSnapshot struct {
+	ID        uint   `gorm:"primaryKey"`
+	Day       string `gorm:"uniqueIndex:idx_metric_snapshot;not null"`
+	VillageID uint   `gorm:"uniqueIndex:idx_metric_snapshot;not null;default:0"`
+	Metric    string `gorm:"uniqueIndex:idx_metric_snapshot;not null"`
+	Value     int64  `gorm:"not null"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+const (
+	ResourceWood  = "wood"
+	ResourceStone = "stone"
+	ResourceFood  = "food"
+	ResourceGold  = "gold"
+)
+
+// Resources lists every resource type a village can stock, in display order.
+var Resources = []string{ResourceWood, ResourceStone, ResourceFood, ResourceGold}
+
+func IsResource(name string) bool {
+	for _, resource := range Resources {
+		if resource == name {
+			return true
+		}
+	}
+	return false
+}
+
+// InventoryEntry is one line of a village's inventory ledger. Stock is never
+// stored directly; it is the sum of Delta per resource, and Balance records the
+// running total after this entry so the ledger can be audited line by line.
+type InventoryEntry struct {
+	ID         uint   `gorm:"primaryKey"`
+	VillageID  uint   `gorm:"index:idx_inventory_village_resource;not null"`
+	Resource   string `gorm:"index:idx_inventory_village_resource;not null"`
+	Delta      int64  `gorm:"not null"`
+	Balance    int64  `gorm:"not null;check:chk_inventory_balance,balance >= 0"`
+	Reason     string `gorm:"not null"`
+	BuildingID *uint  `gorm:"index"`
+	Tick       uint64 `gorm:"not null"`
+	CreatedAt  time.Time
+}
+
+// BuildingRate is how much of a resource a building produces (positive) or
+// consumes (negative) every tick.
+type BuildingRate struct {
+	ID         uint   `gorm:"primaryKey"`
+	BuildingID uint   `gorm:"uniqueIndex:idx_building_rate;not null"`
+	Resource   string `gorm:"uniqueIndex:idx_building_rate;not null"`
+	PerTick    int64  `gorm:"not null"`
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// MinutesPerTile is the travel time along one grid tile of road, used when a
+// segment is created without an explicit travel time.
+const MinutesPerTile = 1.0
+
+// RoadSegment is an undirected edge of the road network. Each end is either a
+// building (the BuildingID is set and X/Y are ignored) or a bare map tile.
+// TravelComputed marks a travel time worked out from the distance between the
+// ends rather than given, which is worked out again when a building end moves.
+type RoadSegment struct {
+	ID             uint    `gorm:"primaryKey"`
+	VillageID      *uint   `gorm:"index"`
+	FromBuildingID *uint   `gorm:"index"`
+	FromX          int     `gorm:"not null;default:0"`
+	FromY          int     `gorm:"not null;default:0"`
+	ToBuildingID   *uint   `gorm:"index"`
+	ToX            int     `gorm:"not null;default:0"`
+	ToY            int     `gorm:"not null;default:0"`
+	TravelMinutes  float64 `gorm:"not null"`
+	TravelComputed bool    `gorm:"not null;default:false"`
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
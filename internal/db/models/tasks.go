@@ -2,14 +2,21 @@ package models
 
 import "time"
 
+const (
+	TaskKindChore        = "chore"
+	TaskKindConstruction = "construction"
+)
+
 type Task struct {
-	ID          uint       `gorm:"primaryKey"`
-	Name        string     `gorm:"not null"`
-	Description string     `gorm:"not null"`
-	BuildingId  uint       `gorm:"index;not null"`
-	Building    Building   `gorm:"constraint:OnDelete:CASCADE; json:"-""`
-	IsCompleted bool       `gorm:"not null;default:false"`
-	CompletedAt *time.Time `gorm:"index"`
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
+	ID               uint       `gorm:"primaryKey"`
+	Name             string     `gorm:"not null"`
+	Description      string     `gorm:"not null"`
+	BuildingId       uint       `gorm:"index;not null"`
+	Building         Building   `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+	Kind             string     `gorm:"index;not null;default:chore"`
+	EstimatedMinutes uint       `gorm:"not null;default:0"`
+	IsCompleted      bool       `gorm:"not null;default:false"`
+	CompletedAt      *time.Time `gorm:"index"`
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
 }
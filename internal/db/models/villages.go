@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// Village is the root of a simulation run. Seed and the ordered Actions log are
+// everything needed to rebuild its state from scratch; Tick, StateHash and
+// Events are the state as it was saved by the live simulation.
+type Village struct {
+	ID        uint            `gorm:"primaryKey"`
+	Name      string          `gorm:"not null"`
+	Seed      int64           `gorm:"not null"`
+	Tick      uint64          `gorm:"not null;default:0"`
+	StateHash string          `gorm:"not null"`
+	Actions   []VillageAction `gorm:"foreignKey:VillageID;constraint:OnDelete:CASCADE;"`
+	Events    []VillageEvent  `gorm:"foreignKey:VillageID;constraint:OnDelete:CASCADE;"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// VillageAction is one entry of the recorded action log, applied in Seq order.
+type VillageAction struct {
+	ID        uint   `gorm:"primaryKey"`
+	VillageID uint   `gorm:"uniqueIndex:idx_village_action_seq;not null"`
+	Seq       uint   `gorm:"uniqueIndex:idx_village_action_seq;not null"`
+	Kind      string `gorm:"not null"`
+	Payload   string `gorm:"not null"`
+	CreatedAt time.Time
+}
+
+// VillageEvent is a random event rolled by the simulation.
+type VillageEvent struct {
+	ID        uint   `gorm:"primaryKey"`
+	VillageID uint   `gorm:"index;not null"`
+	Tick      uint64 `gorm:"not null"`
+	Kind      string `gorm:"not null"`
+	CreatedAt time.Time
+}
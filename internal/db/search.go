@@ -0,0 +1,143 @@
+package db
+
+import (
+	"fmt"
+	"log/slog"
+
+	"gorm.io/gorm"
+)
+
+// The search index is two FTS5 tables keyed by rowid: building_search holds a
+// building's name, description and category names, task_search a task's name
+// and description. Triggers keep them in step with every write, whichever code
+// path makes it, so the services never touch them directly.
+//
+// FTS5 is only compiled into the sqlite driver with the sqlite_fts5 build tag.
+// Without it the tables can't be created; the API still starts and search
+// reports itself unavailable.
+const (
+	buildingSearchRow = `
+		INSERT INTO building_search (rowid, name, description, categories)
+		SELECT b.id, b.name, b.description, COALESCE((
+			SELECT group_concat(c.name, ' ')
+			FROM building_category_links l JOIN categories c ON c.id = l.category_id
+			WHERE l.building_id = b.id
+		), '')
+		FROM buildings b WHERE %s;`
+	taskSearchRow = `
+		INSERT INTO task_search (rowid, name, description)
+		SELECT t.id, t.name, t.description FROM tasks t WHERE %s;`
+)
+
+// searchTriggers are created as "CREATE TRIGGER IF NOT EXISTS <name> <body>".
+var searchTriggers = []struct {
+	name string
+	body string
+}{
+	{"building_search_insert", `AFTER INSERT ON buildings BEGIN
+		` + fmt.Sprintf(buildingSearchRow, "b.id = NEW.id") + `
+	END`},
+	{"building_search_update", `AFTER UPDATE OF name, description ON buildings BEGIN
+		DELETE FROM building_search WHERE rowid = OLD.id;
+		` + fmt.Sprintf(buildingSearchRow, "b.id = NEW.id") + `
+	END`},
+	{"building_search_delete", `AFTER DELETE ON buildings BEGIN
+		DELETE FROM building_search WHERE rowid = OLD.id;
+	END`},
+	{"building_search_link_insert", `AFTER INSERT ON building_category_links BEGIN
+		DELETE FROM building_search WHERE rowid = NEW.building_id;
+		` + fmt.Sprintf(buildingSearchRow, "b.id = NEW.building_id") + `
+	END`},
+	{"building_search_link_delete", `AFTER DELETE ON building_category_links BEGIN
+		DELETE FROM building_search WHERE rowid = OLD.building_id;
+		` + fmt.Sprintf(buildingSearchRow, "b.id = OLD.building_id") + `
+	END`},
+	{"building_search_category_rename", `AFTER UPDATE OF name ON categories BEGIN
+		DELETE FROM building_search WHERE rowid IN (SELECT building_id FROM building_category_links WHERE category_id = NEW.id);
+		` + fmt.Sprintf(buildingSearchRow, "b.id IN (SELECT building_id FROM building_category_links WHERE category_id = NEW.id)") + `
+	END`},
+	{"task_search_insert", `AFTER INSERT ON tasks BEGIN
+		` + fmt.Sprintf(taskSearchRow, "t.id = NEW.id") + `
+	END`},
+	{"task_search_update", `AFTER UPDATE OF name, description ON tasks BEGIN
+		DELETE FROM task_search WHERE rowid = OLD.id;
+		` + fmt.Sprintf(taskSearchRow, "t.id = NEW.id") + `
+	END`},
+	{"task_search_delete", `AFTER DELETE ON tasks BEGIN
+		DELETE FROM task_search WHERE rowid = OLD.id;
+	END`},
+}
+
+// migrateSearch creates the search tables and their triggers. The index is
+// rebuilt from the existing rows whenever the triggers weren't in place, since
+// writes made in the meantime never reached it.
+func migrateSearch(db *gorm.DB) error {
+	if !fts5Enabled(db) {
+		// a database indexed by an FTS5 build would otherwise refuse every write
+		for _, trigger := range searchTriggers {
+			if err := db.Exec("DROP TRIGGER IF EXISTS " + trigger.name).Error; err != nil {
+				return err
+			}
+		}
+		slog.Warn("search disabled: sqlite was built without FTS5, build with -tags sqlite_fts5")
+		return nil
+	}
+
+	var triggers int64
+	if err := db.Raw(
+		"SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name IN ?", searchTriggerNames(),
+	).Scan(&triggers).Error; err != nil {
+		return err
+	}
+
+	return db.Transaction(func(transaction *gorm.DB) error {
+		for _, statement := range []string{
+			`CREATE VIRTUAL TABLE IF NOT EXISTS building_search
+			USING fts5(name, description, categories, tokenize = 'unicode61 remove_diacritics 2')`,
+			`CREATE VIRTUAL TABLE IF NOT EXISTS task_search
+			USING fts5(name, description, tokenize = 'unicode61 remove_diacritics 2')`,
+		} {
+			if err := transaction.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		for _, trigger := range searchTriggers {
+			if err := transaction.Exec("CREATE TRIGGER IF NOT EXISTS " + trigger.name + " " + trigger.body).Error; err != nil {
+				return err
+			}
+		}
+		if int(triggers) == len(searchTriggers) {
+			return nil
+		}
+		for _, statement := range []string{
+			"DELETE FROM building_search",
+			"DELETE FROM task_search",
+			fmt.Sprintf(buildingSearchRow, "1"),
+			fmt.Sprintf(taskSearchRow, "1"),
+		} {
+			if err := transaction.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// SearchAvailable reports whether the search index can be queried: the driver
+// has FTS5 and the index tables exist.
+func SearchAvailable(db *gorm.DB) bool {
+	return fts5Enabled(db) && db.Migrator().HasTable("building_search")
+}
+
+func searchTriggerNames() []string {
+	names := make([]string, 0, len(searchTriggers))
+	for _, trigger := range searchTriggers {
+		names = append(names, trigger.name)
+	}
+	return names
+}
+
+func fts5Enabled(db *gorm.DB) bool {
+	var enabled bool
+	return db.Raw("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&enabled).Error == nil && enabled
+}
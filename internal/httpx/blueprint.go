@@ -0,0 +1,48 @@
+package httpx
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Stckrz/villageApi/internal/catalog"
+	"github.com/go-chi/chi/v5"
+)
+
+type BlueprintHandler struct {
+	buildingTypes *catalog.Catalog
+}
+
+func NewBlueprintHandler(buildingTypes *catalog.Catalog) *BlueprintHandler {
+	return &BlueprintHandler{buildingTypes: buildingTypes}
+}
+
+// GetBlueprints godoc
+// @Summary Get blueprints
+// @Description Every blueprint in the catalogue, with its defaults, starter tasks and the cost, build time and production of each level.
+// @Tags blueprints
+// @Produce json
+// @Success 200 {array} catalog.BuildingType
+// @Router /blueprints [get]
+func (h *BlueprintHandler) ListBlueprints(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.buildingTypes.List())
+}
+
+// GetBlueprint godoc
+// @Summary Get blueprint by id
+// @Tags blueprints
+// @Produce json
+// @Param id path string true "Blueprint ID"
+// @Success 200 {object} catalog.BuildingType
+// @Failure 404 {string} string "Not Found"
+// @Router /blueprints/{id} [get]
+func (h *BlueprintHandler) GetBlueprint(w http.ResponseWriter, r *http.Request) {
+	blueprint, ok := h.buildingTypes.Lookup(chi.URLParam(r, "id"))
+	if !ok {
+		http.Error(w, "blueprint not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(blueprint)
+}
@@ -1,50 +1,97 @@
 package httpx
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/Stckrz/villageApi/internal/db/models"
 	"github.com/Stckrz/villageApi/internal/services"
+	"github.com/Stckrz/villageApi/internal/sim"
 	"github.com/go-chi/chi/v5"
 	"gorm.io/gorm"
 )
 
 type BuildingHandler struct {
 	service services.BuildingService
-	db      *gorm.DB
 }
 
-func NewBuildingHandler(db *gorm.DB, service services.BuildingService) *BuildingHandler {
+func NewBuildingHandler(service services.BuildingService) *BuildingHandler {
 	return &BuildingHandler{
-		db:      db,
 		service: service,
 	}
 }
 
 type CreateBuildingRequest struct {
-	Name          string   `json:"name"`
-	Description   string   `json:"description"`
-	Categories    []string `json:"categories"`
-	ThumbnailPath string   `json:"thumbnailPath"`
-	ImagePath     string   `json:"imagePath"`
+	BlueprintID   string           `json:"blueprintId"`
+	Name          string           `json:"name"`
+	Description   string           `json:"description"`
+	Categories    []string         `json:"categories"`
+	ThumbnailPath string           `json:"thumbnailPath"`
+	ImagePath     string           `json:"imagePath"`
+	VillageID     *uint            `json:"villageId"`
+	Rates         map[string]int64 `json:"rates"`
+	X             int              `json:"x"`
+	Y             int              `json:"y"`
+	Width         int              `json:"width"`
+	Height        int              `json:"height"`
+	Rotation      int              `json:"rotation"`
+	// Tasks are created with the building, in the same transaction.
+	Tasks []CreateBuildingTaskRequest `json:"tasks"`
 }
 
+type CreateBuildingTaskRequest struct {
+	Name             string `json:"name"`
+	Description      string `json:"description"`
+	EstimatedMinutes uint   `json:"estimatedMinutes"`
+	IsCompleted      bool   `json:"isCompleted"`
+}
+
+// UpdateBuildingRequest replaces a building's fields. villageId and rates are
+// kept as they are when left out; send null or {} to clear them.
 type UpdateBuildingRequest struct {
-	Name          string   `json:"name"`
-	Description   string   `json:"description"`
-	Categories    []string `json:"categories"`
-	ThumbnailPath string   `json:"thumbnailPath"`
-	ImagePath     string   `json:"imagePath"`
+	Name          string           `json:"name"`
+	Description   string           `json:"description"`
+	Categories    []string         `json:"categories"`
+	ThumbnailPath string           `json:"thumbnailPath"`
+	ImagePath     string           `json:"imagePath"`
+	VillageID     *uint            `json:"villageId"`
+	Rates         map[string]int64 `json:"rates"`
+	X             int              `json:"x"`
+	Y             int              `json:"y"`
+	Width         int              `json:"width"`
+	Height        int              `json:"height"`
+	Rotation      int              `json:"rotation"`
+}
+
+type CloneBuildingRequest struct {
+	Name string `json:"name"`
+	X    *int   `json:"x"`
+	Y    *int   `json:"y"`
+}
+
+type MergeBuildingRequest struct {
+	SourceID uint `json:"sourceId"`
 }
 
 // GetBuildingById godoc
 // @Summary Get building by id
+// @Description Without include or fields the building comes with every field and relation. Once either is given, only the fields listed (all if fields is left out) and the relations included (none if include is left out) are read and sent, along with the id. Including summary adds the task counts without reading the tasks.
 // @Tags buildings
 // @Produce json
 // @Param id path int true "Building ID"
-// @Success 200 {Building} models.Building
+// @Param include query string false "Comma separated relations to include: categories, rates, tasks, summary"
+// @Param fields query string false "Comma separated fields to send, e.g. name,thumbnailPath"
+// @Success 200 {object} BuildingResponse
+// @Failure 400 {string} string "Bad Request"
+// @Failure 404 {string} string "Not Found"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
 // @Router /buildings/{id} [get]
 func (h *BuildingHandler) GetBuilding(w http.ResponseWriter, r *http.Request) {
 
@@ -56,40 +103,162 @@ func (h *BuildingHandler) GetBuilding(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	users, err := h.service.GetBuildingByID(uint(idInt))
+	filter := services.BuildingFilter{ID: uint(idInt), View: parseBuildingView(r)}
+	building, err := h.service.GetBuilding(r.Context(), filter.ID, filter.View)
+	if err != nil {
+		if requestEnded(w, r) {
+			return
+		}
+		switch {
+		case errors.Is(err, services.ErrUnknownField),
+			errors.Is(err, services.ErrUnknownRelation):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			http.Error(w, "building not found", http.StatusNotFound)
+		default:
+			serverError(w, r, "failed to fetch building", err)
+		}
+		return
+	}
+
+	if filter.View.Full() {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(newBuildingResponse(building))
+		return
+	}
+	responses, err := h.viewResponses(r.Context(), []models.Building{building}, filter)
 	if err != nil {
-		http.Error(w, "failed to fetch building", http.StatusInternalServerError)
+		if requestEnded(w, r) {
+			return
+		}
+		serverError(w, r, "failed to fetch building", err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(users)
+	json.NewEncoder(w).Encode(responses[0])
 }
 
 // GetBuildings godoc
 // @Summary Get buildings
+// @Description Without include or fields every building comes with every field and relation. Once either is given, only the fields listed (all if fields is left out) and the relations included (none if include is left out) are read and sent, along with the id. Including summary adds the task counts without reading the tasks.
 // @Tags buildings
 // @Produce json
-// @Success 200 {array} models.Building
+// @Param category query []string false "Only buildings carrying every listed category, matched case-insensitively" collectionFormat(multi)
+// @Param bbox query string false "Viewport x1,y1,x2,y2; only placed buildings whose footprint intersects it"
+// @Param include query string false "Comma separated relations to include: categories, rates, tasks, summary"
+// @Param fields query string false "Comma separated fields to send, e.g. name,thumbnailPath"
+// @Success 200 {array} BuildingResponse
+// @Failure 400 {string} string "Bad Request"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
 // @Router /buildings [get]
 func (h *BuildingHandler) ListBuildings(w http.ResponseWriter, r *http.Request) {
-	users, err := h.service.ListBuildings()
+	filter, err := parseBuildingFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	buildings, err := h.service.ListBuildings(r.Context(), filter)
 	if err != nil {
-		http.Error(w, "failed to fetch users", http.StatusInternalServerError)
+		if requestEnded(w, r) {
+			return
+		}
+		switch {
+		case errors.Is(err, services.ErrUnknownField),
+			errors.Is(err, services.ErrUnknownRelation):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			serverError(w, r, "failed to fetch users", err)
+		}
+		return
+	}
+
+	if filter.View.Full() {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(newBuildingResponses(buildings))
+		return
+	}
+	responses, err := h.viewResponses(r.Context(), buildings, filter)
+	if err != nil {
+		if requestEnded(w, r) {
+			return
+		}
+		serverError(w, r, "failed to fetch users", err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(users)
+	json.NewEncoder(w).Encode(responses)
+}
+
+// SummarizeBuildings godoc
+// @Summary Summarize buildings
+// @Description Counts each building's open, completed and overdue tasks and reports when one last changed, without reading the tasks. An open task is overdue once its estimate has run out since it was created.
+// @Tags buildings
+// @Produce json
+// @Param category query []string false "Only buildings carrying every listed category, matched case-insensitively" collectionFormat(multi)
+// @Param bbox query string false "Viewport x1,y1,x2,y2; only placed buildings whose footprint intersects it"
+// @Success 200 {array} BuildingSummaryResponse
+// @Failure 400 {string} string "Bad Request"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Router /buildings/summary [get]
+func (h *BuildingHandler) SummarizeBuildings(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseBuildingFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	summaries, err := h.service.SummarizeBuildings(r.Context(), filter)
+	if err != nil {
+		if requestEnded(w, r) {
+			return
+		}
+		serverError(w, r, "failed to summarize buildings", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newBuildingSummaryResponses(summaries))
+}
+
+// viewResponses cuts buildings down to the filter's view, attaching their
+// summaries when it includes them.
+func (h *BuildingHandler) viewResponses(ctx context.Context, buildings []models.Building, filter services.BuildingFilter) ([]map[string]json.RawMessage, error) {
+	summaries := map[uint]services.BuildingSummary{}
+	if filter.View.Summarized() {
+		listed, err := h.service.SummarizeBuildings(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		for _, summary := range listed {
+			summaries[summary.BuildingID] = summary
+		}
+	}
+
+	responses := make([]map[string]json.RawMessage, 0, len(buildings))
+	for _, building := range buildings {
+		response := newBuildingResponse(building)
+		if summary, ok := summaries[building.ID]; ok {
+			summaryResponse := newBuildingSummaryResponse(summary)
+			response.Summary = &summaryResponse
+		}
+		responses = append(responses, newBuildingViewResponse(response, filter.View))
+	}
+	return responses, nil
 }
 
 // @CreateBuilding godoc
 // @Summary Create new building
+// @Description Creates the building with its categories and any nested tasks in one transaction and returns it with everything attached. With a blueprintId, blank fields are filled from the blueprint and its default categories and starter tasks are added. If the blueprint has levels, the first level's cost is reserved from the village and the building stays under construction until its construction tasks are completed.
 // @Tags buildings
 // @Produce application/json
 // @Param request body CreateBuildingRequest true "Create building payload"
-// @Success 200 {object} map[string]string
-// @Failure 500 {string} string "Internal Service Error"
+// @Success 200 {object} BuildingResponse
+// @Failure 400 {string} string "Bad Request"
+// @Failure 409 {string} string "Insufficient Stock or Overlapping Placement"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
 // @Router /buildings [post]
 func (h *BuildingHandler) CreateBuilding(w http.ResponseWriter, r *http.Request) {
 
@@ -101,31 +270,62 @@ func (h *BuildingHandler) CreateBuilding(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	categories := make([]models.BuildingCategory, 0, len(body.Categories))
+	categories := make([]models.Category, 0, len(body.Categories))
 
-	for _, text := range body.Categories {
-		categories = append(categories, models.BuildingCategory{
-			Text: text,
+	for _, name := range body.Categories {
+		categories = append(categories, models.Category{
+			Name: name,
+		})
+	}
+	tasks := make([]models.Task, 0, len(body.Tasks))
+	for _, task := range body.Tasks {
+		tasks = append(tasks, models.Task{
+			Name:             task.Name,
+			Description:      task.Description,
+			EstimatedMinutes: task.EstimatedMinutes,
+			IsCompleted:      task.IsCompleted,
 		})
 	}
 	building := models.Building{
 		Name:          body.Name,
 		Description:   body.Description,
 		Categories:    categories,
+		Tasks:         tasks,
 		ImagePath:     body.ImagePath,
 		ThumbnailPath: body.ThumbnailPath,
+		VillageID:     body.VillageID,
+		Rates:         buildingRates(body.Rates),
+		X:             body.X,
+		Y:             body.Y,
+		Width:         body.Width,
+		Height:        body.Height,
+		Rotation:      body.Rotation,
+		Type:          body.BlueprintID,
 	}
 
-	building, err := h.service.CreateBuilding(building)
+	building, err := h.service.CreateBuilding(r.Context(), building)
 	if err != nil {
-		switch err {
+		if requestEnded(w, r) {
+			return
+		}
+		switch {
+		case errors.Is(err, services.ErrUnknownResource),
+			errors.Is(err, services.ErrUnknownBuildingType),
+			errors.Is(err, services.ErrVillageRequired),
+			errors.Is(err, services.ErrVillageNotFound),
+			errors.Is(err, services.ErrInvalidTask):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, services.ErrInvalidPlacement):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, sim.ErrInsufficientStock), errors.Is(err, services.ErrOverlap):
+			http.Error(w, err.Error(), http.StatusConflict)
 		default:
-			http.Error(w, "failed to create building", http.StatusInternalServerError)
+			serverError(w, r, "failed to create building", err)
 		}
 		return
 	}
 
-	json.NewEncoder(w).Encode(building)
+	json.NewEncoder(w).Encode(newBuildingResponse(building))
 	return
 }
 
@@ -135,7 +335,7 @@ func (h *BuildingHandler) CreateBuilding(w http.ResponseWriter, r *http.Request)
 // @Produce application/json
 // @Param id path int true "Building ID"
 // @Success 200 {object} map[string]string
-// @Failure 500 {string} string "Internal Service Error"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
 // @Router /buildings/{id} [delete]
 func (h *BuildingHandler) DeleteBuilding(w http.ResponseWriter, r *http.Request) {
 	idParam := chi.URLParam(r, "id")
@@ -146,8 +346,11 @@ func (h *BuildingHandler) DeleteBuilding(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if err := h.service.DeleteBuilding(uint(idInt)); err != nil {
-		http.Error(w, "failed to delete building", http.StatusInternalServerError)
+	if err := h.service.DeleteBuilding(r.Context(), uint(idInt)); err != nil {
+		if requestEnded(w, r) {
+			return
+		}
+		serverError(w, r, "failed to delete building", err)
 		return
 	}
 
@@ -156,12 +359,15 @@ func (h *BuildingHandler) DeleteBuilding(w http.ResponseWriter, r *http.Request)
 
 // @UpdateBuilding godoc
 // @Summary Update a building
+// @Description Replaces the building's fields and categories. villageId and rates are only changed when sent, so clients that predate them don't detach buildings or drop their rates.
 // @Tags buildings
 // @Produce application/json
 // @Param id path int true "Building ID"
 // @Param request body UpdateBuildingRequest true "Update building payload"
 // @Success 200 {object} map[string]string
-// @Failure 500 {string} string "Internal Service Error"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 409 {string} string "Overlapping Placement"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
 // @Router /buildings/{id} [put]
 func (h *BuildingHandler) UpdateBuilding(w http.ResponseWriter, r *http.Request) {
 	idParam := chi.URLParam(r, "id")
@@ -172,17 +378,28 @@ func (h *BuildingHandler) UpdateBuilding(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// decoded twice: once for the values, once to see which fields were sent
 	var body UpdateBuildingRequest
-	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+	var sent map[string]json.RawMessage
+	data, err := io.ReadAll(r.Body)
+	if err == nil {
+		err = json.Unmarshal(data, &body)
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &sent)
+	}
+	if err != nil {
 		http.Error(w, "invalid body", http.StatusBadRequest)
 		return
 	}
+	_, setVillage := sent["villageId"]
+	_, setRates := sent["rates"]
 
-	categories := make([]models.BuildingCategory, 0, len(body.Categories))
+	categories := make([]models.Category, 0, len(body.Categories))
 
-	for _, text := range body.Categories {
-		categories = append(categories, models.BuildingCategory{
-			Text: text,
+	for _, name := range body.Categories {
+		categories = append(categories, models.Category{
+			Name: name,
 		})
 	}
 
@@ -192,12 +409,265 @@ func (h *BuildingHandler) UpdateBuilding(w http.ResponseWriter, r *http.Request)
 		Categories:    categories,
 		ImagePath:     body.ImagePath,
 		ThumbnailPath: body.ThumbnailPath,
+		VillageID:     body.VillageID,
+		Rates:         buildingRates(body.Rates),
+		X:             body.X,
+		Y:             body.Y,
+		Width:         body.Width,
+		Height:        body.Height,
+		Rotation:      body.Rotation,
 	}
 
-	if err := h.service.UpdateBuilding(building, uint(idInt)); err != nil {
-		http.Error(w, "failed to delete building", http.StatusInternalServerError)
+	options := services.UpdateOptions{SetVillage: setVillage, SetRates: setRates}
+	if err := h.service.UpdateBuilding(r.Context(), building, uint(idInt), options); err != nil {
+		if requestEnded(w, r) {
+			return
+		}
+		switch {
+		case errors.Is(err, services.ErrUnknownResource),
+			errors.Is(err, services.ErrVillageRequired),
+			errors.Is(err, services.ErrVillageNotFound),
+			errors.Is(err, services.ErrInvalidPlacement):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, services.ErrOverlap):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			serverError(w, r, "failed to delete building", err)
+		}
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// @UpgradeBuilding godoc
+// @Summary Upgrade a building
+// @Description Reserves the next level's cost and spawns its construction tasks. The building is active at the new level once they are completed.
+// @Tags buildings
+// @Produce application/json
+// @Param id path int true "Building ID"
+// @Success 200 {object} BuildingResponse
+// @Failure 404 {string} string "Not Found"
+// @Failure 409 {string} string "Conflict"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Router /buildings/{id}/upgrade [post]
+func (h *BuildingHandler) UpgradeBuilding(w http.ResponseWriter, r *http.Request) {
+	idParam := chi.URLParam(r, "id")
+
+	idInt, err := strconv.Atoi(idParam)
+	if err != nil || idInt <= 0 {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	building, err := h.service.UpgradeBuilding(r.Context(), uint(idInt))
+	if err != nil {
+		if requestEnded(w, r) {
+			return
+		}
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			http.Error(w, "building not found", http.StatusNotFound)
+		case errors.Is(err, services.ErrNotUpgradable),
+			errors.Is(err, services.ErrMaxLevel),
+			errors.Is(err, services.ErrUnderConstruction),
+			errors.Is(err, sim.ErrInsufficientStock):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			serverError(w, r, "failed to upgrade building", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newBuildingResponse(building))
+}
+
+// @CloneBuilding godoc
+// @Summary Clone a building
+// @Description Creates a copy with the same village, description, images, categories and rates and a fresh copy of every open chore. Without x and y the copy is kept off the grid. A blueprint building with levels starts construction of level 1, reserving its cost.
+// @Tags buildings
+// @Produce application/json
+// @Param id path int true "Building ID"
+// @Param request body CloneBuildingRequest false "Clone options"
+// @Success 200 {object} BuildingResponse
+// @Failure 400 {string} string "Bad Request"
+// @Failure 404 {string} string "Not Found"
+// @Failure 409 {string} string "Insufficient Stock or Overlapping Placement"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Router /buildings/{id}/clone [post]
+func (h *BuildingHandler) CloneBuilding(w http.ResponseWriter, r *http.Request) {
+	idParam := chi.URLParam(r, "id")
+
+	idInt, err := strconv.Atoi(idParam)
+	if err != nil || idInt <= 0 {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	// every option has a default, so the body may be left out
+	var body CloneBuildingRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+	if (body.X == nil) != (body.Y == nil) {
+		http.Error(w, "x and y go together", http.StatusBadRequest)
+		return
+	}
+
+	building, err := h.service.CloneBuilding(r.Context(), uint(idInt), services.CloneOptions{
+		Name: strings.TrimSpace(body.Name),
+		X:    body.X,
+		Y:    body.Y,
+	})
+	if err != nil {
+		if requestEnded(w, r) {
+			return
+		}
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			http.Error(w, "building not found", http.StatusNotFound)
+		case errors.Is(err, services.ErrVillageRequired),
+			errors.Is(err, services.ErrInvalidPlacement):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, sim.ErrInsufficientStock), errors.Is(err, services.ErrOverlap):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			serverError(w, r, "failed to clone building", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newBuildingResponse(building))
+}
+
+// @MergeBuilding godoc
+// @Summary Merge another building into this one
+// @Description Moves the source building's chores and categories to this building and deletes the source, with its rates and road segments. A source under construction is refused.
+// @Tags buildings
+// @Produce application/json
+// @Param id path int true "Building ID to merge into"
+// @Param request body MergeBuildingRequest true "Building to merge away"
+// @Success 200 {object} BuildingResponse
+// @Failure 400 {string} string "Bad Request"
+// @Failure 404 {string} string "Not Found"
+// @Failure 409 {string} string "Under Construction"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Router /buildings/{id}/merge [post]
+func (h *BuildingHandler) MergeBuilding(w http.ResponseWriter, r *http.Request) {
+	idParam := chi.URLParam(r, "id")
+
+	idInt, err := strconv.Atoi(idParam)
+	if err != nil || idInt <= 0 {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	var body MergeBuildingRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.SourceID == 0 {
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+
+	building, err := h.service.MergeBuilding(r.Context(), uint(idInt), body.SourceID)
+	if err != nil {
+		if requestEnded(w, r) {
+			return
+		}
+		switch {
+		case errors.Is(err, services.ErrMergeBuildingIntoItself):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			http.Error(w, "building not found", http.StatusNotFound)
+		case errors.Is(err, services.ErrMergeUnderConstruction):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			serverError(w, r, "failed to merge building", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newBuildingResponse(building))
+}
+
+// buildingRates turns the request's resource -> per-tick map into rate rows,
+// sorted so they are stored in a stable order.
+func buildingRates(rates map[string]int64) []models.BuildingRate {
+	resources := make([]string, 0, len(rates))
+	for resource := range rates {
+		resources = append(resources, resource)
+	}
+	sort.Strings(resources)
+
+	buildingRates := make([]models.BuildingRate, 0, len(rates))
+	for _, resource := range resources {
+		buildingRates = append(buildingRates, models.BuildingRate{
+			Resource: resource,
+			PerTick:  rates[resource],
+		})
+	}
+	return buildingRates
+}
+
+// parseBuildingFilter reads the category, bbox, include and fields parameters.
+func parseBuildingFilter(r *http.Request) (services.BuildingFilter, error) {
+	filter := services.BuildingFilter{
+		Categories: r.URL.Query()["category"],
+		View:       parseBuildingView(r),
+	}
+	if bbox := r.URL.Query().Get("bbox"); bbox != "" {
+		box, err := parseBoundingBox(bbox)
+		if err != nil {
+			return services.BuildingFilter{}, errors.New("invalid bbox")
+		}
+		filter.BoundingBox = &box
+	}
+	return filter, nil
+}
+
+// parseBuildingView reads the include and fields parameters, each a comma
+// separated list that may also be repeated. A parameter that isn't given stays
+// nil, one given empty is an empty list.
+func parseBuildingView(r *http.Request) services.BuildingView {
+	list := func(key string) []string {
+		values, ok := r.URL.Query()[key]
+		if !ok {
+			return nil
+		}
+		names := []string{}
+		for _, value := range values {
+			for _, name := range strings.Split(value, ",") {
+				if name = strings.TrimSpace(name); name != "" {
+					names = append(names, name)
+				}
+			}
+		}
+		return names
+	}
+	return services.BuildingView{Include: list("include"), Fields: list("fields")}
+}
+
+// parseBoundingBox reads "x1,y1,x2,y2", accepting the corners in either order.
+func parseBoundingBox(value string) (services.BoundingBox, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 4 {
+		return services.BoundingBox{}, fmt.Errorf("bbox needs 4 values, got %d", len(parts))
+	}
+	var coordinates [4]int
+	for index, part := range parts {
+		coordinate, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return services.BoundingBox{}, err
+		}
+		coordinates[index] = coordinate
+	}
+	return services.BoundingBox{
+		X1: min(coordinates[0], coordinates[2]),
+		Y1: min(coordinates[1], coordinates[3]),
+		X2: max(coordinates[0], coordinates[2]),
+		Y2: max(coordinates[1], coordinates[3]),
+	}, nil
+}
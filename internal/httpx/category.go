@@ -0,0 +1,139 @@
+package httpx
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Stckrz/villageApi/internal/services"
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+type CategoryHandler struct {
+	service services.CategoryService
+}
+
+func NewCategoryHandler(service services.CategoryService) *CategoryHandler {
+	return &CategoryHandler{
+		service: service,
+	}
+}
+
+type RenameCategoryRequest struct {
+	Name string `json:"name"`
+}
+
+type MergeCategoryRequest struct {
+	TargetID uint `json:"targetId"`
+}
+
+// GetCategories godoc
+// @Summary Get categories
+// @Description Every category with the number of buildings carrying it.
+// @Tags categories
+// @Produce json
+// @Success 200 {array} services.CategoryCount
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Router /categories [get]
+func (h *CategoryHandler) ListCategories(w http.ResponseWriter, r *http.Request) {
+	categories, err := h.service.ListCategories(r.Context())
+	if err != nil {
+		serverError(w, r, "failed to fetch categories", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(categories)
+}
+
+// @RenameCategory godoc
+// @Summary Rename a category
+// @Tags categories
+// @Produce application/json
+// @Param id path int true "Category ID"
+// @Param request body RenameCategoryRequest true "Rename category payload"
+// @Success 200 {object} CategoryResponse
+// @Failure 400 {string} string "Bad Request"
+// @Failure 404 {string} string "Not Found"
+// @Failure 409 {string} string "Name Taken"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Router /categories/{id} [put]
+func (h *CategoryHandler) RenameCategory(w http.ResponseWriter, r *http.Request) {
+	idParam := chi.URLParam(r, "id")
+
+	idInt, err := strconv.Atoi(idParam)
+	if err != nil || idInt <= 0 {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	var body RenameCategoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+
+	category, err := h.service.RenameCategory(r.Context(), uint(idInt), body.Name)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidCategory):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, services.ErrCategoryExists):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			http.Error(w, "category not found", http.StatusNotFound)
+		default:
+			serverError(w, r, "failed to rename category", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newCategoryResponse(category))
+}
+
+// @MergeCategory godoc
+// @Summary Merge a category into another
+// @Description Moves every building from this category to the target category and deletes this one.
+// @Tags categories
+// @Produce application/json
+// @Param id path int true "Category ID to merge away"
+// @Param request body MergeCategoryRequest true "Merge target"
+// @Success 200 {object} CategoryResponse
+// @Failure 400 {string} string "Bad Request"
+// @Failure 404 {string} string "Not Found"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Router /categories/{id}/merge [post]
+func (h *CategoryHandler) MergeCategory(w http.ResponseWriter, r *http.Request) {
+	idParam := chi.URLParam(r, "id")
+
+	idInt, err := strconv.Atoi(idParam)
+	if err != nil || idInt <= 0 {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	var body MergeCategoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.TargetID == 0 {
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+
+	category, err := h.service.MergeCategory(r.Context(), uint(idInt), body.TargetID)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrMergeIntoItself):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			http.Error(w, "category not found", http.StatusNotFound)
+		default:
+			serverError(w, r, "failed to merge category", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newCategoryResponse(category))
+}
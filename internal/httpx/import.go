@@ -0,0 +1,91 @@
+package httpx
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/Stckrz/villageApi/internal/services"
+	"github.com/Stckrz/villageApi/internal/tiled"
+)
+
+// maxMapSize caps the size of an uploaded map file.
+const maxMapSize = 10 << 20
+
+type ImportHandler struct {
+	service services.ImportService
+}
+
+func NewImportHandler(service services.ImportService) *ImportHandler {
+	return &ImportHandler{
+		service: service,
+	}
+}
+
+// @ImportTiled godoc
+// @Summary Import buildings from a Tiled map
+// @Description The body is a Tiled map, JSON or TMX. Every named object of its object layers becomes a building of the village, matched by name; nothing is written unless commit is true, so the default response is a dry-run diff.
+// @Tags import
+// @Accept json
+// @Accept xml
+// @Produce application/json
+// @Param villageId query int false "Village to import into"
+// @Param commit query bool false "Apply the import instead of only reporting it"
+// @Success 200 {object} services.ImportReport
+// @Failure 400 {string} string "Bad Request"
+// @Failure 409 {string} string "Conflict"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Router /import/tiled [post]
+func (h *ImportHandler) ImportTiled(w http.ResponseWriter, r *http.Request) {
+	var villageID *uint
+	if villageParam := r.URL.Query().Get("villageId"); villageParam != "" {
+		villageInt, err := strconv.Atoi(villageParam)
+		if err != nil || villageInt <= 0 {
+			http.Error(w, "invalid villageId", http.StatusBadRequest)
+			return
+		}
+		id := uint(villageInt)
+		villageID = &id
+	}
+	commit := false
+	if commitParam := r.URL.Query().Get("commit"); commitParam != "" {
+		var err error
+		if commit, err = strconv.ParseBool(commitParam); err != nil {
+			http.Error(w, "invalid commit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	contents, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxMapSize))
+	if err != nil {
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+	tiledMap, err := tiled.Parse(contents)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report, err := h.service.ImportTiled(r.Context(), villageID, tiledMap, commit)
+	if err != nil {
+		if requestEnded(w, r) {
+			return
+		}
+		switch {
+		case errors.Is(err, services.ErrVillageNotFound),
+			errors.Is(err, services.ErrDuplicateImportName):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, services.ErrOverlap):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			serverError(w, r, "failed to import map", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
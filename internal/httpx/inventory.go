@@ -0,0 +1,111 @@
+package httpx
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Stckrz/villageApi/internal/services"
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+type InventoryHandler struct {
+	service services.InventoryService
+}
+
+func NewInventoryHandler(service services.InventoryService) *InventoryHandler {
+	return &InventoryHandler{
+		service: service,
+	}
+}
+
+// GetStock godoc
+// @Summary Get village stock levels
+// @Description Current amount of every resource, summed from the inventory ledger.
+// @Tags inventory
+// @Produce json
+// @Param id path int true "Village ID"
+// @Success 200 {array} services.StockLevel
+// @Failure 404 {string} string "Not Found"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Router /villages/{id}/inventory [get]
+func (h *InventoryHandler) GetStock(w http.ResponseWriter, r *http.Request) {
+	idParam := chi.URLParam(r, "id")
+
+	idInt, err := strconv.Atoi(idParam)
+	if err != nil || idInt <= 0 {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	levels, err := h.service.GetStock(r.Context(), uint(idInt))
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			http.Error(w, "village not found", http.StatusNotFound)
+		default:
+			serverError(w, r, "failed to fetch inventory", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(levels)
+}
+
+// ListHistory godoc
+// @Summary Get village inventory ledger
+// @Description Ledger entries newest first.
+// @Tags inventory
+// @Produce json
+// @Param id path int true "Village ID"
+// @Param resource query string false "Only entries for this resource"
+// @Param limit query int false "Page size, default 100, max 500"
+// @Param offset query int false "Entries to skip"
+// @Success 200 {array} InventoryEntryResponse
+// @Failure 400 {string} string "Bad Request"
+// @Failure 404 {string} string "Not Found"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Router /villages/{id}/inventory/history [get]
+func (h *InventoryHandler) ListHistory(w http.ResponseWriter, r *http.Request) {
+	idParam := chi.URLParam(r, "id")
+
+	idInt, err := strconv.Atoi(idParam)
+	if err != nil || idInt <= 0 {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := services.InventoryHistoryFilter{Resource: query.Get("resource")}
+	if limit := query.Get("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+	if offset := query.Get("offset"); offset != "" {
+		if filter.Offset, err = strconv.Atoi(offset); err != nil || filter.Offset < 0 {
+			http.Error(w, "invalid offset", http.StatusBadRequest)
+			return
+		}
+	}
+
+	entries, err := h.service.ListHistory(r.Context(), uint(idInt), filter)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrUnknownResource):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			http.Error(w, "village not found", http.StatusNotFound)
+		default:
+			serverError(w, r, "failed to fetch inventory history", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newInventoryEntryResponses(entries))
+}
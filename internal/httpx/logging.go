@@ -0,0 +1,158 @@
+package httpx
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/Stckrz/villageApi/internal/tracing"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// RequestIDHeader carries the request id. A client may send its own, which is
+// kept if it looks sane; either way it is echoed on the response.
+const RequestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// RequestID returns the id withRequestID gave the request, "" outside one.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// Logger is the default logger with the request and trace ids attached.
+func Logger(ctx context.Context) *slog.Logger {
+	logger := slog.Default()
+	if id := RequestID(ctx); id != "" {
+		logger = logger.With("request_id", id)
+	}
+	if traceID := tracing.TraceID(ctx); traceID != "" {
+		logger = logger.With("trace_id", traceID)
+	}
+	return logger
+}
+
+// serverError logs why a request failed, which the client isn't told, and
+// writes a 500 with message.
+func serverError(w http.ResponseWriter, r *http.Request, message string, err error) {
+	Logger(r.Context()).Error(message, "error", err)
+	writeErrorResponse(w, r, http.StatusInternalServerError, message)
+}
+
+// withRequestID gives every request an id, puts it on the context and sets it
+// on the response before anything is written, so errors carry it too.
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+func newRequestID() string {
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// validRequestID accepts short ids of letters, digits, dashes, underscores and
+// dots, which keeps client ids safe to log and echo.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// withAccessLog logs every request once it is done, with its route pattern,
+// status, size and latency.
+func withAccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started := time.Now()
+		recorder := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(recorder, r)
+
+		status := recorder.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		route := routePattern(r)
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		Logger(r.Context()).LogAttrs(r.Context(), level, "request",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("route", route),
+			slog.Int("status", status),
+			slog.Int("bytes", recorder.BytesWritten()),
+			slog.Duration("duration", time.Since(started)),
+			slog.String("remote", r.RemoteAddr),
+		)
+	})
+}
+
+// ErrorResponse is what a request that failed on the server's side gets back.
+// The ids let a client point at the logs and trace of its request.
+type ErrorResponse struct {
+	Error     string `json:"error"`
+	RequestID string `json:"requestId"`
+	TraceID   string `json:"traceId,omitempty"`
+}
+
+func writeErrorResponse(w http.ResponseWriter, r *http.Request, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(ErrorResponse{
+		Error:     message,
+		RequestID: RequestID(r.Context()),
+		TraceID:   tracing.TraceID(r.Context()),
+	})
+}
+
+// withRecovery turns a panicking handler into a logged stack trace and a JSON
+// 500 carrying the request id, instead of a dropped connection. If the handler
+// already started its response, only the log is left to do.
+func withRecovery(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorder := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			// the server uses this one to abort a response on purpose
+			if err, ok := recovered.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+				panic(recovered)
+			}
+			Logger(r.Context()).Error("panic",
+				slog.Any("panic", recovered),
+				slog.String("stack", string(debug.Stack())),
+			)
+			if recorder.Status() != 0 {
+				return
+			}
+			writeErrorResponse(w, r, http.StatusInternalServerError, "internal server error")
+		}()
+		next.ServeHTTP(recorder, r)
+	})
+}
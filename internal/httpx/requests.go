@@ -0,0 +1,65 @@
+package httpx
+
+import "encoding/json"
+
+// Request bodies use camelCase keys, like the responses. A few keys were
+// snake_case before that; they are still accepted while clients move over, the
+// camelCase key winning when a body sends both. The legacy keys aren't in the
+// Swagger docs.
+
+var legacyTaskKeys = map[string]string{
+	"building_id":  "buildingId",
+	"is_completed": "isCompleted",
+}
+
+func (r *CreateTaskRequest) UnmarshalJSON(data []byte) error {
+	type plain CreateTaskRequest
+	return decodeLegacyKeys(data, (*plain)(r), legacyTaskKeys)
+}
+
+func (r *UpdateTaskRequest) UnmarshalJSON(data []byte) error {
+	type plain UpdateTaskRequest
+	return decodeLegacyKeys(data, (*plain)(r), legacyTaskKeys)
+}
+
+func (r *CreateBuildingRequest) UnmarshalJSON(data []byte) error {
+	type plain CreateBuildingRequest
+	return decodeLegacyKeys(data, (*plain)(r), map[string]string{"blueprint_id": "blueprintId"})
+}
+
+func (r *CreateBuildingTaskRequest) UnmarshalJSON(data []byte) error {
+	type plain CreateBuildingTaskRequest
+	return decodeLegacyKeys(data, (*plain)(r), map[string]string{
+		"estimated_minutes": "estimatedMinutes",
+		"is_completed":      "isCompleted",
+	})
+}
+
+// decodeLegacyKeys decodes data into target after renaming the legacy keys to
+// their current names.
+func decodeLegacyKeys(data []byte, target any, legacy map[string]string) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil || fields == nil {
+		return json.Unmarshal(data, target)
+	}
+	renamed := false
+	for old, current := range legacy {
+		value, ok := fields[old]
+		if !ok {
+			continue
+		}
+		if _, ok := fields[current]; !ok {
+			fields[current] = value
+		}
+		delete(fields, old)
+		renamed = true
+	}
+	if !renamed {
+		return json.Unmarshal(data, target)
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, target)
+}
@@ -0,0 +1,290 @@
+package httpx
+
+import (
+	"encoding/json"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/Stckrz/villageApi/internal/db/models"
+	"github.com/Stckrz/villageApi/internal/services"
+)
+
+// The API never encodes gorm models directly; handlers convert them to the
+// response types below, so storage details (join tables, back references,
+// gorm bookkeeping) stay out of the JSON. The rules every response follows:
+//
+//   - field names are camelCase, like the request bodies
+//   - ids of optional relations (a building's village, a road end's building)
+//     and unset timestamps are left out rather than sent as null
+//   - lists a response always carries are sent as [] when empty, never null
+//   - related objects are nested only where the parent owns them: a building
+//     carries its categories, rates and tasks, a task carries only its
+//     buildingId
+//   - a building read with include or fields carries only the id and the keys
+//     asked for; see newBuildingViewResponse
+
+type BuildingResponse struct {
+	ID            uint               `json:"id"`
+	VillageID     *uint              `json:"villageId,omitempty"`
+	Name          string             `json:"name"`
+	Description   string             `json:"description"`
+	Type          string             `json:"type"`
+	ThumbnailPath string             `json:"thumbnailPath"`
+	ImagePath     string             `json:"imagePath"`
+	Level         int                `json:"level"`
+	TargetLevel   int                `json:"targetLevel"`
+	Status        string             `json:"status"`
+	X             int                `json:"x"`
+	Y             int                `json:"y"`
+	Width         int                `json:"width"`
+	Height        int                `json:"height"`
+	Rotation      int                `json:"rotation"`
+	Categories    []CategoryResponse `json:"categories"`
+	// Rates maps a resource to what the building produces (positive) or
+	// consumes (negative) per tick, the same shape the requests take.
+	Rates     map[string]int64 `json:"rates"`
+	Tasks     []TaskResponse   `json:"tasks"`
+	CreatedAt time.Time        `json:"createdAt"`
+	UpdatedAt time.Time        `json:"updatedAt"`
+	// Summary is only sent when asked for with include=summary.
+	Summary *BuildingSummaryResponse `json:"summary,omitempty"`
+}
+
+type BuildingSummaryResponse struct {
+	BuildingID   uint       `json:"buildingId"`
+	Open         int64      `json:"open"`
+	Completed    int64      `json:"completed"`
+	Overdue      int64      `json:"overdue"`
+	LastActivity *time.Time `json:"lastActivity,omitempty"`
+}
+
+type CategoryResponse struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+type TaskResponse struct {
+	ID               uint       `json:"id"`
+	BuildingID       uint       `json:"buildingId"`
+	Name             string     `json:"name"`
+	Description      string     `json:"description"`
+	Kind             string     `json:"kind"`
+	EstimatedMinutes uint       `json:"estimatedMinutes"`
+	IsCompleted      bool       `json:"isCompleted"`
+	CompletedAt      *time.Time `json:"completedAt,omitempty"`
+	CreatedAt        time.Time  `json:"createdAt"`
+	UpdatedAt        time.Time  `json:"updatedAt"`
+}
+
+type VillageResponse struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	Seed      int64     `json:"seed"`
+	Tick      uint64    `json:"tick"`
+	StateHash string    `json:"stateHash"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type RoadEndResponse struct {
+	BuildingID *uint `json:"buildingId,omitempty"`
+	X          int   `json:"x"`
+	Y          int   `json:"y"`
+}
+
+type RoadResponse struct {
+	ID            uint            `json:"id"`
+	VillageID     *uint           `json:"villageId,omitempty"`
+	From          RoadEndResponse `json:"from"`
+	To            RoadEndResponse `json:"to"`
+	TravelMinutes float64         `json:"travelMinutes"`
+	CreatedAt     time.Time       `json:"createdAt"`
+	UpdatedAt     time.Time       `json:"updatedAt"`
+}
+
+type InventoryEntryResponse struct {
+	ID         uint      `json:"id"`
+	VillageID  uint      `json:"villageId"`
+	Resource   string    `json:"resource"`
+	Delta      int64     `json:"delta"`
+	Balance    int64     `json:"balance"`
+	Reason     string    `json:"reason"`
+	BuildingID *uint     `json:"buildingId,omitempty"`
+	Tick       uint64    `json:"tick"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+func newBuildingResponse(building models.Building) BuildingResponse {
+	rates := make(map[string]int64, len(building.Rates))
+	for _, rate := range building.Rates {
+		rates[rate.Resource] = rate.PerTick
+	}
+	return BuildingResponse{
+		ID:            building.ID,
+		VillageID:     building.VillageID,
+		Name:          building.Name,
+		Description:   building.Description,
+		Type:          building.Type,
+		ThumbnailPath: building.ThumbnailPath,
+		ImagePath:     building.ImagePath,
+		Level:         building.Level,
+		TargetLevel:   building.TargetLevel,
+		Status:        building.Status,
+		X:             building.X,
+		Y:             building.Y,
+		Width:         building.Width,
+		Height:        building.Height,
+		Rotation:      building.Rotation,
+		Categories:    newCategoryResponses(building.Categories),
+		Rates:         rates,
+		Tasks:         newTaskResponses(building.Tasks),
+		CreatedAt:     building.CreatedAt,
+		UpdatedAt:     building.UpdatedAt,
+	}
+}
+
+func newBuildingResponses(buildings []models.Building) []BuildingResponse {
+	responses := make([]BuildingResponse, 0, len(buildings))
+	for _, building := range buildings {
+		responses = append(responses, newBuildingResponse(building))
+	}
+	return responses
+}
+
+// newBuildingViewResponse is a building response cut down to what view reads:
+// the id, the fields listed (every field when none are) and the relations
+// included. Keys the full response leaves out stay out.
+func newBuildingViewResponse(building BuildingResponse, view services.BuildingView) map[string]json.RawMessage {
+	encoded, _ := json.Marshal(building)
+	var full map[string]json.RawMessage
+	_ = json.Unmarshal(encoded, &full)
+
+	keys := view.Fields
+	if keys == nil {
+		keys = slices.Collect(maps.Keys(services.BuildingFields))
+	}
+	response := map[string]json.RawMessage{"id": full["id"]}
+	for _, key := range append(slices.Clone(keys), view.Include...) {
+		if value, ok := full[key]; ok {
+			response[key] = value
+		}
+	}
+	return response
+}
+
+func newBuildingSummaryResponse(summary services.BuildingSummary) BuildingSummaryResponse {
+	return BuildingSummaryResponse{
+		BuildingID:   summary.BuildingID,
+		Open:         summary.Open,
+		Completed:    summary.Completed,
+		Overdue:      summary.Overdue,
+		LastActivity: summary.LastActivity,
+	}
+}
+
+func newBuildingSummaryResponses(summaries []services.BuildingSummary) []BuildingSummaryResponse {
+	responses := make([]BuildingSummaryResponse, 0, len(summaries))
+	for _, summary := range summaries {
+		responses = append(responses, newBuildingSummaryResponse(summary))
+	}
+	return responses
+}
+
+func newCategoryResponse(category models.Category) CategoryResponse {
+	return CategoryResponse{
+		ID:   category.ID,
+		Name: category.Name,
+		Slug: category.Slug,
+	}
+}
+
+func newCategoryResponses(categories []models.Category) []CategoryResponse {
+	responses := make([]CategoryResponse, 0, len(categories))
+	for _, category := range categories {
+		responses = append(responses, newCategoryResponse(category))
+	}
+	return responses
+}
+
+func newTaskResponse(task models.Task) TaskResponse {
+	return TaskResponse{
+		ID:               task.ID,
+		BuildingID:       task.BuildingId,
+		Name:             task.Name,
+		Description:      task.Description,
+		Kind:             task.Kind,
+		EstimatedMinutes: task.EstimatedMinutes,
+		IsCompleted:      task.IsCompleted,
+		CompletedAt:      task.CompletedAt,
+		CreatedAt:        task.CreatedAt,
+		UpdatedAt:        task.UpdatedAt,
+	}
+}
+
+func newTaskResponses(tasks []models.Task) []TaskResponse {
+	responses := make([]TaskResponse, 0, len(tasks))
+	for _, task := range tasks {
+		responses = append(responses, newTaskResponse(task))
+	}
+	return responses
+}
+
+func newVillageResponse(village models.Village) VillageResponse {
+	return VillageResponse{
+		ID:        village.ID,
+		Name:      village.Name,
+		Seed:      village.Seed,
+		Tick:      village.Tick,
+		StateHash: village.StateHash,
+		CreatedAt: village.CreatedAt,
+		UpdatedAt: village.UpdatedAt,
+	}
+}
+
+func newVillageResponses(villages []models.Village) []VillageResponse {
+	responses := make([]VillageResponse, 0, len(villages))
+	for _, village := range villages {
+		responses = append(responses, newVillageResponse(village))
+	}
+	return responses
+}
+
+func newRoadResponse(road models.RoadSegment) RoadResponse {
+	return RoadResponse{
+		ID:            road.ID,
+		VillageID:     road.VillageID,
+		From:          RoadEndResponse{BuildingID: road.FromBuildingID, X: road.FromX, Y: road.FromY},
+		To:            RoadEndResponse{BuildingID: road.ToBuildingID, X: road.ToX, Y: road.ToY},
+		TravelMinutes: road.TravelMinutes,
+		CreatedAt:     road.CreatedAt,
+		UpdatedAt:     road.UpdatedAt,
+	}
+}
+
+func newRoadResponses(roads []models.RoadSegment) []RoadResponse {
+	responses := make([]RoadResponse, 0, len(roads))
+	for _, road := range roads {
+		responses = append(responses, newRoadResponse(road))
+	}
+	return responses
+}
+
+func newInventoryEntryResponses(entries []models.InventoryEntry) []InventoryEntryResponse {
+	responses := make([]InventoryEntryResponse, 0, len(entries))
+	for _, entry := range entries {
+		responses = append(responses, InventoryEntryResponse{
+			ID:         entry.ID,
+			VillageID:  entry.VillageID,
+			Resource:   entry.Resource,
+			Delta:      entry.Delta,
+			Balance:    entry.Balance,
+			Reason:     entry.Reason,
+			BuildingID: entry.BuildingID,
+			Tick:       entry.Tick,
+			CreatedAt:  entry.CreatedAt,
+		})
+	}
+	return responses
+}
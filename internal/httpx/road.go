@@ -0,0 +1,224 @@
+package httpx
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Stckrz/villageApi/internal/db/models"
+	"github.com/Stckrz/villageApi/internal/services"
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+type RoadHandler struct {
+	service services.RoadService
+}
+
+func NewRoadHandler(service services.RoadService) *RoadHandler {
+	return &RoadHandler{
+		service: service,
+	}
+}
+
+// RoadEndRequest is one end of a road: a building, or a tile when buildingId is empty.
+type RoadEndRequest struct {
+	BuildingID *uint `json:"buildingId"`
+	X          int   `json:"x"`
+	Y          int   `json:"y"`
+}
+
+type CreateRoadRequest struct {
+	VillageID *uint          `json:"villageId"`
+	From      RoadEndRequest `json:"from"`
+	To        RoadEndRequest `json:"to"`
+	// TravelMinutes is worked out from the distance when left out.
+	TravelMinutes *float64 `json:"travelMinutes"`
+}
+
+// GetRoads godoc
+// @Summary Get road segments
+// @Tags roads
+// @Produce json
+// @Param villageId query int false "Only roads of this village"
+// @Success 200 {array} RoadResponse
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Router /roads [get]
+func (h *RoadHandler) ListRoads(w http.ResponseWriter, r *http.Request) {
+	var villageID *uint
+	if villageParam := r.URL.Query().Get("villageId"); villageParam != "" {
+		villageInt, err := strconv.Atoi(villageParam)
+		if err != nil || villageInt <= 0 {
+			http.Error(w, "invalid villageId", http.StatusBadRequest)
+			return
+		}
+		id := uint(villageInt)
+		villageID = &id
+	}
+
+	roads, err := h.service.ListRoads(r.Context(), villageID)
+	if err != nil {
+		serverError(w, r, "failed to fetch roads", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newRoadResponses(roads))
+}
+
+// @CreateRoad godoc
+// @Summary Create a road segment
+// @Description Connects two buildings of the road's village, or tiles. Without travelMinutes, the travel time is the straight distance between the ends at one minute per tile; 0 is a free road.
+// @Tags roads
+// @Produce application/json
+// @Param request body CreateRoadRequest true "Create road payload"
+// @Success 200 {object} RoadResponse
+// @Failure 400 {string} string "Bad Request"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Router /roads [post]
+func (h *RoadHandler) CreateRoad(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("Content-Type", "application/json")
+
+	var body CreateRoadRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+
+	road, err := h.service.CreateRoad(r.Context(), models.RoadSegment{
+		VillageID:      body.VillageID,
+		FromBuildingID: body.From.BuildingID,
+		FromX:          body.From.X,
+		FromY:          body.From.Y,
+		ToBuildingID:   body.To.BuildingID,
+		ToX:            body.To.X,
+		ToY:            body.To.Y,
+	}, body.TravelMinutes)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidRoad),
+			errors.Is(err, services.ErrUnplacedEndpoint),
+			errors.Is(err, services.ErrEndpointNotFound),
+			errors.Is(err, services.ErrVillageNotFound):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			serverError(w, r, "failed to create road", err)
+		}
+		return
+	}
+
+	json.NewEncoder(w).Encode(newRoadResponse(road))
+}
+
+// @DeleteRoad godoc
+// @Summary Delete a road segment
+// @Tags roads
+// @Produce application/json
+// @Param id path int true "Road ID"
+// @Success 204
+// @Failure 404 {string} string "Not Found"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Router /roads/{id} [delete]
+func (h *RoadHandler) DeleteRoad(w http.ResponseWriter, r *http.Request) {
+	idParam := chi.URLParam(r, "id")
+
+	idInt, err := strconv.Atoi(idParam)
+	if err != nil || idInt <= 0 {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.DeleteRoad(r.Context(), uint(idInt)); err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			http.Error(w, "road not found", http.StatusNotFound)
+		default:
+			serverError(w, r, "failed to delete road", err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// FindPath godoc
+// @Summary Shortest route between two buildings
+// @Description Dijkstra over the road network, weighted by travel time.
+// @Tags roads
+// @Produce json
+// @Param from query int true "Start building ID"
+// @Param to query int true "Destination building ID"
+// @Success 200 {object} services.Path
+// @Failure 400 {string} string "Bad Request"
+// @Failure 404 {string} string "Not Found or No Route"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Router /paths [get]
+func (h *RoadHandler) FindPath(w http.ResponseWriter, r *http.Request) {
+	from, errFrom := strconv.Atoi(r.URL.Query().Get("from"))
+	to, errTo := strconv.Atoi(r.URL.Query().Get("to"))
+	if errFrom != nil || errTo != nil || from <= 0 || to <= 0 {
+		http.Error(w, "from and to must be building ids", http.StatusBadRequest)
+		return
+	}
+
+	path, err := h.service.FindPath(r.Context(), uint(from), uint(to))
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			http.Error(w, "building not found", http.StatusNotFound)
+		case errors.Is(err, services.ErrNoPath):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			serverError(w, r, "failed to find path", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(path)
+}
+
+// EstimateTask godoc
+// @Summary Estimate a task for a servitor
+// @Description Task time plus the walk from the servitor's home building to the task's building and back.
+// @Tags tasks
+// @Produce json
+// @Param id path int true "Task ID"
+// @Param home query int true "Servitor home building ID"
+// @Success 200 {object} services.TaskEstimate
+// @Failure 400 {string} string "Bad Request"
+// @Failure 404 {string} string "Not Found or No Route"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Router /tasks/{id}/estimate [get]
+func (h *RoadHandler) EstimateTask(w http.ResponseWriter, r *http.Request) {
+	idParam := chi.URLParam(r, "id")
+
+	idInt, err := strconv.Atoi(idParam)
+	if err != nil || idInt <= 0 {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	home, err := strconv.Atoi(r.URL.Query().Get("home"))
+	if err != nil || home <= 0 {
+		http.Error(w, "home must be a building id", http.StatusBadRequest)
+		return
+	}
+
+	estimate, err := h.service.EstimateTask(r.Context(), uint(idInt), uint(home))
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			http.Error(w, "task or building not found", http.StatusNotFound)
+		case errors.Is(err, services.ErrNoPath):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			serverError(w, r, "failed to estimate task", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(estimate)
+}
@@ -2,8 +2,13 @@ package httpx
 
 import (
 	"net/http"
+	"time"
 
+	"github.com/Stckrz/villageApi/internal/catalog"
+	"github.com/Stckrz/villageApi/internal/config"
+	"github.com/Stckrz/villageApi/internal/metrics"
 	"github.com/Stckrz/villageApi/internal/services"
+	"github.com/Stckrz/villageApi/internal/services/repository"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/cors"
 	httpSwagger "github.com/swaggo/http-swagger"
@@ -12,30 +17,58 @@ import (
 )
 
 type RouterDeps struct {
-	DB *gorm.DB
+	DB            *gorm.DB
+	BuildingTypes *catalog.Catalog
+	// ImageRoot is the directory building image paths are resolved against.
+	ImageRoot string
+	CORS      config.CORS
+	// RequestTimeout bounds how long a request may keep the database busy.
+	RequestTimeout time.Duration
+	// TransferTimeout bounds snapshot uploads and downloads instead.
+	TransferTimeout time.Duration
 }
 
 func BuildRouter(deps RouterDeps) *chi.Mux {
 	r := chi.NewRouter()
 
+	r.Use(withRequestID)
+	r.Use(withTracing)
+	r.Use(withAccessLog)
+	r.Use(withMetrics)
+	r.Use(withRecovery)
 	r.Use(cors.Handler(cors.Options{
-		AllowedOrigins: []string{
-			"http://127.0.0.1:5173",
-			"http://localhost:5173",
-			"http://localhost:8080",
-		},
+		AllowedOrigins:   deps.CORS.AllowedOrigins,
 		AllowedMethods:   []string{"GET", "POST", "PATCH", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Content-Type", "Authorization"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedHeaders:   []string{"Accept", "Content-Type", "Authorization", RequestIDHeader, "traceparent", "tracestate"},
+		ExposedHeaders:   []string{"Link", RequestIDHeader, TraceIDHeader},
 		AllowCredentials: true,
 		MaxAge:           300,
 	}))
 
-	buildingService := services.NewBuildingService(deps.DB)
-	taskService := services.NewTaskService(deps.DB)
+	store := repository.NewGormStore(deps.DB)
+	buildingService := services.TraceBuildingService(services.NewBuildingService(store, deps.BuildingTypes))
+	taskService := services.TraceTaskService(services.NewTaskService(store, deps.BuildingTypes))
+	villageService := services.NewVillageService(deps.DB)
+	inventoryService := services.NewInventoryService(deps.DB)
+	categoryService := services.NewCategoryService(deps.DB)
+	roadService := services.NewRoadService(deps.DB)
+	importService := services.NewImportService(deps.DB)
+	snapshotService := services.NewSnapshotService(deps.DB)
+	searchService := services.NewSearchService(deps.DB)
+	statsService := services.TraceStatsService(services.NewStatsService(deps.DB))
+	metricHistoryService := services.TraceMetricHistoryService(services.NewMetricHistoryService(deps.DB))
 
-	buildings := NewBuildingHandler(deps.DB, buildingService)
-	tasks := NewTaskHandler(deps.DB, taskService)
+	buildings := NewBuildingHandler(buildingService)
+	tasks := NewTaskHandler(taskService)
+	villages := NewVillageHandler(villageService)
+	inventory := NewInventoryHandler(inventoryService)
+	blueprints := NewBlueprintHandler(deps.BuildingTypes)
+	categories := NewCategoryHandler(categoryService)
+	roads := NewRoadHandler(roadService)
+	imports := NewImportHandler(importService)
+	snapshots := NewSnapshotHandler(snapshotService, deps.ImageRoot)
+	search := NewSearchHandler(searchService)
+	stats := NewStatsHandler(statsService, metricHistoryService)
 
 	// Health Check godoc
 	// @Summary Health Check
@@ -48,18 +81,76 @@ func BuildRouter(deps RouterDeps) *chi.Mux {
 		_, _ = w.Write([]byte("ok"))
 	})
 
-	// Building Endpoints
-	r.Get("/api/buildings", buildings.ListBuildings)
-	r.Get("/api/buildings/{id}", buildings.GetBuilding)
-	r.Post("/api/buildings", buildings.CreateBuilding)
-	r.Delete("/api/buildings/{id}", buildings.DeleteBuilding)
-	r.Put("/api/buildings/{id}", buildings.UpdateBuilding)
-
-	//Task Endpoints
-	r.Get("/api/tasks", tasks.ListTasks)
-	r.Post("/api/tasks", tasks.CreateTask)
-	r.Delete("/api/tasks/{id}", tasks.DeleteTask)
-	r.Put("/api/tasks/{id}", tasks.UpdateTask)
+	r.Group(func(r chi.Router) {
+		r.Use(withTimeout(deps.RequestTimeout))
+
+		// Building Endpoints
+		r.Get("/api/buildings", buildings.ListBuildings)
+		r.Get("/api/buildings/summary", buildings.SummarizeBuildings)
+		r.Get("/api/buildings/{id}", buildings.GetBuilding)
+		r.Post("/api/buildings", buildings.CreateBuilding)
+		r.Delete("/api/buildings/{id}", buildings.DeleteBuilding)
+		r.Put("/api/buildings/{id}", buildings.UpdateBuilding)
+		r.Post("/api/buildings/{id}/upgrade", buildings.UpgradeBuilding)
+		r.Post("/api/buildings/{id}/clone", buildings.CloneBuilding)
+		r.Post("/api/buildings/{id}/merge", buildings.MergeBuilding)
+
+		//Task Endpoints
+		r.Get("/api/tasks", tasks.ListTasks)
+		r.Post("/api/tasks", tasks.CreateTask)
+		r.Get("/api/tasks.csv", tasks.ExportTasksCSV)
+		r.Post("/api/tasks/import", tasks.ImportTasksCSV)
+		r.Post("/api/tasks/bulk", tasks.BulkTasks)
+		r.Delete("/api/tasks/{id}", tasks.DeleteTask)
+		r.Put("/api/tasks/{id}", tasks.UpdateTask)
+		r.Get("/api/tasks/{id}/estimate", roads.EstimateTask)
+
+		//Road Endpoints
+		r.Get("/api/roads", roads.ListRoads)
+		r.Post("/api/roads", roads.CreateRoad)
+		r.Delete("/api/roads/{id}", roads.DeleteRoad)
+		r.Get("/api/paths", roads.FindPath)
+
+		//Category Endpoints
+		r.Get("/api/categories", categories.ListCategories)
+		r.Put("/api/categories/{id}", categories.RenameCategory)
+		r.Post("/api/categories/{id}/merge", categories.MergeCategory)
+
+		//Blueprint Endpoints
+		r.Get("/api/blueprints", blueprints.ListBlueprints)
+		r.Get("/api/blueprints/{id}", blueprints.GetBlueprint)
+
+		//Village Endpoints
+		r.Get("/api/villages", villages.ListVillages)
+		r.Get("/api/villages/{id}", villages.GetVillage)
+		r.Post("/api/villages", villages.CreateVillage)
+		r.Delete("/api/villages/{id}", villages.DeleteVillage)
+		r.Post("/api/villages/{id}/actions", villages.RecordAction)
+		r.Post("/api/villages/{id}/replay", villages.ReplayVillage)
+		r.Get("/api/villages/{id}/inventory", inventory.GetStock)
+		r.Get("/api/villages/{id}/inventory/history", inventory.ListHistory)
+
+		//Search Endpoints
+		r.Get("/api/search", search.Search)
+
+		//Stats Endpoints
+		r.Get("/api/stats", stats.GetStats)
+		r.Get("/api/stats/history", stats.GetHistory)
+
+		//Import Endpoints
+		r.Post("/api/import/tiled", imports.ImportTiled)
+	})
+
+	//Snapshot Endpoints, whose archives can be too big for the request timeout
+	r.Group(func(r chi.Router) {
+		r.Use(withTransferTimeout(deps.TransferTimeout))
+
+		r.Post("/api/villages/import", snapshots.ImportVillage)
+		r.Get("/api/villages/{id}/export", snapshots.ExportVillage)
+	})
+
+	//Prometheus
+	r.Method(http.MethodGet, "/metrics", metrics.Handler())
 
 	//Swagger
 	r.Get("/swagger/*", httpSwagger.WrapHandler)
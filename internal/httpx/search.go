@@ -0,0 +1,73 @@
+package httpx
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Stckrz/villageApi/internal/services"
+)
+
+type SearchHandler struct {
+	service services.SearchService
+}
+
+func NewSearchHandler(service services.SearchService) *SearchHandler {
+	return &SearchHandler{
+		service: service,
+	}
+}
+
+// Search godoc
+// @Summary Search buildings and tasks
+// @Description Full-text search over building names, descriptions and categories and task names and descriptions. Every word must match, as a prefix. Hits are ranked and grouped by type; names and snippets are HTML escaped, with matched terms wrapped in <mark></mark>.
+// @Tags search
+// @Produce json
+// @Param q query string true "Search text"
+// @Param type query []string false "Only these types (building, task)" collectionFormat(multi)
+// @Param villageId query int false "Only results from this village"
+// @Param limit query int false "Hits per type, at most 100 (default 20)"
+// @Success 200 {object} services.SearchResults
+// @Failure 400 {string} string "Bad Request"
+// @Failure 503 {string} string "Search Unavailable"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Router /search [get]
+func (h *SearchHandler) Search(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	options := services.SearchOptions{Types: query["type"]}
+	if villageParam := query.Get("villageId"); villageParam != "" {
+		villageInt, err := strconv.Atoi(villageParam)
+		if err != nil || villageInt <= 0 {
+			http.Error(w, "invalid villageId", http.StatusBadRequest)
+			return
+		}
+		id := uint(villageInt)
+		options.VillageID = &id
+	}
+	if limitParam := query.Get("limit"); limitParam != "" {
+		limit, err := strconv.Atoi(limitParam)
+		if err != nil || limit <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		options.Limit = limit
+	}
+
+	results, err := h.service.Search(r.Context(), query.Get("q"), options)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrEmptySearch),
+			errors.Is(err, services.ErrUnknownSearchType):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, services.ErrSearchUnavailable):
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		default:
+			serverError(w, r, "failed to search", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
@@ -0,0 +1,145 @@
+package httpx
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/Stckrz/villageApi/internal/services"
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+// maxSnapshotSize caps the size of an uploaded snapshot or archive.
+const maxSnapshotSize = 200 << 20
+
+type SnapshotHandler struct {
+	service   services.SnapshotService
+	imageRoot string
+}
+
+func NewSnapshotHandler(service services.SnapshotService, imageRoot string) *SnapshotHandler {
+	return &SnapshotHandler{
+		service:   service,
+		imageRoot: imageRoot,
+	}
+}
+
+// ExportVillage godoc
+// @Summary Export a village
+// @Description A versioned snapshot of the village with its buildings, categories, tasks, rates, roads, ledger and action log. With format=archive it is a gzipped tar that also carries the referenced images.
+// @Tags villages
+// @Produce json
+// @Produce application/gzip
+// @Param id path int true "Village ID"
+// @Param format query string false "json (default) or archive"
+// @Success 200 {object} services.Snapshot
+// @Failure 400 {string} string "Bad Request"
+// @Failure 404 {string} string "Not Found"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Router /villages/{id}/export [get]
+func (h *SnapshotHandler) ExportVillage(w http.ResponseWriter, r *http.Request) {
+	idParam := chi.URLParam(r, "id")
+
+	idInt, err := strconv.Atoi(idParam)
+	if err != nil || idInt <= 0 {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "archive" {
+		http.Error(w, "format must be json or archive", http.StatusBadRequest)
+		return
+	}
+
+	snapshot, err := h.service.ExportVillage(r.Context(), uint(idInt))
+	if err != nil {
+		if requestEnded(w, r) {
+			return
+		}
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			http.Error(w, "village not found", http.StatusNotFound)
+		default:
+			serverError(w, r, "failed to export village", err)
+		}
+		return
+	}
+
+	if format == "archive" {
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="village-%d.tar.gz"`, idInt))
+		if err := services.WriteSnapshotArchive(w, snapshot, h.imageRoot); err != nil {
+			serverError(w, r, "failed to export village", err)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="village-%d.json"`, idInt))
+	json.NewEncoder(w).Encode(snapshot)
+}
+
+// @ImportVillage godoc
+// @Summary Import a village
+// @Description Recreates an exported snapshot as a new village with fresh ids. The body is either the JSON snapshot or the gzipped archive; images from an archive are written below the image root unless a file is already there. If the village is imported but its images can't be moved into place, the response carries a Warning header.
+// @Tags villages
+// @Accept json
+// @Accept application/gzip
+// @Produce application/json
+// @Param request body services.Snapshot true "Village snapshot"
+// @Success 200 {object} VillageResponse
+// @Failure 400 {string} string "Bad Request"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Router /villages/import [post]
+func (h *SnapshotHandler) ImportVillage(w http.ResponseWriter, r *http.Request) {
+	body := bufio.NewReader(http.MaxBytesReader(w, r.Body, maxSnapshotSize))
+
+	var snapshot services.Snapshot
+	var images map[string][]byte
+	// archives are told apart from JSON by the gzip magic number
+	if magic, _ := body.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		var err error
+		if snapshot, images, err = services.ReadSnapshotArchive(body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	} else if err := json.NewDecoder(body).Decode(&snapshot); err != nil {
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+
+	// images are staged before the import and moved into place after it
+	// commits, so a failure either way never leaves half an import behind
+	staged, err := services.StageSnapshotImages(h.imageRoot, images)
+	if err != nil {
+		serverError(w, r, "failed to stage village images", err)
+		return
+	}
+	village, err := h.service.ImportVillage(r.Context(), snapshot)
+	if err != nil {
+		staged.Discard()
+		if requestEnded(w, r) {
+			return
+		}
+		switch {
+		case errors.Is(err, services.ErrSnapshotVersion),
+			errors.Is(err, services.ErrInvalidSnapshot):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			serverError(w, r, "failed to import village", err)
+		}
+		return
+	}
+	if err := staged.Commit(); err != nil {
+		// the village exists now; failing the request would only get it
+		// imported twice on a retry
+		Logger(r.Context()).Error("move imported images into place", "village_id", village.ID, "error", err)
+		w.Header().Set("Warning", `199 - "village imported, but some of its images could not be saved"`)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newVillageResponse(village))
+}
@@ -0,0 +1,154 @@
+package httpx
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Stckrz/villageApi/internal/services"
+)
+
+// defaultStatsDays is how far back stats reach when from is left out.
+const defaultStatsDays = 30
+
+type StatsHandler struct {
+	service services.StatsService
+	history services.MetricHistoryService
+}
+
+func NewStatsHandler(service services.StatsService, history services.MetricHistoryService) *StatsHandler {
+	return &StatsHandler{
+		service: service,
+		history: history,
+	}
+}
+
+// GetStats godoc
+// @Summary Get village statistics
+// @Description Tasks created and completed per bucket, mean minutes from creating to completing a task per building and category, and streaks of buckets with a task completed. Days are UTC and weeks start on Monday; a week range starts on the Monday of from. Only tasks still stored are counted.
+// @Tags stats
+// @Produce json
+// @Param from query string false "First day, YYYY-MM-DD (default 30 days before to)"
+// @Param to query string false "Last day, YYYY-MM-DD (default today)"
+// @Param bucket query string false "day or week (default day)"
+// @Param villageId query int false "Only tasks of this village's buildings"
+// @Success 200 {object} services.Stats
+// @Failure 400 {string} string "Bad Request"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Router /stats [get]
+func (h *StatsHandler) GetStats(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	options := services.StatsOptions{
+		To:     time.Now().UTC(),
+		Bucket: query.Get("bucket"),
+	}
+	if toParam := query.Get("to"); toParam != "" {
+		to, err := time.Parse(time.DateOnly, toParam)
+		if err != nil {
+			http.Error(w, "invalid to", http.StatusBadRequest)
+			return
+		}
+		options.To = to
+	}
+	options.From = options.To.AddDate(0, 0, -defaultStatsDays)
+	if fromParam := query.Get("from"); fromParam != "" {
+		from, err := time.Parse(time.DateOnly, fromParam)
+		if err != nil {
+			http.Error(w, "invalid from", http.StatusBadRequest)
+			return
+		}
+		options.From = from
+	}
+	if villageParam := query.Get("villageId"); villageParam != "" {
+		villageInt, err := strconv.Atoi(villageParam)
+		if err != nil || villageInt <= 0 {
+			http.Error(w, "invalid villageId", http.StatusBadRequest)
+			return
+		}
+		id := uint(villageInt)
+		options.VillageID = &id
+	}
+
+	stats, err := h.service.GetStats(r.Context(), options)
+	if err != nil {
+		if requestEnded(w, r) {
+			return
+		}
+		switch {
+		case errors.Is(err, services.ErrUnknownStatsBucket),
+			errors.Is(err, services.ErrInvalidStatsRange):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			serverError(w, r, "failed to compute stats", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// GetHistory godoc
+// @Summary Get daily metric history
+// @Description Daily snapshots of building counts, open and completed tasks and, per village, resource stock (metrics named resource.wood and so on), oldest first. Snapshots are taken in the background, so they outlive deleted rows; the current day holds the latest snapshot. Days without a snapshot are left out.
+// @Tags stats
+// @Produce json
+// @Param from query string false "First day, YYYY-MM-DD (default 30 days before to)"
+// @Param to query string false "Last day, YYYY-MM-DD (default today)"
+// @Param villageId query int false "One village's metrics instead of the whole database's"
+// @Param metric query []string false "Only these metrics" collectionFormat(multi)
+// @Success 200 {array} services.MetricSeries
+// @Failure 400 {string} string "Bad Request"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Router /stats/history [get]
+func (h *StatsHandler) GetHistory(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	options := services.MetricHistoryQuery{
+		To:      time.Now().UTC(),
+		Metrics: query["metric"],
+	}
+	if toParam := query.Get("to"); toParam != "" {
+		to, err := time.Parse(time.DateOnly, toParam)
+		if err != nil {
+			http.Error(w, "invalid to", http.StatusBadRequest)
+			return
+		}
+		options.To = to
+	}
+	options.From = options.To.AddDate(0, 0, -defaultStatsDays)
+	if fromParam := query.Get("from"); fromParam != "" {
+		from, err := time.Parse(time.DateOnly, fromParam)
+		if err != nil {
+			http.Error(w, "invalid from", http.StatusBadRequest)
+			return
+		}
+		options.From = from
+	}
+	if villageParam := query.Get("villageId"); villageParam != "" {
+		villageInt, err := strconv.Atoi(villageParam)
+		if err != nil || villageInt <= 0 {
+			http.Error(w, "invalid villageId", http.StatusBadRequest)
+			return
+		}
+		options.VillageID = uint(villageInt)
+	}
+
+	series, err := h.history.History(r.Context(), options)
+	if err != nil {
+		if requestEnded(w, r) {
+			return
+		}
+		switch {
+		case errors.Is(err, services.ErrInvalidStatsRange):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			serverError(w, r, "failed to fetch history", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(series)
+}
@@ -2,56 +2,220 @@ package httpx
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/Stckrz/villageApi/internal/db/models"
 	"github.com/Stckrz/villageApi/internal/services"
+	"github.com/Stckrz/villageApi/internal/services/repository"
 	"github.com/go-chi/chi/v5"
 	"gorm.io/gorm"
 )
 
+// maxTaskCSVSize caps the size of an uploaded task spreadsheet.
+const maxTaskCSVSize = 10 << 20
+
 type TaskHandler struct {
 	service services.TaskService
-	db      *gorm.DB
 }
 
-func NewTaskHandler(db *gorm.DB, service services.TaskService) *TaskHandler {
+func NewTaskHandler(service services.TaskService) *TaskHandler {
 	return &TaskHandler{
-		db:      db,
 		service: service,
 	}
 }
 
 type CreateTaskRequest struct {
-	Name        string          `json:"name"`
-	Description string          `json:"description"`
-	BuildingId  uint            `json:"building_id"`
-	IsCompleted bool            `json:"is_completed"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	BuildingId  uint   `json:"buildingId"`
+	IsCompleted bool   `json:"isCompleted"`
 }
 
 type UpdateTaskRequest struct {
-	Name        string          `json:"name"`
-	Description string          `json:"description"`
-	BuildingId  uint            `json:"building_id"`
-	IsCompleted bool            `json:"is_completed"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	BuildingId  uint   `json:"buildingId"`
+	IsCompleted bool   `json:"isCompleted"`
+}
+
+type BulkTaskOperationRequest struct {
+	Op   string            `json:"op"`
+	ID   uint              `json:"id"`
+	Task UpdateTaskRequest `json:"task"`
+}
+
+type BulkTasksRequest struct {
+	Operations []BulkTaskOperationRequest `json:"operations"`
 }
 
 // GetTasks godoc
 // @Summary Get tasks
 // @Tags tasks
 // @Produce json
-// @Success 200 {array} models.Task
+// @Param buildingId query int false "Only tasks of this building"
+// @Param villageId query int false "Only tasks of buildings in this village"
+// @Param completed query bool false "Only completed or only open tasks"
+// @Param kind query string false "Only tasks of this kind (chore or construction)"
+// @Success 200 {array} TaskResponse
+// @Failure 400 {string} string "Bad Request"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
 // @Router /tasks [get]
 func (h *TaskHandler) ListTasks(w http.ResponseWriter, r *http.Request) {
-	users, err := h.service.ListTasks()
+	filter, err := parseTaskFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tasks, err := h.service.ListTasks(r.Context(), filter)
+	if err != nil {
+		if requestEnded(w, r) {
+			return
+		}
+		serverError(w, r, "failed to fetch users", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newTaskResponses(tasks))
+}
+
+// ExportTasksCSV godoc
+// @Summary Export tasks as CSV
+// @Description Takes the same filters as the task list. Text cells starting with =, +, -, @, a tab, a carriage return or an apostrophe get a leading apostrophe, so spreadsheets show them as text; the import strips it again.
+// @Tags tasks
+// @Produce text/csv
+// @Param buildingId query int false "Only tasks of this building"
+// @Param villageId query int false "Only tasks of buildings in this village"
+// @Param completed query bool false "Only completed or only open tasks"
+// @Param kind query string false "Only tasks of this kind (chore or construction)"
+// @Success 200 {string} string "CSV with a header row"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Router /tasks.csv [get]
+func (h *TaskHandler) ExportTasksCSV(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseTaskFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="tasks.csv"`)
+	if err := h.service.WriteTasksCSV(r.Context(), w, filter); err != nil {
+		if requestEnded(w, r) {
+			return
+		}
+		serverError(w, r, "failed to export tasks", err)
+	}
+}
+
+// @ImportTasksCSV godoc
+// @Summary Import tasks from CSV
+// @Description Rows without an id create a task, rows with one update it. The building is given by building_id or by name in the building column. Every row is validated in the transaction that writes them; if any is invalid, nothing is imported and the errors are returned per row.
+// @Tags tasks
+// @Accept text/csv
+// @Produce application/json
+// @Success 200 {object} services.TaskImportReport
+// @Failure 422 {object} services.TaskImportReport
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Router /tasks/import [post]
+func (h *TaskHandler) ImportTasksCSV(w http.ResponseWriter, r *http.Request) {
+	report, err := h.service.ImportTasksCSV(r.Context(), http.MaxBytesReader(w, r.Body, maxTaskCSVSize))
+	if err != nil && !errors.Is(err, services.ErrInvalidTaskImport) {
+		if requestEnded(w, r) {
+			return
+		}
+		serverError(w, r, "failed to import tasks", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
 	if err != nil {
-		http.Error(w, "failed to fetch users", http.StatusInternalServerError)
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+// @BulkTasks godoc
+// @Summary Create, update, delete or complete many tasks at once
+// @Description Operations run in order in one transaction. Every operation is attempted and reported; if any fails, none of them are applied and the response is 422.
+// @Tags tasks
+// @Accept json
+// @Produce application/json
+// @Param request body BulkTasksRequest true "Bulk operations"
+// @Success 200 {object} services.BulkTaskReport
+// @Failure 400 {string} string "Bad Request"
+// @Failure 422 {object} services.BulkTaskReport
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Router /tasks/bulk [post]
+func (h *TaskHandler) BulkTasks(w http.ResponseWriter, r *http.Request) {
+	var body BulkTasksRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "invalid body", http.StatusBadRequest)
 		return
 	}
+	operations := make([]services.BulkTaskOperation, 0, len(body.Operations))
+	for _, operation := range body.Operations {
+		operations = append(operations, services.BulkTaskOperation{
+			Op: operation.Op,
+			ID: operation.ID,
+			Task: models.Task{
+				Name:        operation.Task.Name,
+				Description: operation.Task.Description,
+				BuildingId:  operation.Task.BuildingId,
+				IsCompleted: operation.Task.IsCompleted,
+			},
+		})
+	}
+
+	report, err := h.service.BulkTasks(r.Context(), operations)
+	if err != nil {
+		if requestEnded(w, r) {
+			return
+		}
+		switch {
+		case errors.Is(err, services.ErrTooManyBulkOps):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case !errors.Is(err, services.ErrBulkFailed):
+			serverError(w, r, "failed to apply bulk operations", err)
+			return
+		}
+	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(users)
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+// parseTaskFilter reads the task list filters from the query string.
+func parseTaskFilter(r *http.Request) (services.TaskFilter, error) {
+	var filter services.TaskFilter
+	query := r.URL.Query()
+	for name, target := range map[string]**uint{"buildingId": &filter.BuildingID, "villageId": &filter.VillageID} {
+		if value := query.Get(name); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed <= 0 {
+				return services.TaskFilter{}, errors.New("invalid " + name)
+			}
+			id := uint(parsed)
+			*target = &id
+		}
+	}
+	if value := query.Get("completed"); value != "" {
+		completed, err := strconv.ParseBool(value)
+		if err != nil {
+			return services.TaskFilter{}, errors.New("invalid completed")
+		}
+		filter.IsCompleted = &completed
+	}
+	filter.Kind = query.Get("kind")
+	return filter, nil
 }
 
 // @CreateTask godoc
@@ -59,8 +223,9 @@ func (h *TaskHandler) ListTasks(w http.ResponseWriter, r *http.Request) {
 // @Tags tasks
 // @Produce application/json
 // @Param request body CreateTaskRequest true "Create task payload"
-// @Success 200 {object} map[string]string
-// @Failure 500 {string} string "Internal Service Error"
+// @Success 200 {object} TaskResponse
+// @Failure 400 {string} string "Bad Request"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
 // @Router /tasks [post]
 func (h *TaskHandler) CreateTask(w http.ResponseWriter, r *http.Request) {
 
@@ -78,16 +243,21 @@ func (h *TaskHandler) CreateTask(w http.ResponseWriter, r *http.Request) {
 		IsCompleted: body.IsCompleted,
 	}
 
-	task, err := h.service.CreateTask(task)
+	task, err := h.service.CreateTask(r.Context(), task)
 	if err != nil {
-		switch err {
+		if requestEnded(w, r) {
+			return
+		}
+		switch {
+		case errors.Is(err, repository.ErrMissingBuilding):
+			http.Error(w, err.Error(), http.StatusBadRequest)
 		default:
-			http.Error(w, "failed to create task", http.StatusInternalServerError)
+			serverError(w, r, "failed to create task", err)
 		}
 		return
 	}
 
-	json.NewEncoder(w).Encode(task)
+	json.NewEncoder(w).Encode(newTaskResponse(task))
 	return
 }
 
@@ -97,7 +267,9 @@ func (h *TaskHandler) CreateTask(w http.ResponseWriter, r *http.Request) {
 // @Produce application/json
 // @Param id path int true "Task ID"
 // @Success 200 {object} map[string]string
-// @Failure 500 {string} string "Internal Service Error"
+// @Failure 404 {string} string "Not Found"
+// @Failure 409 {string} string "Open Construction Task"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
 // @Router /tasks/{id} [delete]
 func (h *TaskHandler) DeleteTask(w http.ResponseWriter, r *http.Request) {
 	idParam := chi.URLParam(r, "id")
@@ -108,8 +280,18 @@ func (h *TaskHandler) DeleteTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.service.DeleteTask(uint(idInt)); err != nil {
-		http.Error(w, "failed to delete task", http.StatusInternalServerError)
+	if err := h.service.DeleteTask(r.Context(), uint(idInt)); err != nil {
+		if requestEnded(w, r) {
+			return
+		}
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			http.Error(w, "task not found", http.StatusNotFound)
+		case errors.Is(err, services.ErrConstructionTask):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			serverError(w, r, "failed to delete task", err)
+		}
 		return
 	}
 
@@ -123,7 +305,10 @@ func (h *TaskHandler) DeleteTask(w http.ResponseWriter, r *http.Request) {
 // @Param id path int true "Task ID"
 // @Param request body UpdateTaskRequest true "Update task payload"
 // @Success 200 {object} map[string]string
-// @Failure 500 {string} string "Internal Service Error"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 404 {string} string "Not Found"
+// @Failure 409 {string} string "Open Construction Task"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
 // @Router /tasks/{id} [put]
 func (h *TaskHandler) UpdateTask(w http.ResponseWriter, r *http.Request) {
 	idParam := chi.URLParam(r, "id")
@@ -149,14 +334,26 @@ func (h *TaskHandler) UpdateTask(w http.ResponseWriter, r *http.Request) {
 	// }
 
 	task := models.Task{
-		Name:          body.Name,
-		Description:   body.Description,
-		BuildingId:    body.BuildingId,
-		IsCompleted:     body.IsCompleted,
+		Name:        body.Name,
+		Description: body.Description,
+		BuildingId:  body.BuildingId,
+		IsCompleted: body.IsCompleted,
 	}
 
-	if err := h.service.UpdateTask(task, uint(idInt)); err != nil {
-		http.Error(w, "failed to update task", http.StatusInternalServerError)
+	if err := h.service.UpdateTask(r.Context(), task, uint(idInt)); err != nil {
+		if requestEnded(w, r) {
+			return
+		}
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			http.Error(w, "task not found", http.StatusNotFound)
+		case errors.Is(err, repository.ErrMissingBuilding):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, services.ErrConstructionTask):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			serverError(w, r, "failed to update task", err)
+		}
 		return
 	}
 
@@ -0,0 +1,52 @@
+package httpx
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// withTimeout puts a deadline on every request's context. Services run their
+// queries with that context, so once it passes they are abandoned instead of
+// running on for a client that stopped waiting.
+func withTimeout(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// withTransferTimeout is withTimeout for snapshot uploads and downloads. They
+// can take far longer than the server's read and write timeouts allow, so the
+// connection's deadlines are pushed out to the same, longer, timeout.
+func withTransferTimeout(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			deadline := time.Now().Add(timeout)
+			controller := http.NewResponseController(w)
+			//not every writer supports deadlines, the recorder in tests doesn't
+			_ = controller.SetReadDeadline(deadline)
+			_ = controller.SetWriteDeadline(deadline)
+			ctx, cancel := context.WithDeadline(r.Context(), deadline)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// requestEnded is checked when a service fails. If the request's context ended
+// first, that is the real cause: a passed deadline is answered with 504, and a
+// cancelled request, whose client is gone or the server is shutting down, gets
+// no answer at all. It reports whether the response is taken care of.
+func requestEnded(w http.ResponseWriter, r *http.Request) bool {
+	switch r.Context().Err() {
+	case nil:
+		return false
+	case context.DeadlineExceeded:
+		http.Error(w, "request timed out", http.StatusGatewayTimeout)
+	}
+	return true
+}
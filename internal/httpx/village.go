@@ -0,0 +1,266 @@
+package httpx
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/Stckrz/villageApi/internal/db/models"
+	"github.com/Stckrz/villageApi/internal/services"
+	"github.com/Stckrz/villageApi/internal/sim"
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+type VillageHandler struct {
+	service services.VillageService
+}
+
+func NewVillageHandler(service services.VillageService) *VillageHandler {
+	return &VillageHandler{
+		service: service,
+	}
+}
+
+type CreateVillageRequest struct {
+	Name string `json:"name"`
+	Seed *int64 `json:"seed"`
+}
+
+// ReplayVillageRequest optionally replays from another seed than the stored one.
+type ReplayVillageRequest struct {
+	Seed *int64 `json:"seed"`
+}
+
+type RecordActionRequest struct {
+	Kind    string         `json:"kind"`
+	Payload map[string]any `json:"payload"`
+}
+
+// GetVillages godoc
+// @Summary Get villages
+// @Tags villages
+// @Produce json
+// @Success 200 {array} VillageResponse
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Router /villages [get]
+func (h *VillageHandler) ListVillages(w http.ResponseWriter, r *http.Request) {
+	villages, err := h.service.ListVillages(r.Context())
+	if err != nil {
+		if requestEnded(w, r) {
+			return
+		}
+		serverError(w, r, "failed to fetch villages", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newVillageResponses(villages))
+}
+
+// GetVillageById godoc
+// @Summary Get village by id
+// @Tags villages
+// @Produce json
+// @Param id path int true "Village ID"
+// @Success 200 {object} VillageResponse
+// @Failure 404 {string} string "Not Found"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Router /villages/{id} [get]
+func (h *VillageHandler) GetVillage(w http.ResponseWriter, r *http.Request) {
+	idParam := chi.URLParam(r, "id")
+
+	idInt, err := strconv.Atoi(idParam)
+	if err != nil || idInt <= 0 {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	village, err := h.service.GetVillageByID(r.Context(), uint(idInt))
+	if err != nil {
+		if requestEnded(w, r) {
+			return
+		}
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			http.Error(w, "village not found", http.StatusNotFound)
+		default:
+			serverError(w, r, "failed to fetch village", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newVillageResponse(village))
+}
+
+// @CreateVillage godoc
+// @Summary Create new village
+// @Description Starts a new simulation. The seed is random unless given.
+// @Tags villages
+// @Produce application/json
+// @Param request body CreateVillageRequest true "Create village payload"
+// @Success 200 {object} VillageResponse
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Router /villages [post]
+func (h *VillageHandler) CreateVillage(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("Content-Type", "application/json")
+
+	var body CreateVillageRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+
+	village, err := h.service.CreateVillage(r.Context(), models.Village{Name: body.Name}, body.Seed)
+	if err != nil {
+		if requestEnded(w, r) {
+			return
+		}
+		serverError(w, r, "failed to create village", err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(newVillageResponse(village))
+}
+
+// @DeleteVillage godoc
+// @Summary Delete a village
+// @Tags villages
+// @Produce application/json
+// @Param id path int true "Village ID"
+// @Success 204
+// @Failure 404 {string} string "Not Found"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Router /villages/{id} [delete]
+func (h *VillageHandler) DeleteVillage(w http.ResponseWriter, r *http.Request) {
+	idParam := chi.URLParam(r, "id")
+
+	idInt, err := strconv.Atoi(idParam)
+	if err != nil || idInt <= 0 {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.DeleteVillage(r.Context(), uint(idInt)); err != nil {
+		if requestEnded(w, r) {
+			return
+		}
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			http.Error(w, "village not found", http.StatusNotFound)
+		default:
+			serverError(w, r, "failed to delete village", err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @RecordAction godoc
+// @Summary Record and apply a simulation action
+// @Description Appends the action to the village log and applies it to the saved state. Supported kinds: advance {"ticks": n} runs production and events; adjust {"resource", "delta", "reason"} moves stock by hand.
+// @Tags villages
+// @Produce application/json
+// @Param id path int true "Village ID"
+// @Param request body RecordActionRequest true "Action payload"
+// @Success 200 {object} VillageResponse
+// @Failure 400 {string} string "Invalid Action"
+// @Failure 404 {string} string "Not Found"
+// @Failure 409 {string} string "Insufficient Stock"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Router /villages/{id}/actions [post]
+func (h *VillageHandler) RecordAction(w http.ResponseWriter, r *http.Request) {
+	idParam := chi.URLParam(r, "id")
+
+	idInt, err := strconv.Atoi(idParam)
+	if err != nil || idInt <= 0 {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	var body RecordActionRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+	payload, err := json.Marshal(body.Payload)
+	if err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	village, err := h.service.RecordAction(r.Context(), uint(idInt), models.VillageAction{
+		Kind:    body.Kind,
+		Payload: string(payload),
+	})
+	if err != nil {
+		if requestEnded(w, r) {
+			return
+		}
+		switch {
+		case errors.Is(err, sim.ErrInvalidAction):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, sim.ErrInsufficientStock):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			http.Error(w, "village not found", http.StatusNotFound)
+		default:
+			serverError(w, r, "failed to record action", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newVillageResponse(village))
+}
+
+// @ReplayVillage godoc
+// @Summary Replay a village from its seed
+// @Description Re-runs the simulation from the stored seed, or the seed in the body, and the action log and reports divergence from the saved state.
+// @Tags villages
+// @Produce application/json
+// @Param id path int true "Village ID"
+// @Param request body ReplayVillageRequest false "Seed override"
+// @Success 200 {object} services.ReplayReport
+// @Failure 400 {string} string "Bad Request"
+// @Failure 404 {string} string "Not Found"
+// @Failure 500 {object} ErrorResponse "Internal Server Error"
+// @Router /villages/{id}/replay [post]
+func (h *VillageHandler) ReplayVillage(w http.ResponseWriter, r *http.Request) {
+	idParam := chi.URLParam(r, "id")
+
+	idInt, err := strconv.Atoi(idParam)
+	if err != nil || idInt <= 0 {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	// the body may be left out to replay from the stored seed
+	var body ReplayVillageRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+
+	report, err := h.service.ReplayVillage(r.Context(), uint(idInt), body.Seed)
+	if err != nil {
+		if requestEnded(w, r) {
+			return
+		}
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			http.Error(w, "village not found", http.StatusNotFound)
+		default:
+			serverError(w, r, "failed to replay village", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/Stckrz/villageApi/internal/db/models"
+	"github.com/Stckrz/villageApi/internal/services/repository"
+)
+
+var (
+	ErrMergeBuildingIntoItself = errors.New("cannot merge a building into itself")
+	ErrMergeUnderConstruction  = errors.New("cannot merge away a building that is under construction")
+)
+
+// CloneOptions shapes the copy. A blank Name reuses the source's name. X and Y
+// place the clone with the source's footprint; without them the clone is kept
+// off the grid.
+type CloneOptions struct {
+	Name string
+	X    *int
+	Y    *int
+}
+
+// CloneBuilding creates a new building configured like id: same village,
+// description, images, categories and rates, and a fresh copy of every open
+// chore. Completed tasks stay with the source. A blueprint building with levels
+// is not copied at its level: the clone starts construction of level 1 like a
+// newly created one, reserving that level's cost from the village.
+func (s *buildingService) CloneBuilding(ctx context.Context, id uint, options CloneOptions) (models.Building, error) {
+	var clone models.Building
+	err := s.store.Transaction(ctx, func(transaction repository.Store) error {
+		source, err := transaction.Buildings().Get(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		clone = models.Building{
+			Name:          source.Name,
+			Description:   source.Description,
+			ThumbnailPath: source.ThumbnailPath,
+			ImagePath:     source.ImagePath,
+			VillageID:     source.VillageID,
+			Categories:    source.Categories,
+			Type:          source.Type,
+			Rotation:      source.Rotation,
+		}
+		if options.Name != "" {
+			clone.Name = options.Name
+		}
+		if options.X != nil && options.Y != nil {
+			clone.X, clone.Y = *options.X, *options.Y
+			clone.Width, clone.Height = source.Width, source.Height
+		}
+		for _, task := range source.Tasks {
+			if task.Kind != models.TaskKindChore || task.IsCompleted {
+				continue
+			}
+			clone.Tasks = append(clone.Tasks, models.Task{
+				Name:             task.Name,
+				Description:      task.Description,
+				Kind:             models.TaskKindChore,
+				EstimatedMinutes: task.EstimatedMinutes,
+			})
+		}
+
+		buildingType, typed := s.buildingTypes.Lookup(source.Type)
+		leveled := typed && buildingType.MaxLevel() > 0
+		if leveled {
+			clone.Status = models.BuildingStatusUnderConstruction
+		} else {
+			for _, rate := range source.Rates {
+				clone.Rates = append(clone.Rates, models.BuildingRate{Resource: rate.Resource, PerTick: rate.PerTick})
+			}
+		}
+
+		if err := checkOverlap(ctx, transaction, clone, 0); err != nil {
+			return err
+		}
+		if err := transaction.Buildings().Create(ctx, &clone); err != nil {
+			return err
+		}
+		if !leveled {
+			return nil
+		}
+		return startConstruction(ctx, transaction, &clone, buildingType, 1)
+	})
+	if err != nil {
+		return models.Building{}, err
+	}
+	return s.GetBuildingByID(ctx, clone.ID)
+}
+
+// MergeBuilding folds source into target: source's chores move to target, its
+// categories are added to target's, and source is deleted along with what is
+// left of it: construction tasks, rates and road segments. A source under
+// construction is refused, since its reserved cost belongs to it.
+func (s *buildingService) MergeBuilding(ctx context.Context, targetID uint, sourceID uint) (models.Building, error) {
+	if targetID == sourceID {
+		return models.Building{}, ErrMergeBuildingIntoItself
+	}
+
+	err := s.store.Transaction(ctx, func(transaction repository.Store) error {
+		target, err := transaction.Buildings().Get(ctx, targetID)
+		if err != nil {
+			return err
+		}
+		source, err := transaction.Buildings().Get(ctx, sourceID)
+		if err != nil {
+			return err
+		}
+		if source.Status == models.BuildingStatusUnderConstruction {
+			return ErrMergeUnderConstruction
+		}
+
+		for _, task := range source.Tasks {
+			if task.Kind != models.TaskKindChore {
+				continue
+			}
+			task.BuildingId = targetID
+			if err := transaction.Tasks().Update(ctx, task); err != nil {
+				return err
+			}
+		}
+		for _, category := range source.Categories {
+			if !slices.ContainsFunc(target.Categories, func(linked models.Category) bool { return linked.ID == category.ID }) {
+				target.Categories = append(target.Categories, category)
+			}
+		}
+		if err := transaction.Buildings().Update(ctx, target); err != nil {
+			return err
+		}
+		// construction history describes the source's levels, not target's, and
+		// goes with it
+		return transaction.Buildings().Delete(ctx, sourceID)
+	})
+	if err != nil {
+		return models.Building{}, err
+	}
+	return s.GetBuildingByID(ctx, targetID)
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Stckrz/villageApi/internal/catalog"
+	"github.com/Stckrz/villageApi/internal/db/models"
+	"github.com/Stckrz/villageApi/internal/services/repository"
+)
+
+func TestCloneBuildingCopiesOpenChoresAndLinksCategories(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t)
+	buildings := NewBuildingService(repository.NewGormStore(database), catalog.Default())
+
+	source, err := buildings.CreateBuilding(ctx, models.Building{
+		Name:       "Barn",
+		Categories: []models.Category{{Name: "Storage"}, {Name: "Farm"}},
+		Tasks: []models.Task{
+			{Name: "Sweep", EstimatedMinutes: 15},
+			{Name: "Paint", IsCompleted: true},
+		},
+	})
+	if err != nil {
+		t.Fatalf("create source: %v", err)
+	}
+
+	clone, err := buildings.CloneBuilding(ctx, source.ID, CloneOptions{Name: "Second barn"})
+	if err != nil {
+		t.Fatalf("clone: %v", err)
+	}
+	if clone.ID == source.ID || clone.Name != "Second barn" {
+		t.Fatalf("clone %+v", clone)
+	}
+	if len(clone.Tasks) != 1 {
+		t.Fatalf("clone tasks %+v, want only the open chore", clone.Tasks)
+	}
+	if task := clone.Tasks[0]; task.Name != "Sweep" || task.EstimatedMinutes != 15 || task.IsCompleted || task.CompletedAt != nil {
+		t.Fatalf("cloned task %+v", task)
+	}
+	if len(clone.Categories) != 2 {
+		t.Fatalf("clone categories %+v", clone.Categories)
+	}
+	for index, category := range clone.Categories {
+		if category.ID != source.Categories[index].ID {
+			t.Fatalf("clone category %+v isn't the source's %+v", category, source.Categories[index])
+		}
+	}
+	var categories int64
+	if err := database.Model(&models.Category{}).Count(&categories).Error; err != nil {
+		t.Fatal(err)
+	}
+	if categories != 2 {
+		t.Fatalf("%d categories after the clone, want the 2 shared ones", categories)
+	}
+	if source, err = buildings.GetBuildingByID(ctx, source.ID); err != nil || len(source.Tasks) != 2 {
+		t.Fatalf("source after the clone: %+v, %v", source, err)
+	}
+}
+
+func TestMergeBuildingIntoItselfIsRefused(t *testing.T) {
+	ctx := context.Background()
+	buildings := NewBuildingService(repository.NewMemoryStore(), catalog.Default())
+	building, err := buildings.CreateBuilding(ctx, models.Building{Name: "Barn"})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := buildings.MergeBuilding(ctx, building.ID, building.ID); !errors.Is(err, ErrMergeBuildingIntoItself) {
+		t.Fatalf("self merge: %v", err)
+	}
+}
+
+func TestMergeBuildingFailingPartwayChangesNothing(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewGormStore(newTestDB(t))
+	buildings := NewBuildingService(store, catalog.Default())
+
+	target, err := buildings.CreateBuilding(ctx, models.Building{Name: "Barn", Categories: []models.Category{{Name: "Storage"}}})
+	if err != nil {
+		t.Fatalf("create target: %v", err)
+	}
+	source, err := buildings.CreateBuilding(ctx, models.Building{
+		Name:       "Shed",
+		Categories: []models.Category{{Name: "Tools"}},
+		Tasks:      []models.Task{{Name: "Oil the hinges"}},
+	})
+	if err != nil {
+		t.Fatalf("create source: %v", err)
+	}
+
+	// the chores have moved and target's categories are saved by the time the
+	// source is deleted, which is made to fail
+	failing := NewBuildingService(failingDeleteStore{store}, catalog.Default())
+	if _, err := failing.MergeBuilding(ctx, target.ID, source.ID); !errors.Is(err, errDeleteFailed) {
+		t.Fatalf("merge: %v", err)
+	}
+
+	source, err = buildings.GetBuildingByID(ctx, source.ID)
+	if err != nil {
+		t.Fatalf("source is gone after a failed merge: %v", err)
+	}
+	if len(source.Tasks) != 1 {
+		t.Fatalf("source tasks after a failed merge %+v", source.Tasks)
+	}
+	if target, err = buildings.GetBuildingByID(ctx, target.ID); err != nil {
+		t.Fatalf("get target: %v", err)
+	}
+	if len(target.Tasks) != 0 || len(target.Categories) != 1 {
+		t.Fatalf("target after a failed merge %+v", target)
+	}
+
+	merged, err := buildings.MergeBuilding(ctx, target.ID, source.ID)
+	if err != nil {
+		t.Fatalf("merge: %v", err)
+	}
+	if len(merged.Tasks) != 1 || len(merged.Categories) != 2 {
+		t.Fatalf("merged %+v", merged)
+	}
+	if _, err := buildings.GetBuildingByID(ctx, source.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("source after the merge: %v", err)
+	}
+}
+
+var errDeleteFailed = errors.New("delete failed")
+
+// failingDeleteStore fails every building delete, inside transactions too.
+type failingDeleteStore struct {
+	repository.Store
+}
+
+func (s failingDeleteStore) Buildings() repository.BuildingRepository {
+	return failingDeleteBuildings{s.Store.Buildings()}
+}
+
+func (s failingDeleteStore) Transaction(ctx context.Context, fn func(store repository.Store) error) error {
+	return s.Store.Transaction(ctx, func(store repository.Store) error {
+		return fn(failingDeleteStore{store})
+	})
+}
+
+type failingDeleteBuildings struct {
+	repository.BuildingRepository
+}
+
+func (failingDeleteBuildings) Delete(ctx context.Context, id uint) error {
+	return errDeleteFailed
+}
@@ -1,103 +1,283 @@
 package services
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
 
+	"github.com/Stckrz/villageApi/internal/catalog"
 	"github.com/Stckrz/villageApi/internal/db/models"
-	"gorm.io/gorm"
+	"github.com/Stckrz/villageApi/internal/services/repository"
+)
+
+var (
+	ErrVillageNotFound = errors.New("village not found")
+	ErrInvalidTask     = errors.New("every task needs a name")
 )
 
 type BuildingService interface {
-	GetBuildingByID(id uint) (models.Building, error)
-	ListBuildings() ([]models.Building, error)
-	CreateBuilding(building models.Building) (models.Building, error)
-	DeleteBuilding(id uint) (error)
-	UpdateBuilding(building models.Building, id uint) (error)
+	GetBuildingByID(ctx context.Context, id uint) (models.Building, error)
+	GetBuilding(ctx context.Context, id uint, view BuildingView) (models.Building, error)
+	ListBuildings(ctx context.Context, filter BuildingFilter) ([]models.Building, error)
+	SummarizeBuildings(ctx context.Context, filter BuildingFilter) ([]BuildingSummary, error)
+	CreateBuilding(ctx context.Context, building models.Building) (models.Building, error)
+	DeleteBuilding(ctx context.Context, id uint) (error)
+	UpdateBuilding(ctx context.Context, building models.Building, id uint, options UpdateOptions) (error)
+	UpgradeBuilding(ctx context.Context, id uint) (models.Building, error)
+	CloneBuilding(ctx context.Context, id uint, options CloneOptions) (models.Building, error)
+	MergeBuilding(ctx context.Context, targetID uint, sourceID uint) (models.Building, error)
+}
+
+// BuildingFilter narrows ListBuildings. ID keeps one building. Categories are
+// matched by slug and a building has to carry all of them; BoundingBox keeps
+// only placed buildings whose footprint intersects it. View trims what each
+// building carries.
+type BuildingFilter struct {
+	ID          uint
+	Categories  []string
+	BoundingBox *BoundingBox
+	View        BuildingView
+}
+
+// UpdateOptions says whether an update carries the village and the rates. They
+// were added after updates were first published, so clients that predate them
+// leave them out, and what isn't sent is kept rather than cleared.
+type UpdateOptions struct {
+	SetVillage bool
+	SetRates   bool
 }
 
 type buildingService struct {
-	db *gorm.DB
+	store         repository.Store
+	buildingTypes *catalog.Catalog
 }
 
-func NewBuildingService(db *gorm.DB) BuildingService {
-	return &buildingService{db: db}
-} 
+func NewBuildingService(store repository.Store, buildingTypes *catalog.Catalog) BuildingService {
+	return &buildingService{store: store, buildingTypes: buildingTypes}
+}
 
-func (s *buildingService) ListBuildings() ([]models.Building, error) {
-	var buildings []models.Building
-	if err := s.db.Preload("Categories").Preload("Tasks").Preload("Tasks.Building").Find(&buildings).Error; err != nil {
+func (s *buildingService) ListBuildings(ctx context.Context, filter BuildingFilter) ([]models.Building, error) {
+	query := repository.BuildingQuery{
+		ID:            filter.ID,
+		CategorySlugs: categorySlugs(filter.Categories),
+		BoundingBox:   filter.BoundingBox,
+	}
+	if err := filter.View.apply(&query); err != nil {
 		return nil, err
 	}
-
-	return buildings, nil
+	return s.store.Buildings().List(ctx, query)
 }
 
-func (s *buildingService) CreateBuilding(building models.Building) (models.Building, error){
-	if err := s.db.Create(&building).Error; err != nil{
+// CreateBuilding creates a building. With a Type set, the building is
+// instantiated from that blueprint: blank fields are filled from it, its default
+// categories and starter tasks are added, and if the blueprint has levels the
+// building is not free: the first level's cost is reserved from the village and
+// it stays under construction until its construction tasks are done. Buildings
+// without levels are active straight away.
+//
+// Tasks given on the building are created with it as chores, in the same
+// transaction as the building and its categories.
+func (s *buildingService) CreateBuilding(ctx context.Context, building models.Building) (models.Building, error){
+	if err := validateRates(building.Rates); err != nil {
 		return models.Building{}, err
 	}
-	if err := s.db.Preload("Categories").First(&building, building.ID).Error; err != nil {
-		return models.Building{}, err
+	now := time.Now()
+	for index := range building.Tasks {
+		task := &building.Tasks[index]
+		if strings.TrimSpace(task.Name) == "" {
+			return models.Building{}, fmt.Errorf("%w: task %d has none", ErrInvalidTask, index+1)
+		}
+		task.ID = 0
+		task.Kind = models.TaskKindChore
+		task.CompletedAt = nil
+		if task.IsCompleted {
+			task.CompletedAt = &now
+		}
 	}
 
-	return building, nil
-}
-
-func (s *buildingService) DeleteBuilding(id uint) error{
-	result := s.db.Delete(&models.Building{}, id)
-	if result.Error != nil {
-		return result.Error
+	var buildingType catalog.BuildingType
+	if building.Type != "" {
+		var ok bool
+		if buildingType, ok = s.buildingTypes.Lookup(building.Type); !ok {
+			return models.Building{}, fmt.Errorf("%w: %q", ErrUnknownBuildingType, building.Type)
+		}
+		applyBlueprint(&building, buildingType)
+		if buildingType.MaxLevel() > 0 {
+			if building.VillageID == nil {
+				return models.Building{}, ErrVillageRequired
+			}
+			// rates come from the catalogue once a level is finished
+			building.Rates = nil
+			building.Level = 0
+			building.Status = models.BuildingStatusUnderConstruction
+		}
 	}
-	if result.RowsAffected == 0 {
-		return gorm.ErrRecordNotFound
+
+	if err := s.store.Transaction(ctx, func(transaction repository.Store) error {
+		if err := villageExists(ctx, transaction, building.VillageID); err != nil {
+			return err
+		}
+		if err := checkOverlap(ctx, transaction, building, 0); err != nil {
+			return err
+		}
+		categories, err := transaction.Categories().Resolve(ctx, building.Categories)
+		if err != nil {
+			return err
+		}
+		building.Categories = categories
+		if err := transaction.Buildings().Create(ctx, &building); err != nil {
+			return err
+		}
+		if buildingType.MaxLevel() == 0 {
+			return nil
+		}
+		return startConstruction(ctx, transaction, &building, buildingType, 1)
+	}); err != nil {
+		return models.Building{}, err
 	}
-	return nil
+	return s.GetBuildingByID(ctx, building.ID)
 }
 
-func (s *buildingService) UpdateBuilding(building models.Building, id uint) error{
-	return s.db.Transaction(func(transaction *gorm.DB) error {
-		result := transaction.
-			Model(&models.Building{}).
-			Where("id = ?", id).
-			Updates(map[string]any{
-				"name": building.Name,
-				"description": building.Description,
-				"thumbnail_path": building.ThumbnailPath,
-				"image_path": building.ImagePath,
-			})
-			if result.Error != nil {
-				return result.Error
-			}
+// DeleteBuilding removes the building along with its tasks, rates, category
+// links and the road segments ending at it.
+func (s *buildingService) DeleteBuilding(ctx context.Context, id uint) error{
+	return s.store.Buildings().Delete(ctx, id)
+}
 
-			if result.RowsAffected == 0 {
-				return gorm.ErrRecordNotFound
-			}
-		if err := transaction.
-			Where("building_id = ?", id).
-			Delete(&models.BuildingCategory{}).Error; err != nil {
-				return err
-			}
-		if len(building.Categories) > 0 {
-			for categoryIndex := range building.Categories {
-				building.Categories[categoryIndex].ID = 0
-				building.Categories[categoryIndex].BuildingID = id
-			}
-			if err := transaction.Create(&building.Categories).Error; err != nil {
-				return err
-			}
+// UpdateBuilding replaces the building's fields and categories with building's.
+// Its village and rates are only replaced when options says they are set. Roads
+// ending at the building are dropped when it moves to another village, and
+// their computed travel times follow it when it moves on the map.
+func (s *buildingService) UpdateBuilding(ctx context.Context, building models.Building, id uint, options UpdateOptions) error{
+	if err := validateRates(building.Rates); err != nil {
+		return err
+	}
+	return s.store.Transaction(ctx, func(transaction repository.Store) error {
+		existing, err := transaction.Buildings().Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		if !options.SetVillage {
+			building.VillageID = existing.VillageID
+		}
+		if err := villageExists(ctx, transaction, building.VillageID); err != nil {
+			return err
+		}
+		buildingType, typed := s.buildingTypes.Lookup(existing.Type)
+		typed = typed && buildingType.MaxLevel() > 0
+		if typed && building.VillageID == nil {
+			return ErrVillageRequired
+		}
+		if err := checkOverlap(ctx, transaction, building, id); err != nil {
+			return err
 		}
-	return nil
 
+		updated := existing
+		updated.Name = building.Name
+		updated.Description = building.Description
+		updated.ThumbnailPath = building.ThumbnailPath
+		updated.ImagePath = building.ImagePath
+		updated.VillageID = building.VillageID
+		updated.X, updated.Y = building.X, building.Y
+		updated.Width, updated.Height, updated.Rotation = building.Width, building.Height, building.Rotation
+		// links are replaced, the categories themselves are shared and keep their ids
+		if updated.Categories, err = transaction.Categories().Resolve(ctx, building.Categories); err != nil {
+			return err
+		}
+		// typed buildings take their rates from the catalogue level
+		if !typed && options.SetRates {
+			updated.Rates = building.Rates
+		}
+		return transaction.Buildings().Update(ctx, updated)
 	})
 }
 
-func (s *buildingService) GetBuildingByID(id uint) (models.Building, error) {
-	var building models.Building
-	err := s.db.
-		Preload("Categories").
-		Preload("Tasks").
-		First(&building, id).Error
+func (s *buildingService) GetBuildingByID(ctx context.Context, id uint) (models.Building, error) {
+	return s.store.Buildings().Get(ctx, id)
+}
+
+// UpgradeBuilding starts construction of the next level of a typed, active
+// building.
+func (s *buildingService) UpgradeBuilding(ctx context.Context, id uint) (models.Building, error) {
+	err := s.store.Transaction(ctx, func(transaction repository.Store) error {
+		building, err := transaction.Buildings().Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		if building.Type == "" {
+			return ErrNotUpgradable
+		}
+		if building.Status == models.BuildingStatusUnderConstruction {
+			return ErrUnderConstruction
+		}
+		buildingType, ok := s.buildingTypes.Lookup(building.Type)
+		if !ok {
+			return fmt.Errorf("%w: %q", ErrUnknownBuildingType, building.Type)
+		}
+		if buildingType.MaxLevel() == 0 {
+			return ErrNotUpgradable
+		}
+		if building.Level >= buildingType.MaxLevel() {
+			return ErrMaxLevel
+		}
+		return startConstruction(ctx, transaction, &building, buildingType, building.Level+1)
+	})
 	if err != nil {
 		return models.Building{}, err
 	}
-	return building, nil
+	return s.GetBuildingByID(ctx, id)
+}
+
+// applyBlueprint fills whatever the caller left blank from the blueprint and
+// adds its default categories and starter tasks.
+func applyBlueprint(building *models.Building, buildingType catalog.BuildingType) {
+	if building.Name == "" {
+		building.Name = buildingType.Name
+	}
+	if building.Description == "" {
+		building.Description = buildingType.Description
+	}
+	if building.ThumbnailPath == "" {
+		building.ThumbnailPath = buildingType.ThumbnailPath
+	}
+	if building.ImagePath == "" {
+		building.ImagePath = buildingType.ImagePath
+	}
+
+	for _, name := range buildingType.Categories {
+		building.Categories = append(building.Categories, models.Category{Name: name})
+	}
+
+	for _, starter := range buildingType.StarterTasks {
+		building.Tasks = append(building.Tasks, models.Task{
+			Name:        starter.Name,
+			Description: starter.Description,
+			Kind:        models.TaskKindChore,
+		})
+	}
+}
+
+func validateRates(rates []models.BuildingRate) error {
+	for _, rate := range rates {
+		if !models.IsResource(rate.Resource) {
+			return fmt.Errorf("%w: %q", ErrUnknownResource, rate.Resource)
+		}
+	}
+	return nil
+}
+
+// villageExists checks an optional village reference before it is written.
+func villageExists(ctx context.Context, store repository.Store, villageID *uint) error {
+	if villageID == nil {
+		return nil
+	}
+	if _, err := store.Villages().Get(ctx, *villageID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrVillageNotFound
+		}
+		return err
+	}
+	return nil
 }
@@ -0,0 +1,230 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/Stckrz/villageApi/internal/catalog"
+	"github.com/Stckrz/villageApi/internal/db/models"
+	"github.com/Stckrz/villageApi/internal/services/repository"
+	"github.com/Stckrz/villageApi/internal/sim"
+)
+
+func TestUpdateBuildingKeepsWhatIsNotSent(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t)
+	village, err := NewVillageService(database).CreateVillage(ctx, models.Village{Name: "Village"}, nil)
+	if err != nil {
+		t.Fatalf("create village: %v", err)
+	}
+	service := NewBuildingService(repository.NewGormStore(database), catalog.Default())
+	building, err := service.CreateBuilding(ctx, models.Building{
+		Name:      "Farm",
+		VillageID: &village.ID,
+		Rates:     []models.BuildingRate{{Resource: models.ResourceFood, PerTick: 2}},
+	})
+	if err != nil {
+		t.Fatalf("create building: %v", err)
+	}
+
+	if err := service.UpdateBuilding(ctx, models.Building{Name: "Big farm"}, building.ID, UpdateOptions{}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	updated, err := service.GetBuildingByID(ctx, building.ID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if updated.Name != "Big farm" {
+		t.Fatalf("name is %q", updated.Name)
+	}
+	if updated.VillageID == nil || *updated.VillageID != village.ID {
+		t.Fatalf("village was dropped: %v", updated.VillageID)
+	}
+	if len(updated.Rates) != 1 || updated.Rates[0].PerTick != 2 {
+		t.Fatalf("rates were dropped: %+v", updated.Rates)
+	}
+
+	if err := service.UpdateBuilding(ctx, models.Building{Name: "Big farm"}, building.ID, UpdateOptions{SetVillage: true, SetRates: true}); err != nil {
+		t.Fatalf("update clearing: %v", err)
+	}
+	if updated, err = service.GetBuildingByID(ctx, building.ID); err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if updated.VillageID != nil || len(updated.Rates) != 0 {
+		t.Fatalf("village and rates sent empty were kept: %v %+v", updated.VillageID, updated.Rates)
+	}
+}
+
+func TestAdvanceIsBoundedByLedgerEntries(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t)
+	villages := NewVillageService(database)
+	village, err := villages.CreateVillage(ctx, models.Village{Name: "Village"}, nil)
+	if err != nil {
+		t.Fatalf("create village: %v", err)
+	}
+	buildings := NewBuildingService(repository.NewGormStore(database), catalog.Default())
+	for range 20 {
+		if _, err := buildings.CreateBuilding(ctx, models.Building{
+			Name:      "Quarry",
+			VillageID: &village.ID,
+			Rates:     []models.BuildingRate{{Resource: models.ResourceStone, PerTick: 1}},
+		}); err != nil {
+			t.Fatalf("create building: %v", err)
+		}
+	}
+
+	_, err = villages.RecordAction(ctx, village.ID, models.VillageAction{Kind: sim.ActionAdvance, Payload: `{"ticks":10000}`})
+	if !errors.Is(err, sim.ErrInvalidAction) {
+		t.Fatalf("advancing 20 producers 10000 ticks: %v", err)
+	}
+	if _, err := villages.RecordAction(ctx, village.ID, models.VillageAction{Kind: sim.ActionAdvance, Payload: `{"ticks":100}`}); err != nil {
+		t.Fatalf("advancing 100 ticks: %v", err)
+	}
+}
+
+func TestBuildingServiceOnMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore()
+	village := models.Village{Name: "Village", Seed: 3, StateHash: sim.Hash(sim.State{})}
+	if err := store.Villages().Create(ctx, &village); err != nil {
+		t.Fatalf("create village: %v", err)
+	}
+	for _, payload := range []string{`{"resource":"wood","delta":100}`, `{"resource":"stone","delta":20}`} {
+		if _, err := store.Villages().Record(ctx, village.ID, models.VillageAction{Kind: sim.ActionAdjust, Payload: payload}); err != nil {
+			t.Fatalf("stock up: %v", err)
+		}
+	}
+	buildings := NewBuildingService(store, catalog.Default())
+	tasks := NewTaskService(store, catalog.Default())
+	stock := func(resource string) int64 {
+		t.Helper()
+		state, err := store.Villages().State(ctx, village.ID)
+		if err != nil {
+			t.Fatalf("state: %v", err)
+		}
+		return state.Stock[resource]
+	}
+
+	quarry, err := buildings.CreateBuilding(ctx, models.Building{
+		Type:       "quarry",
+		VillageID:  &village.ID,
+		Width:      2,
+		Height:     2,
+		Categories: []models.Category{{Name: "Stone  Works"}},
+	})
+	if err != nil {
+		t.Fatalf("create quarry: %v", err)
+	}
+	if quarry.Status != models.BuildingStatusUnderConstruction || quarry.TargetLevel != 1 || len(quarry.Tasks) != 1 {
+		t.Fatalf("quarry isn't under construction: %+v", quarry)
+	}
+	if got := stock(models.ResourceWood); got != 70 {
+		t.Fatalf("wood is %d after reserving the quarry, want 70", got)
+	}
+
+	if _, err := buildings.CreateBuilding(ctx, models.Building{Name: "Shed", VillageID: &village.ID, X: 1, Y: 1, Width: 1, Height: 1}); !errors.Is(err, ErrOverlap) {
+		t.Fatalf("shed on the quarry: got %v, want ErrOverlap", err)
+	}
+	if _, err := buildings.CreateBuilding(ctx, models.Building{Name: "Hut", X: 1, Y: 1, Width: 1, Height: 1}); err != nil {
+		t.Fatalf("a building outside the village collided with it: %v", err)
+	}
+	missing := village.ID + 1
+	if _, err := buildings.CreateBuilding(ctx, models.Building{Name: "Ghost", VillageID: &missing}); !errors.Is(err, ErrVillageNotFound) {
+		t.Fatalf("building in a missing village: got %v, want ErrVillageNotFound", err)
+	}
+
+	mill, err := buildings.CreateBuilding(ctx, models.Building{Name: "Mill", VillageID: &village.ID, Categories: []models.Category{{Name: "stone works"}}})
+	if err != nil {
+		t.Fatalf("create mill: %v", err)
+	}
+	if len(mill.Categories) != 1 || !slices.ContainsFunc(quarry.Categories, func(category models.Category) bool { return category.ID == mill.Categories[0].ID }) {
+		t.Fatalf("categories weren't shared: %+v %+v", quarry.Categories, mill.Categories)
+	}
+
+	task := quarry.Tasks[0]
+	task.IsCompleted = true
+	if err := tasks.UpdateTask(ctx, task, task.ID); err != nil {
+		t.Fatalf("complete construction: %v", err)
+	}
+	if quarry, err = buildings.UpgradeBuilding(ctx, quarry.ID); err != nil {
+		t.Fatalf("upgrade: %v", err)
+	}
+	if quarry.Level != 1 || quarry.TargetLevel != 2 || quarry.Status != models.BuildingStatusUnderConstruction {
+		t.Fatalf("quarry isn't building level 2: %+v", quarry)
+	}
+	if wood, stone := stock(models.ResourceWood), stock(models.ResourceStone); wood != 20 || stone != 0 {
+		t.Fatalf("stock is %d wood and %d stone after the upgrade, want 20 and 0", wood, stone)
+	}
+	if _, err := buildings.UpgradeBuilding(ctx, quarry.ID); !errors.Is(err, ErrUnderConstruction) {
+		t.Fatalf("upgrading twice: got %v, want ErrUnderConstruction", err)
+	}
+
+	merged, err := buildings.MergeBuilding(ctx, quarry.ID, mill.ID)
+	if err != nil {
+		t.Fatalf("merge: %v", err)
+	}
+	if len(merged.Categories) != 2 {
+		t.Fatalf("merged categories: %+v", merged.Categories)
+	}
+	if _, err := buildings.GetBuildingByID(ctx, mill.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("merged away building: got %v, want ErrNotFound", err)
+	}
+}
+
+func TestCreateBuildingWithTasks(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t)
+	village, err := NewVillageService(database).CreateVillage(ctx, models.Village{Name: "Village"}, nil)
+	if err != nil {
+		t.Fatalf("create village: %v", err)
+	}
+	service := NewBuildingService(repository.NewGormStore(database), catalog.Default())
+	count := func(model any) int64 {
+		t.Helper()
+		var count int64
+		if err := database.Model(model).Count(&count).Error; err != nil {
+			t.Fatalf("count %T: %v", model, err)
+		}
+		return count
+	}
+
+	barn, err := service.CreateBuilding(ctx, models.Building{
+		Name: "Barn",
+		Tasks: []models.Task{
+			{Name: "Sweep", Kind: models.TaskKindConstruction, EstimatedMinutes: 10},
+			{Name: "Paint", IsCompleted: true},
+		},
+	})
+	if err != nil {
+		t.Fatalf("create barn: %v", err)
+	}
+	if len(barn.Tasks) != 2 || barn.Tasks[0].BuildingId != barn.ID || barn.Tasks[0].Kind != models.TaskKindChore {
+		t.Fatalf("barn tasks %+v", barn.Tasks)
+	}
+	if !barn.Tasks[1].IsCompleted || barn.Tasks[1].CompletedAt == nil {
+		t.Fatalf("completed task %+v has no completion time", barn.Tasks[1])
+	}
+
+	// a nameless task refuses the whole building
+	if _, err := service.CreateBuilding(ctx, models.Building{
+		Name:  "Shed",
+		Tasks: []models.Task{{Name: "Oil the hinges"}, {Name: "  "}},
+	}); !errors.Is(err, ErrInvalidTask) {
+		t.Fatalf("task without a name: %v", err)
+	}
+	// so does a building failing once its tasks are written: the farm's
+	// reservation can't be covered by an empty village
+	if _, err := service.CreateBuilding(ctx, models.Building{
+		Type:      "farm",
+		VillageID: &village.ID,
+		Tasks:     []models.Task{{Name: "Mend the fence"}},
+	}); !errors.Is(err, sim.ErrInsufficientStock) {
+		t.Fatalf("farm in an empty village: %v", err)
+	}
+	if buildings, tasks := count(&models.Building{}), count(&models.Task{}); buildings != 1 || tasks != 2 {
+		t.Fatalf("%d buildings and %d tasks after the failed creates, want the barn's", buildings, tasks)
+	}
+}
@@ -0,0 +1,22 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/Stckrz/villageApi/internal/services/repository"
+)
+
+// BuildingSummary counts a building's open, completed and overdue tasks. An
+// open task is overdue once its estimate has run out since it was created.
+type BuildingSummary = repository.BuildingSummary
+
+// SummarizeBuildings counts the tasks of every building the filter matches with
+// grouped queries, so no task is read. The filter's view doesn't apply.
+func (s *buildingService) SummarizeBuildings(ctx context.Context, filter BuildingFilter) ([]BuildingSummary, error) {
+	return s.store.Buildings().Summaries(ctx, repository.BuildingQuery{
+		ID:            filter.ID,
+		CategorySlugs: categorySlugs(filter.Categories),
+		BoundingBox:   filter.BoundingBox,
+	}, time.Now())
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/Stckrz/villageApi/internal/db/models"
+	"github.com/Stckrz/villageApi/internal/services/repository"
+)
+
+var (
+	ErrUnknownField    = errors.New("unknown building field")
+	ErrUnknownRelation = errors.New("unknown building relation")
+)
+
+// BuildingFields maps each building field clients can ask for, by its name in
+// the API, to its column.
+var BuildingFields = map[string]string{
+	"villageId":     "village_id",
+	"name":          "name",
+	"description":   "description",
+	"type":          "type",
+	"thumbnailPath": "thumbnail_path",
+	"imagePath":     "image_path",
+	"level":         "level",
+	"targetLevel":   "target_level",
+	"status":        "status",
+	"x":             "x",
+	"y":             "y",
+	"width":         "width",
+	"height":        "height",
+	"rotation":      "rotation",
+	"createdAt":     "created_at",
+	"updatedAt":     "updated_at",
+}
+
+// SummaryRelation includes the building's task counts. It is worked out by
+// SummarizeBuildings rather than preloaded.
+const SummaryRelation = "summary"
+
+// BuildingRelations maps each relation clients can include, by its name in the
+// API, to the relation the repositories preload. SummaryRelation can be
+// included too.
+var BuildingRelations = map[string]string{
+	"categories": repository.RelationCategories,
+	"rates":      repository.RelationRates,
+	"tasks":      repository.RelationTasks,
+}
+
+// BuildingView picks what a building read carries, by the API's names. The
+// zero view is everything: every field and every relation. Once Include or
+// Fields is set, only what is asked for is read: the fields listed (all of them
+// when Fields is nil) and the relations listed (none when Include is nil). The
+// id is always read.
+type BuildingView struct {
+	Include []string
+	Fields  []string
+}
+
+// Full reports whether the view reads the whole building.
+func (v BuildingView) Full() bool {
+	return v.Include == nil && v.Fields == nil
+}
+
+// Summarized reports whether the view includes the summary.
+func (v BuildingView) Summarized() bool {
+	return slices.Contains(v.Include, SummaryRelation)
+}
+
+// apply translates the view into the preloads and columns of query.
+func (v BuildingView) apply(query *repository.BuildingQuery) error {
+	if v.Full() {
+		return nil
+	}
+	query.Preload = []string{}
+	for _, name := range v.Include {
+		if name == SummaryRelation {
+			continue
+		}
+		relation, ok := BuildingRelations[name]
+		if !ok {
+			return fmt.Errorf("%w: %q", ErrUnknownRelation, name)
+		}
+		query.Preload = append(query.Preload, relation)
+	}
+	if v.Fields == nil {
+		return nil
+	}
+	query.Columns = []string{}
+	for _, name := range v.Fields {
+		if name == "id" {
+			continue
+		}
+		column, ok := BuildingFields[name]
+		if !ok {
+			return fmt.Errorf("%w: %q", ErrUnknownField, name)
+		}
+		query.Columns = append(query.Columns, column)
+	}
+	return nil
+}
+
+// GetBuilding reads one building as view has it.
+func (s *buildingService) GetBuilding(ctx context.Context, id uint, view BuildingView) (models.Building, error) {
+	query := repository.BuildingQuery{ID: id}
+	if err := view.apply(&query); err != nil {
+		return models.Building{}, err
+	}
+	buildings, err := s.store.Buildings().List(ctx, query)
+	if err != nil {
+		return models.Building{}, err
+	}
+	if len(buildings) == 0 {
+		return models.Building{}, repository.ErrNotFound
+	}
+	return buildings[0], nil
+}
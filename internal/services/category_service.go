@@ -0,0 +1,137 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/Stckrz/villageApi/internal/db/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCategoryExists  = errors.New("a category with that name already exists")
+	ErrInvalidCategory = errors.New("category name must not be empty")
+	ErrMergeIntoItself = errors.New("cannot merge a category into itself")
+)
+
+type CategoryService interface {
+	ListCategories(ctx context.Context) ([]CategoryCount, error)
+	RenameCategory(ctx context.Context, id uint, name string) (models.Category, error)
+	MergeCategory(ctx context.Context, sourceID uint, targetID uint) (models.Category, error)
+}
+
+// CategoryCount is a category with the number of buildings carrying it.
+type CategoryCount struct {
+	ID        uint   `json:"id"`
+	Name      string `json:"name"`
+	Slug      string `json:"slug"`
+	Buildings int64  `json:"buildings"`
+}
+
+type categoryService struct {
+	db *gorm.DB
+}
+
+func NewCategoryService(db *gorm.DB) CategoryService {
+	return &categoryService{db: db}
+}
+
+func (s *categoryService) ListCategories(ctx context.Context) ([]CategoryCount, error) {
+	var counts []CategoryCount
+	if err := s.db.WithContext(ctx).
+		Model(&models.Category{}).
+		Select("categories.id, categories.name, categories.slug, COUNT(building_category_links.building_id) AS buildings").
+		Joins("LEFT JOIN building_category_links ON building_category_links.category_id = categories.id").
+		Group("categories.id").
+		Order("categories.slug").
+		Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// RenameCategory changes a category's name. Renaming onto the slug of another
+// category is refused; merge the two instead.
+func (s *categoryService) RenameCategory(ctx context.Context, id uint, name string) (models.Category, error) {
+	name = strings.Join(strings.Fields(name), " ")
+	slug := models.CategorySlug(name)
+	if slug == "" {
+		return models.Category{}, ErrInvalidCategory
+	}
+
+	var category models.Category
+	err := s.db.WithContext(ctx).Transaction(func(transaction *gorm.DB) error {
+		if err := transaction.First(&category, id).Error; err != nil {
+			return err
+		}
+		var clashes int64
+		if err := transaction.
+			Model(&models.Category{}).
+			Where("slug = ? AND id <> ?", slug, id).
+			Count(&clashes).Error; err != nil {
+			return err
+		}
+		if clashes > 0 {
+			return ErrCategoryExists
+		}
+
+		category.Name = name
+		category.Slug = slug
+		return transaction.
+			Model(&category).
+			Updates(map[string]any{"name": name, "slug": slug}).Error
+	})
+	if err != nil {
+		return models.Category{}, err
+	}
+	return category, nil
+}
+
+// MergeCategory moves every building link from source to target and deletes
+// source. Buildings that already carry both end up with target once.
+func (s *categoryService) MergeCategory(ctx context.Context, sourceID uint, targetID uint) (models.Category, error) {
+	if sourceID == targetID {
+		return models.Category{}, ErrMergeIntoItself
+	}
+
+	var target models.Category
+	err := s.db.WithContext(ctx).Transaction(func(transaction *gorm.DB) error {
+		if err := transaction.First(&models.Category{}, sourceID).Error; err != nil {
+			return err
+		}
+		if err := transaction.First(&target, targetID).Error; err != nil {
+			return err
+		}
+
+		if err := transaction.Exec(`
+			INSERT OR IGNORE INTO building_category_links (building_id, category_id)
+			SELECT building_id, ? FROM building_category_links WHERE category_id = ?
+		`, targetID, sourceID).Error; err != nil {
+			return err
+		}
+		if err := transaction.Exec(
+			"DELETE FROM building_category_links WHERE category_id = ?", sourceID,
+		).Error; err != nil {
+			return err
+		}
+		return transaction.Delete(&models.Category{}, sourceID).Error
+	})
+	if err != nil {
+		return models.Category{}, err
+	}
+	return target, nil
+}
+
+func categorySlugs(names []string) []string {
+	slugs := make([]string, 0, len(names))
+	seen := map[string]bool{}
+	for _, name := range names {
+		slug := models.CategorySlug(name)
+		if slug != "" && !seen[slug] {
+			seen[slug] = true
+			slugs = append(slugs, slug)
+		}
+	}
+	return slugs
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/Stckrz/villageApi/internal/catalog"
+	"github.com/Stckrz/villageApi/internal/db/models"
+	"github.com/Stckrz/villageApi/internal/services/repository"
+	"github.com/Stckrz/villageApi/internal/sim"
+)
+
+var (
+	ErrUnknownBuildingType = errors.New("unknown blueprint")
+	ErrVillageRequired     = errors.New("buildings that need construction must belong to a village")
+	ErrNotUpgradable       = errors.New("building has no levels to upgrade")
+	ErrMaxLevel            = errors.New("building is already at its highest level")
+	ErrUnderConstruction   = errors.New("building is already under construction")
+	ErrConstructionTask    = errors.New("open construction tasks can't be deleted or moved, only completed")
+)
+
+// startConstruction reserves the cost of targetLevel from the village stock,
+// marks the building as under construction and spawns the level's construction
+// tasks under it. The building only becomes active again once every one of those
+// tasks is completed.
+func startConstruction(ctx context.Context, store repository.Store, building *models.Building, buildingType catalog.BuildingType, targetLevel int) error {
+	level, ok := buildingType.LevelAt(targetLevel)
+	if !ok {
+		return ErrMaxLevel
+	}
+	if building.VillageID == nil {
+		return ErrVillageRequired
+	}
+
+	payload, err := json.Marshal(sim.ReservePayload{BuildingID: building.ID, Cost: level.Cost})
+	if err != nil {
+		return err
+	}
+	if _, err := store.Villages().Record(ctx, *building.VillageID, models.VillageAction{
+		Kind:    sim.ActionReserve,
+		Payload: string(payload),
+	}); err != nil {
+		return err
+	}
+
+	stored, err := store.Buildings().Get(ctx, building.ID)
+	if err != nil {
+		return err
+	}
+	stored.Status = models.BuildingStatusUnderConstruction
+	stored.TargetLevel = targetLevel
+	if err := store.Buildings().Update(ctx, stored); err != nil {
+		return err
+	}
+	building.Status, building.TargetLevel = stored.Status, stored.TargetLevel
+
+	names := level.Tasks
+	if len(names) == 0 {
+		names = []string{fmt.Sprintf("Build %s", buildingType.Name)}
+	}
+	// the build time is split across the tasks, any remainder going to the first
+	share := level.BuildMinutes / uint(len(names))
+	for index, name := range names {
+		minutes := share
+		if index == 0 {
+			minutes += level.BuildMinutes % uint(len(names))
+		}
+		task := models.Task{
+			Name:             name,
+			Description:      fmt.Sprintf("Construction of %s, level %d", buildingType.Name, targetLevel),
+			BuildingId:       building.ID,
+			Kind:             models.TaskKindConstruction,
+			EstimatedMinutes: minutes,
+		}
+		if err := store.Tasks().Create(ctx, &task); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// finishConstruction flips a building under construction to active at its
+// target level once none of its construction tasks are open, and swaps in that
+// level's production rates. It is only called when a task is completed; open
+// construction tasks can't be deleted or moved away, so completing them is the
+// only way to finish.
+func finishConstruction(ctx context.Context, store repository.Store, buildingTypes *catalog.Catalog, buildingID uint) error {
+	building, err := store.Buildings().Get(ctx, buildingID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	if building.Status != models.BuildingStatusUnderConstruction {
+		return nil
+	}
+
+	open := false
+	tasks, err := store.Tasks().List(ctx, repository.TaskQuery{
+		BuildingID:  &buildingID,
+		IsCompleted: &open,
+		Kind:        models.TaskKindConstruction,
+	})
+	if err != nil {
+		return err
+	}
+	if len(tasks) > 0 {
+		return nil
+	}
+
+	building.Status = models.BuildingStatusActive
+	building.Level = building.TargetLevel
+	building.TargetLevel = 0
+	if buildingType, ok := buildingTypes.Lookup(building.Type); ok {
+		if level, ok := buildingType.LevelAt(building.Level); ok {
+			building.Rates = building.Rates[:0]
+			for _, resource := range models.Resources {
+				if perTick, ok := level.Rates[resource]; ok && perTick != 0 {
+					building.Rates = append(building.Rates, models.BuildingRate{
+						Resource: resource,
+						PerTick:  perTick,
+					})
+				}
+			}
+		}
+	}
+	return store.Buildings().Update(ctx, building)
+}
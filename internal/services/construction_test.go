@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Stckrz/villageApi/internal/catalog"
+	"github.com/Stckrz/villageApi/internal/db/models"
+	"github.com/Stckrz/villageApi/internal/services/repository"
+	"github.com/Stckrz/villageApi/internal/sim"
+)
+
+func TestConstructionOnlyFinishesOnCompletion(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t)
+	village, err := NewVillageService(database).CreateVillage(ctx, models.Village{Name: "Village"}, nil)
+	if err != nil {
+		t.Fatalf("create village: %v", err)
+	}
+	buildings := NewBuildingService(repository.NewGormStore(database), catalog.Default())
+	tasks := NewTaskService(repository.NewGormStore(database), catalog.Default())
+
+	mill, err := buildings.CreateBuilding(ctx, models.Building{Type: "lumber_mill", VillageID: &village.ID})
+	if err != nil {
+		t.Fatalf("create mill: %v", err)
+	}
+	other, err := buildings.CreateBuilding(ctx, models.Building{Name: "Shed", VillageID: &village.ID})
+	if err != nil {
+		t.Fatalf("create shed: %v", err)
+	}
+	open := false
+	construction, err := tasks.ListTasks(ctx, TaskFilter{BuildingID: &mill.ID, IsCompleted: &open, Kind: models.TaskKindConstruction})
+	if err != nil || len(construction) != 1 {
+		t.Fatalf("construction tasks: %v %+v", err, construction)
+	}
+	task := construction[0]
+
+	if err := tasks.DeleteTask(ctx, task.ID); !errors.Is(err, ErrConstructionTask) {
+		t.Fatalf("deleting an open construction task: %v", err)
+	}
+	moved := models.Task{Name: task.Name, BuildingId: other.ID}
+	if err := tasks.UpdateTask(ctx, moved, task.ID); !errors.Is(err, ErrConstructionTask) {
+		t.Fatalf("moving an open construction task: %v", err)
+	}
+	report, err := tasks.BulkTasks(ctx, []BulkTaskOperation{{Op: BulkDelete, ID: task.ID}})
+	if !errors.Is(err, ErrBulkFailed) || report.Committed {
+		t.Fatalf("bulk deleting an open construction task: %v %+v", err, report)
+	}
+	if mill, err = buildings.GetBuildingByID(ctx, mill.ID); err != nil || mill.Status != models.BuildingStatusUnderConstruction {
+		t.Fatalf("mill finished without work: %v %+v", err, mill)
+	}
+
+	completed := models.Task{Name: task.Name, BuildingId: mill.ID, IsCompleted: true}
+	if err := tasks.UpdateTask(ctx, completed, task.ID); err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+	if mill, err = buildings.GetBuildingByID(ctx, mill.ID); err != nil {
+		t.Fatalf("get mill: %v", err)
+	}
+	if mill.Status != models.BuildingStatusActive || mill.Level != 1 || len(mill.Rates) != 1 {
+		t.Fatalf("mill not finished on completion: %+v", mill)
+	}
+	if err := tasks.DeleteTask(ctx, task.ID); err != nil {
+		t.Fatalf("deleting a completed construction task: %v", err)
+	}
+}
+
+func TestRecordActionRefusesReservations(t *testing.T) {
+	ctx := context.Background()
+	villages := NewVillageService(newTestDB(t))
+	village, err := villages.CreateVillage(ctx, models.Village{Name: "Village"}, nil)
+	if err != nil {
+		t.Fatalf("create village: %v", err)
+	}
+	_, err = villages.RecordAction(ctx, village.ID, models.VillageAction{Kind: sim.ActionReserve, Payload: `{"buildingId":1,"cost":{}}`})
+	if !errors.Is(err, sim.ErrInvalidAction) {
+		t.Fatalf("recording a reservation: %v", err)
+	}
+}
@@ -0,0 +1,20 @@
+package services
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/Stckrz/villageApi/internal/config"
+	"github.com/Stckrz/villageApi/internal/db"
+	"gorm.io/gorm"
+)
+
+// newTestDB is a migrated database of the test's own.
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	database, err := db.ConnectDb(config.DB{Path: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	return database
+}
@@ -0,0 +1,293 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/Stckrz/villageApi/internal/db/models"
+	"github.com/Stckrz/villageApi/internal/services/repository"
+	"github.com/Stckrz/villageApi/internal/tiled"
+	"gorm.io/gorm"
+)
+
+const (
+	ImportCreate    = "create"
+	ImportUpdate    = "update"
+	ImportUnchanged = "unchanged"
+)
+
+var ErrDuplicateImportName = errors.New("map has more than one building with the same name")
+
+// errDryRun rolls back an import that was only meant to be previewed.
+var errDryRun = errors.New("dry run")
+
+type ImportService interface {
+	ImportTiled(ctx context.Context, villageID *uint, tiledMap tiled.Map, commit bool) (ImportReport, error)
+}
+
+// FieldChange is one field an import would change on an existing building.
+type FieldChange struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+type ImportChange struct {
+	Action     string        `json:"action"`
+	BuildingID uint          `json:"buildingId,omitempty"`
+	Name       string        `json:"name"`
+	Fields     []FieldChange `json:"fields,omitempty"`
+}
+
+type ImportSkip struct {
+	ObjectID int    `json:"objectId"`
+	Layer    string `json:"layer"`
+	Reason   string `json:"reason"`
+}
+
+// ImportReport is the diff between a map and the village. Buildings of the
+// village that the map doesn't mention are listed in Untouched; an import never
+// deletes anything.
+type ImportReport struct {
+	VillageID *uint          `json:"villageId"`
+	Committed bool           `json:"committed"`
+	Created   int            `json:"created"`
+	Updated   int            `json:"updated"`
+	Unchanged int            `json:"unchanged"`
+	Changes   []ImportChange `json:"changes"`
+	Skipped   []ImportSkip   `json:"skipped"`
+	Untouched []string       `json:"untouched"`
+}
+
+type importService struct {
+	db *gorm.DB
+}
+
+func NewImportService(db *gorm.DB) ImportService {
+	return &importService{db: db}
+}
+
+// ImportTiled turns the named objects of a map into buildings of the village,
+// matching existing buildings by name. Every object becomes a footprint in
+// tiles; the "description", "categories" (comma separated), "imagePath" and
+// "thumbnailPath" properties and the object class fill in the rest, and only
+// overwrite what is already there when the map sets them.
+//
+// Without commit the import runs in a transaction that is rolled back, so the
+// report, overlap errors included, is exactly what committing would do.
+func (s *importService) ImportTiled(ctx context.Context, villageID *uint, tiledMap tiled.Map, commit bool) (ImportReport, error) {
+	report := ImportReport{
+		VillageID: villageID,
+		Changes:   []ImportChange{},
+		Skipped:   []ImportSkip{},
+		Untouched: []string{},
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(transaction *gorm.DB) error {
+		if err := villageExists(ctx, repository.NewGormStore(transaction), villageID); err != nil {
+			return err
+		}
+
+		query := transaction.Preload("Categories")
+		if villageID == nil {
+			query = query.Where("village_id IS NULL")
+		} else {
+			query = query.Where("village_id = ?", *villageID)
+		}
+		var existing []models.Building
+		if err := query.Order("id").Find(&existing).Error; err != nil {
+			return err
+		}
+		byName := make(map[string]models.Building, len(existing))
+		for _, building := range existing {
+			byName[building.Name] = building
+		}
+
+		seen := map[string]bool{}
+		var imported []models.Building
+		for _, object := range tiledMap.Objects {
+			if object.Name == "" {
+				report.Skipped = append(report.Skipped, ImportSkip{ObjectID: object.ID, Layer: object.Layer, Reason: "object has no name"})
+				continue
+			}
+			if seen[object.Name] {
+				return fmt.Errorf("%w: %q", ErrDuplicateImportName, object.Name)
+			}
+			seen[object.Name] = true
+
+			building, err := tiledBuilding(tiledMap, object)
+			if err != nil {
+				report.Skipped = append(report.Skipped, ImportSkip{ObjectID: object.ID, Layer: object.Layer, Reason: err.Error()})
+				continue
+			}
+			building.VillageID = villageID
+
+			current, found := byName[object.Name]
+			if !found {
+				categories, err := repository.NewGormStore(transaction).Categories().Resolve(ctx, building.Categories)
+				if err != nil {
+					return err
+				}
+				building.Categories = categories
+				if err := transaction.Create(&building).Error; err != nil {
+					return err
+				}
+				imported = append(imported, building)
+				report.Created++
+				report.Changes = append(report.Changes, ImportChange{Action: ImportCreate, Name: building.Name})
+				continue
+			}
+
+			change, err := updateFromTiled(ctx, transaction, current, building, object)
+			if err != nil {
+				return err
+			}
+			current.X, current.Y = building.X, building.Y
+			current.Width, current.Height, current.Rotation = building.Width, building.Height, building.Rotation
+			imported = append(imported, current)
+			if change.Action == ImportUpdate {
+				report.Updated++
+			} else {
+				report.Unchanged++
+			}
+			report.Changes = append(report.Changes, change)
+		}
+
+		for _, building := range existing {
+			if !seen[building.Name] {
+				report.Untouched = append(report.Untouched, building.Name)
+			}
+		}
+
+		// overlaps are checked once everything has moved, so buildings can swap places
+		for _, building := range imported {
+			if err := checkOverlap(ctx, repository.NewGormStore(transaction), building, building.ID); err != nil {
+				return fmt.Errorf("%w: %q", err, building.Name)
+			}
+		}
+
+		if !commit {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return ImportReport{}, err
+	}
+	report.Committed = commit
+	return report, nil
+}
+
+// tiledBuilding converts an object from pixels to tiles, rounding to the nearest
+// tile.
+func tiledBuilding(tiledMap tiled.Map, object tiled.Object) (models.Building, error) {
+	rotation := int(math.Round(object.Rotation))
+	rotation = ((rotation % 360) + 360) % 360
+
+	building := models.Building{
+		Name:          object.Name,
+		Description:   object.Properties["description"],
+		ThumbnailPath: object.Properties["thumbnailPath"],
+		ImagePath:     object.Properties["imagePath"],
+		X:             int(math.Round(object.X / float64(tiledMap.TileWidth))),
+		Y:             int(math.Round(object.Y / float64(tiledMap.TileHeight))),
+		Width:         int(math.Round(object.Width / float64(tiledMap.TileWidth))),
+		Height:        int(math.Round(object.Height / float64(tiledMap.TileHeight))),
+		Rotation:      rotation,
+		Categories:    tiledCategories(object),
+	}
+	if err := validatePlacement(building); err != nil {
+		return models.Building{}, err
+	}
+	return building, nil
+}
+
+// tiledCategories is the object class followed by its "categories" property.
+func tiledCategories(object tiled.Object) []models.Category {
+	var categories []models.Category
+	if object.Class != "" {
+		categories = append(categories, models.Category{Name: object.Class})
+	}
+	for _, name := range strings.Split(object.Properties["categories"], ",") {
+		if strings.TrimSpace(name) != "" {
+			categories = append(categories, models.Category{Name: name})
+		}
+	}
+	return categories
+}
+
+// updateFromTiled applies the map's version of a building over the stored one
+// and reports which fields moved.
+func updateFromTiled(ctx context.Context, transaction *gorm.DB, current models.Building, building models.Building, object tiled.Object) (ImportChange, error) {
+	change := ImportChange{Action: ImportUnchanged, BuildingID: current.ID, Name: current.Name}
+	updates := map[string]any{}
+	compare := func(field string, column string, from any, to any) {
+		if fmt.Sprint(from) != fmt.Sprint(to) {
+			change.Fields = append(change.Fields, FieldChange{Field: field, From: fmt.Sprint(from), To: fmt.Sprint(to)})
+			updates[column] = to
+		}
+	}
+
+	compare("x", "x", current.X, building.X)
+	compare("y", "y", current.Y, building.Y)
+	compare("width", "width", current.Width, building.Width)
+	compare("height", "height", current.Height, building.Height)
+	compare("rotation", "rotation", current.Rotation, building.Rotation)
+	if _, ok := object.Properties["description"]; ok {
+		compare("description", "description", current.Description, building.Description)
+	}
+	if _, ok := object.Properties["thumbnailPath"]; ok {
+		compare("thumbnailPath", "thumbnail_path", current.ThumbnailPath, building.ThumbnailPath)
+	}
+	if _, ok := object.Properties["imagePath"]; ok {
+		compare("imagePath", "image_path", current.ImagePath, building.ImagePath)
+	}
+
+	if len(updates) > 0 {
+		if err := transaction.
+			Model(&models.Building{}).
+			Where("id = ?", current.ID).
+			Updates(updates).Error; err != nil {
+			return ImportChange{}, err
+		}
+		if err := repository.FollowRoads(transaction, current.ID); err != nil {
+			return ImportChange{}, err
+		}
+	}
+
+	// categories are only replaced when the map says something about them
+	if len(building.Categories) > 0 {
+		categories, err := repository.NewGormStore(transaction).Categories().Resolve(ctx, building.Categories)
+		if err != nil {
+			return ImportChange{}, err
+		}
+		from, to := categoryNames(current.Categories), categoryNames(categories)
+		if from != to {
+			change.Fields = append(change.Fields, FieldChange{Field: "categories", From: from, To: to})
+			if err := transaction.
+				Model(&models.Building{ID: current.ID}).
+				Association("Categories").
+				Replace(categories); err != nil {
+				return ImportChange{}, err
+			}
+		}
+	}
+
+	if len(change.Fields) > 0 {
+		change.Action = ImportUpdate
+	}
+	return change, nil
+}
+
+func categoryNames(categories []models.Category) string {
+	names := make([]string, 0, len(categories))
+	for _, category := range categories {
+		names = append(names, category.Name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
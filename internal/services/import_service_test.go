@@ -0,0 +1,101 @@
+package services
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/Stckrz/villageApi/internal/catalog"
+	"github.com/Stckrz/villageApi/internal/db/models"
+	"github.com/Stckrz/villageApi/internal/services/repository"
+	"github.com/Stckrz/villageApi/internal/tiled"
+)
+
+func TestImportTiledDryRunMatchesCommit(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t)
+	villages := NewVillageService(database)
+	buildings := NewBuildingService(repository.NewGormStore(database), catalog.Default())
+	imports := NewImportService(database)
+
+	village, err := villages.CreateVillage(ctx, models.Village{Name: "Village"}, nil)
+	if err != nil {
+		t.Fatalf("create village: %v", err)
+	}
+	well, err := buildings.CreateBuilding(ctx, models.Building{
+		Name: "Well", Description: "Deep", VillageID: &village.ID, Width: 1, Height: 1,
+	})
+	if err != nil {
+		t.Fatalf("create well: %v", err)
+	}
+	if _, err := buildings.CreateBuilding(ctx, models.Building{Name: "Shed", VillageID: &village.ID, X: 30}); err != nil {
+		t.Fatalf("create shed: %v", err)
+	}
+
+	tiledMap := tiled.Map{
+		TileWidth:  16,
+		TileHeight: 16,
+		Objects: []tiled.Object{
+			// 40 and 30 pixels round to 3 and 2 tiles
+			{ID: 1, Name: "Hall", Class: "civic", X: 40, Y: 32, Width: 30, Height: 32, Properties: map[string]string{"categories": "admin"}},
+			// without a description property the stored one is kept
+			{ID: 2, Name: "Well", X: 160, Y: 0, Width: 16, Height: 16, Properties: map[string]string{}},
+			{ID: 3, Layer: "Buildings", Width: 16, Height: 16},
+		},
+	}
+
+	dryRun, err := imports.ImportTiled(ctx, &village.ID, tiledMap, false)
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if dryRun.Committed || dryRun.Created != 1 || dryRun.Updated != 1 || len(dryRun.Skipped) != 1 {
+		t.Fatalf("dry run report %+v", dryRun)
+	}
+	if !reflect.DeepEqual(dryRun.Untouched, []string{"Shed"}) {
+		t.Fatalf("untouched %v", dryRun.Untouched)
+	}
+	var count int64
+	if err := database.Model(&models.Building{}).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Fatalf("dry run left %d buildings, want 2", count)
+	}
+	if stored, err := buildings.GetBuildingByID(ctx, well.ID); err != nil || stored.X != 0 {
+		t.Fatalf("dry run moved the well: %+v, %v", stored, err)
+	}
+	if err := database.Model(&models.Category{}).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Fatalf("dry run left %d categories", count)
+	}
+
+	committed, err := imports.ImportTiled(ctx, &village.ID, tiledMap, true)
+	if err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	if !committed.Committed {
+		t.Fatalf("commit report %+v", committed)
+	}
+	committed.Committed = false
+	if !reflect.DeepEqual(committed, dryRun) {
+		t.Fatalf("commit reported\n%+v\ndry run reported\n%+v", committed, dryRun)
+	}
+
+	list, err := buildings.ListBuildings(ctx, BuildingFilter{})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	byName := map[string]models.Building{}
+	for _, building := range list {
+		byName[building.Name] = building
+	}
+	hall := byName["Hall"]
+	if hall.X != 3 || hall.Y != 2 || hall.Width != 2 || hall.Height != 2 || len(hall.Categories) != 2 {
+		t.Fatalf("imported hall %+v", hall)
+	}
+	if moved := byName["Well"]; moved.X != 10 || moved.Description != "Deep" {
+		t.Fatalf("updated well %+v", moved)
+	}
+}
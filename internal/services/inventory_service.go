@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Stckrz/villageApi/internal/db/models"
+	"gorm.io/gorm"
+)
+
+var ErrUnknownResource = errors.New("unknown resource")
+
+type InventoryService interface {
+	GetStock(ctx context.Context, villageID uint) ([]StockLevel, error)
+	ListHistory(ctx context.Context, villageID uint, filter InventoryHistoryFilter) ([]models.InventoryEntry, error)
+}
+
+// StockLevel is the current amount of one resource. Quantity is summed from the
+// ledger deltas and Balance is the running total on the newest entry; Audited is
+// false when the two disagree.
+type StockLevel struct {
+	Resource string `json:"resource"`
+	Quantity int64  `json:"quantity"`
+	Balance  int64  `json:"balance"`
+	Entries  int64  `json:"entries"`
+	Audited  bool   `json:"audited"`
+}
+
+type InventoryHistoryFilter struct {
+	Resource string
+	Limit    int
+	Offset   int
+}
+
+type inventoryService struct {
+	db *gorm.DB
+}
+
+func NewInventoryService(db *gorm.DB) InventoryService {
+	return &inventoryService{db: db}
+}
+
+func (s *inventoryService) GetStock(ctx context.Context, villageID uint) ([]StockLevel, error) {
+	if err := s.db.WithContext(ctx).First(&models.Village{}, villageID).Error; err != nil {
+		return nil, err
+	}
+
+	var totals []struct {
+		Resource string
+		Quantity int64
+		Entries  int64
+		LastID   uint
+	}
+	if err := s.db.WithContext(ctx).
+		Model(&models.InventoryEntry{}).
+		Select("resource, SUM(delta) AS quantity, COUNT(*) AS entries, MAX(id) AS last_id").
+		Where("village_id = ?", villageID).
+		Group("resource").
+		Scan(&totals).Error; err != nil {
+		return nil, err
+	}
+
+	lastIDs := make([]uint, 0, len(totals))
+	for _, total := range totals {
+		lastIDs = append(lastIDs, total.LastID)
+	}
+	var lastEntries []models.InventoryEntry
+	if len(lastIDs) > 0 {
+		if err := s.db.WithContext(ctx).Where("id IN ?", lastIDs).Find(&lastEntries).Error; err != nil {
+			return nil, err
+		}
+	}
+	balances := map[string]int64{}
+	for _, entry := range lastEntries {
+		balances[entry.Resource] = entry.Balance
+	}
+
+	levels := make([]StockLevel, 0, len(models.Resources))
+	for _, resource := range models.Resources {
+		level := StockLevel{Resource: resource, Audited: true}
+		for _, total := range totals {
+			if total.Resource == resource {
+				level.Quantity = total.Quantity
+				level.Entries = total.Entries
+				level.Balance = balances[resource]
+				level.Audited = total.Quantity == level.Balance && total.Quantity >= 0
+			}
+		}
+		levels = append(levels, level)
+	}
+	return levels, nil
+}
+
+// ListHistory returns ledger entries newest first.
+func (s *inventoryService) ListHistory(ctx context.Context, villageID uint, filter InventoryHistoryFilter) ([]models.InventoryEntry, error) {
+	if err := s.db.WithContext(ctx).First(&models.Village{}, villageID).Error; err != nil {
+		return nil, err
+	}
+
+	query := s.db.WithContext(ctx).Where("village_id = ?", villageID)
+	if filter.Resource != "" {
+		if !models.IsResource(filter.Resource) {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownResource, filter.Resource)
+		}
+		query = query.Where("resource = ?", filter.Resource)
+	}
+	if filter.Limit <= 0 || filter.Limit > 500 {
+		filter.Limit = 100
+	}
+
+	var entries []models.InventoryEntry
+	if err := query.
+		Order("id DESC").
+		Limit(filter.Limit).
+		Offset(filter.Offset).
+		Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
@@ -0,0 +1,184 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Stckrz/villageApi/internal/db/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type MetricHistoryService interface {
+	TakeSnapshot(ctx context.Context, now time.Time) error
+	PruneSnapshots(ctx context.Context, before time.Time) (int64, error)
+	History(ctx context.Context, query MetricHistoryQuery) ([]MetricSeries, error)
+}
+
+// MetricHistoryQuery picks the days of history, From to To inclusive, for one
+// village or, with VillageID 0, the whole database. Empty Metrics returns every
+// metric recorded.
+type MetricHistoryQuery struct {
+	From      time.Time
+	To        time.Time
+	VillageID uint
+	Metrics   []string
+}
+
+type MetricPoint struct {
+	Day   string `json:"day"`
+	Value int64  `json:"value"`
+}
+
+// MetricSeries is one metric's snapshots, oldest first. Days without a snapshot
+// are left out.
+type MetricSeries struct {
+	Metric string        `json:"metric"`
+	Points []MetricPoint `json:"points"`
+}
+
+type metricHistoryService struct {
+	db *gorm.DB
+}
+
+func NewMetricHistoryService(db *gorm.DB) MetricHistoryService {
+	return &metricHistoryService{db: db}
+}
+
+// TakeSnapshot records today's building count, open and completed tasks and, per
+// village, resource stock, overwriting what was recorded earlier in the day. A
+// village with nothing to count gets zeros, so its series have no holes.
+func (s *metricHistoryService) TakeSnapshot(ctx context.Context, now time.Time) error {
+	day := now.UTC().Format(time.DateOnly)
+	return s.db.WithContext(ctx).Transaction(func(transaction *gorm.DB) error {
+		values := map[uint]map[string]int64{0: {}}
+		var villageIDs []uint
+		if err := transaction.Model(&models.Village{}).Order("id").Pluck("id", &villageIDs).Error; err != nil {
+			return err
+		}
+		for _, villageID := range villageIDs {
+			values[villageID] = map[string]int64{}
+			for _, resource := range models.Resources {
+				values[villageID][models.MetricResourcePrefix+resource] = 0
+			}
+		}
+		for villageID := range values {
+			for _, metric := range []string{models.MetricBuildings, models.MetricOpenTasks, models.MetricCompletedTasks} {
+				values[villageID][metric] = 0
+			}
+		}
+		// add counts toward a village and the whole database
+		add := func(villageID *uint, metric string, value int64) {
+			if villageID != nil {
+				if village, ok := values[*villageID]; ok {
+					village[metric] += value
+				}
+			}
+			values[0][metric] += value
+		}
+
+		var buildings []struct {
+			VillageID *uint
+			Count     int64
+		}
+		if err := transaction.
+			Model(&models.Building{}).
+			Select("village_id, COUNT(*) AS count").
+			Group("village_id").
+			Scan(&buildings).Error; err != nil {
+			return err
+		}
+		for _, row := range buildings {
+			add(row.VillageID, models.MetricBuildings, row.Count)
+		}
+
+		var tasks []struct {
+			VillageID   *uint
+			IsCompleted bool
+			Count       int64
+		}
+		if err := transaction.
+			Table("tasks").
+			Select("buildings.village_id AS village_id, tasks.is_completed AS is_completed, COUNT(*) AS count").
+			Joins("JOIN buildings ON buildings.id = tasks.building_id").
+			Group("buildings.village_id, tasks.is_completed").
+			Scan(&tasks).Error; err != nil {
+			return err
+		}
+		for _, row := range tasks {
+			metric := models.MetricOpenTasks
+			if row.IsCompleted {
+				metric = models.MetricCompletedTasks
+			}
+			add(row.VillageID, metric, row.Count)
+		}
+
+		var stock []struct {
+			VillageID uint
+			Resource  string
+			Quantity  int64
+		}
+		if err := transaction.
+			Model(&models.InventoryEntry{}).
+			Select("village_id, resource, SUM(delta) AS quantity").
+			Group("village_id, resource").
+			Scan(&stock).Error; err != nil {
+			return err
+		}
+		for _, row := range stock {
+			if village, ok := values[row.VillageID]; ok {
+				village[models.MetricResourcePrefix+row.Resource] = row.Quantity
+			}
+		}
+
+		snapshots := []models.MetricSnapshot{}
+		for villageID, metrics := range values {
+			for metric, value := range metrics {
+				snapshots = append(snapshots, models.MetricSnapshot{Day: day, VillageID: villageID, Metric: metric, Value: value})
+			}
+		}
+		return transaction.
+			Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "day"}, {Name: "village_id"}, {Name: "metric"}},
+				DoUpdates: clause.AssignmentColumns([]string{"value", "updated_at"}),
+			}).
+			CreateInBatches(&snapshots, 200).Error
+	})
+}
+
+// PruneSnapshots deletes the snapshots of days before before.
+func (s *metricHistoryService) PruneSnapshots(ctx context.Context, before time.Time) (int64, error) {
+	result := s.db.WithContext(ctx).
+		Where("day < ?", before.UTC().Format(time.DateOnly)).
+		Delete(&models.MetricSnapshot{})
+	return result.RowsAffected, result.Error
+}
+
+func (s *metricHistoryService) History(ctx context.Context, query MetricHistoryQuery) ([]MetricSeries, error) {
+	from, to := query.From.UTC().Format(time.DateOnly), query.To.UTC().Format(time.DateOnly)
+	if to < from {
+		return nil, fmt.Errorf("%w: from is after to", ErrInvalidStatsRange)
+	}
+
+	statement := s.db.WithContext(ctx).
+		Where("village_id = ?", query.VillageID).
+		Where("day BETWEEN ? AND ?", from, to)
+	if len(query.Metrics) > 0 {
+		statement = statement.Where("metric IN ?", query.Metrics)
+	}
+	var snapshots []models.MetricSnapshot
+	if err := statement.Order("metric, day").Find(&snapshots).Error; err != nil {
+		return nil, err
+	}
+
+	series := []MetricSeries{}
+	for _, snapshot := range snapshots {
+		if len(series) == 0 || series[len(series)-1].Metric != snapshot.Metric {
+			series = append(series, MetricSeries{Metric: snapshot.Metric, Points: []MetricPoint{}})
+		}
+		last := &series[len(series)-1]
+		last.Points = append(last.Points, MetricPoint{Day: snapshot.Day, Value: snapshot.Value})
+	}
+	return series, nil
+}
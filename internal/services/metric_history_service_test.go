@@ -0,0 +1,109 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Stckrz/villageApi/internal/catalog"
+	"github.com/Stckrz/villageApi/internal/db/models"
+	"github.com/Stckrz/villageApi/internal/services/repository"
+)
+
+func TestTakeSnapshotOverwritesTheDay(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t)
+	history := NewMetricHistoryService(database)
+	village, err := NewVillageService(database).CreateVillage(ctx, models.Village{Name: "Village"}, nil)
+	if err != nil {
+		t.Fatalf("create village: %v", err)
+	}
+	morning := time.Date(2026, 3, 5, 8, 0, 0, 0, time.UTC)
+	query := MetricHistoryQuery{From: morning, To: morning, VillageID: village.ID}
+
+	// an empty village still gets every series, at zero
+	if err := history.TakeSnapshot(ctx, morning); err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	series, err := history.History(ctx, query)
+	if err != nil {
+		t.Fatalf("history: %v", err)
+	}
+	want := map[string][]MetricPoint{
+		models.MetricBuildings:      {{Day: "2026-03-05", Value: 0}},
+		models.MetricCompletedTasks: {{Day: "2026-03-05", Value: 0}},
+		models.MetricOpenTasks:      {{Day: "2026-03-05", Value: 0}},
+	}
+	for _, resource := range models.Resources {
+		want[models.MetricResourcePrefix+resource] = []MetricPoint{{Day: "2026-03-05", Value: 0}}
+	}
+	if got := seriesByMetric(series); !reflect.DeepEqual(got, want) {
+		t.Fatalf("empty village history %v, want %v", got, want)
+	}
+
+	buildings := NewBuildingService(repository.NewGormStore(database), catalog.Default())
+	if _, err := buildings.CreateBuilding(ctx, models.Building{
+		Name:      "Barn",
+		VillageID: &village.ID,
+		Tasks:     []models.Task{{Name: "Sweep"}, {Name: "Paint", IsCompleted: true}},
+	}); err != nil {
+		t.Fatalf("create building: %v", err)
+	}
+	if err := history.TakeSnapshot(ctx, morning.Add(10*time.Hour)); err != nil {
+		t.Fatalf("evening snapshot: %v", err)
+	}
+	query.Metrics = []string{models.MetricBuildings, models.MetricOpenTasks, models.MetricCompletedTasks}
+	if series, err = history.History(ctx, query); err != nil {
+		t.Fatalf("history: %v", err)
+	}
+	want = map[string][]MetricPoint{
+		models.MetricBuildings:      {{Day: "2026-03-05", Value: 1}},
+		models.MetricCompletedTasks: {{Day: "2026-03-05", Value: 1}},
+		models.MetricOpenTasks:      {{Day: "2026-03-05", Value: 1}},
+	}
+	if got := seriesByMetric(series); !reflect.DeepEqual(got, want) {
+		t.Fatalf("history after the evening snapshot %v, want %v", got, want)
+	}
+
+	if _, err := history.History(ctx, MetricHistoryQuery{From: morning, To: morning.AddDate(0, 0, -1)}); !errors.Is(err, ErrInvalidStatsRange) {
+		t.Fatalf("history from after to: %v", err)
+	}
+}
+
+func TestPruneSnapshots(t *testing.T) {
+	ctx := context.Background()
+	history := NewMetricHistoryService(newTestDB(t))
+	first := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for day := 0; day < 3; day++ {
+		if err := history.TakeSnapshot(ctx, first.AddDate(0, 0, day)); err != nil {
+			t.Fatalf("snapshot: %v", err)
+		}
+	}
+
+	// the cut-off is a day: everything before the 3rd goes, whatever the hour
+	pruned, err := history.PruneSnapshots(ctx, first.AddDate(0, 0, 2).Add(6*time.Hour))
+	if err != nil {
+		t.Fatalf("prune: %v", err)
+	}
+	// buildings, open and completed tasks for the whole database, twice
+	if pruned != 6 {
+		t.Fatalf("pruned %d snapshots, want 6", pruned)
+	}
+	series, err := history.History(ctx, MetricHistoryQuery{From: first, To: first.AddDate(0, 0, 2), Metrics: []string{models.MetricBuildings}})
+	if err != nil {
+		t.Fatalf("history: %v", err)
+	}
+	if got, want := seriesByMetric(series), map[string][]MetricPoint{models.MetricBuildings: {{Day: "2026-03-03"}}}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("history after pruning %v, want %v", got, want)
+	}
+}
+
+func seriesByMetric(series []MetricSeries) map[string][]MetricPoint {
+	byMetric := map[string][]MetricPoint{}
+	for _, one := range series {
+		byMetric[one.Metric] = one.Points
+	}
+	return byMetric
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Stckrz/villageApi/internal/db/models"
+	"github.com/Stckrz/villageApi/internal/services/repository"
+)
+
+var (
+	ErrInvalidPlacement = errors.New("invalid placement: width and height must not be negative and rotation must be 0, 90, 180 or 270")
+	ErrOverlap          = errors.New("placement overlaps another building")
+)
+
+// BoundingBox is a viewport on the map grid, corners inclusive of X1, Y1 and
+// exclusive of X2, Y2.
+type BoundingBox = repository.BoundingBox
+
+func validatePlacement(building models.Building) error {
+	if building.Width < 0 || building.Height < 0 {
+		return ErrInvalidPlacement
+	}
+	switch building.Rotation {
+	case 0, 90, 180, 270:
+		return nil
+	default:
+		return ErrInvalidPlacement
+	}
+}
+
+// checkOverlap refuses a placement that collides with another placed building
+// of the same village. excludeID is the building being moved, 0 on create.
+func checkOverlap(ctx context.Context, store repository.Store, building models.Building, excludeID uint) error {
+	if err := validatePlacement(building); err != nil {
+		return err
+	}
+	if !building.Placed() {
+		return nil
+	}
+
+	width, height := building.Extent()
+	others, err := store.Buildings().List(ctx, repository.BuildingQuery{
+		BoundingBox: &BoundingBox{
+			X1: building.X,
+			Y1: building.Y,
+			X2: building.X + width,
+			Y2: building.Y + height,
+		},
+		Preload: []string{},
+		Columns: []string{"village_id"},
+	})
+	if err != nil {
+		return err
+	}
+	for _, other := range others {
+		if other.ID != excludeID && sameVillage(other.VillageID, building.VillageID) {
+			return ErrOverlap
+		}
+	}
+	return nil
+}
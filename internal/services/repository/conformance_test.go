@@ -0,0 +1,654 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Stckrz/villageApi/internal/config"
+	"github.com/Stckrz/villageApi/internal/db"
+	"github.com/Stckrz/villageApi/internal/db/models"
+	"github.com/Stckrz/villageApi/internal/sim"
+)
+
+// Every store runs the same suite, so the memory store can stand in for the
+// gorm one wherever services are tested.
+
+func TestGormStore(t *testing.T) {
+	runConformance(t, func(t *testing.T) Store {
+		database, err := db.ConnectDb(config.DB{Path: filepath.Join(t.TempDir(), "test.db")})
+		if err != nil {
+			t.Fatalf("connect: %v", err)
+		}
+		if err := database.Create(&models.Village{Name: "Village"}).Error; err != nil {
+			t.Fatalf("create village: %v", err)
+		}
+		return NewGormStore(database)
+	})
+}
+
+func TestMemoryStore(t *testing.T) {
+	runConformance(t, func(t *testing.T) Store {
+		return NewMemoryStore()
+	})
+}
+
+func runConformance(t *testing.T, newStore func(t *testing.T) Store) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, store Store)
+	}{
+		{"CreateAndGetBuilding", testCreateAndGetBuilding},
+		{"MissingBuilding", testMissingBuilding},
+		{"ListBuildings", testListBuildings},
+		{"ListBuildingsTrimmed", testListBuildingsTrimmed},
+		{"BuildingSummaries", testBuildingSummaries},
+		{"UpdateBuilding", testUpdateBuilding},
+		{"DeleteBuildingCascades", testDeleteBuildingCascades},
+		{"TaskLifecycle", testTaskLifecycle},
+		{"TaskNeedsBuilding", testTaskNeedsBuilding},
+		{"ListTasks", testListTasks},
+		{"ResolveCategories", testResolveCategories},
+		{"RecordVillageActions", testRecordVillageActions},
+		{"Transaction", testTransaction},
+		{"CancelledContext", testCancelledContext},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.run(t, newStore(t))
+		})
+	}
+}
+
+// villageID is the village the gorm store is seeded with; the memory store
+// doesn't check village references.
+var villageID = uint(1)
+
+func newBuilding(name string) models.Building {
+	return models.Building{
+		Name:        name,
+		Description: name + " description",
+		VillageID:   &villageID,
+		Categories:  []models.Category{{Name: name + " Category", Slug: models.CategorySlug(name + " Category")}},
+		Rates:       []models.BuildingRate{{Resource: models.ResourceWood, PerTick: 2}},
+		Tasks: []models.Task{
+			{Name: "first", Description: "first task"},
+			{Name: "second", Description: "second task", Kind: models.TaskKindConstruction},
+		},
+	}
+}
+
+func createBuilding(t *testing.T, store Store, building models.Building) models.Building {
+	t.Helper()
+	if err := store.Buildings().Create(context.Background(), &building); err != nil {
+		t.Fatalf("create building %q: %v", building.Name, err)
+	}
+	return building
+}
+
+func testCreateAndGetBuilding(t *testing.T, store Store) {
+	ctx := context.Background()
+	created := createBuilding(t, store, newBuilding("Farm"))
+	if created.ID == 0 {
+		t.Fatal("created building has no id")
+	}
+	other := createBuilding(t, store, newBuilding("Mill"))
+	if other.ID == created.ID {
+		t.Fatalf("both buildings got id %d", created.ID)
+	}
+
+	building, err := store.Buildings().Get(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if building.Name != "Farm" || building.Description != "Farm description" {
+		t.Errorf("got %q / %q", building.Name, building.Description)
+	}
+	if building.VillageID == nil || *building.VillageID != villageID {
+		t.Errorf("village = %v, want %d", building.VillageID, villageID)
+	}
+	if building.Status != models.BuildingStatusActive {
+		t.Errorf("status = %q, want the %q default", building.Status, models.BuildingStatusActive)
+	}
+	if building.CreatedAt.IsZero() || building.UpdatedAt.IsZero() {
+		t.Error("timestamps not set")
+	}
+	if len(building.Categories) != 1 || building.Categories[0].Slug != "farm category" || building.Categories[0].ID == 0 {
+		t.Errorf("categories = %+v", building.Categories)
+	}
+	if len(building.Rates) != 1 || building.Rates[0].Resource != models.ResourceWood || building.Rates[0].PerTick != 2 {
+		t.Errorf("rates = %+v", building.Rates)
+	}
+	if len(building.Tasks) != 2 {
+		t.Fatalf("got %d tasks, want 2", len(building.Tasks))
+	}
+	first, second := building.Tasks[0], building.Tasks[1]
+	if first.Name != "first" || second.Name != "second" || first.ID >= second.ID {
+		t.Errorf("tasks not in id order: %+v", building.Tasks)
+	}
+	if first.BuildingId != created.ID || first.Kind != models.TaskKindChore || second.Kind != models.TaskKindConstruction {
+		t.Errorf("tasks = %+v", building.Tasks)
+	}
+}
+
+func testMissingBuilding(t *testing.T, store Store) {
+	ctx := context.Background()
+	if _, err := store.Buildings().Get(ctx, 999); !errors.Is(err, ErrNotFound) {
+		t.Errorf("get: got %v, want ErrNotFound", err)
+	}
+	if err := store.Buildings().Update(ctx, models.Building{ID: 999, Name: "ghost"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("update: got %v, want ErrNotFound", err)
+	}
+	if err := store.Buildings().Delete(ctx, 999); !errors.Is(err, ErrNotFound) {
+		t.Errorf("delete: got %v, want ErrNotFound", err)
+	}
+}
+
+func testListBuildings(t *testing.T, store Store) {
+	ctx := context.Background()
+	farm := newBuilding("Farm")
+	farm.X, farm.Y, farm.Width, farm.Height = 0, 0, 2, 2
+	farm = createBuilding(t, store, farm)
+
+	// a quarter turn makes the 1x4 mill a 4x1 strip along y = 10
+	mill := newBuilding("Mill")
+	mill.Categories = append(mill.Categories, farm.Categories[0])
+	mill.X, mill.Y, mill.Width, mill.Height, mill.Rotation = 10, 10, 1, 4, 90
+	mill = createBuilding(t, store, mill)
+
+	unplaced := createBuilding(t, store, newBuilding("Shed"))
+
+	ids := func(buildings []models.Building) []uint {
+		var ids []uint
+		for _, building := range buildings {
+			ids = append(ids, building.ID)
+		}
+		return ids
+	}
+	for _, test := range []struct {
+		name  string
+		query BuildingQuery
+		want  []uint
+	}{
+		{"everything", BuildingQuery{}, []uint{farm.ID, mill.ID, unplaced.ID}},
+		{"id", BuildingQuery{ID: mill.ID}, []uint{mill.ID}},
+		{"name", BuildingQuery{Name: "Mill"}, []uint{mill.ID}},
+		{"one category", BuildingQuery{CategorySlugs: []string{"farm category"}}, []uint{farm.ID, mill.ID}},
+		{"every category", BuildingQuery{CategorySlugs: []string{"farm category", "mill category"}}, []uint{mill.ID}},
+		{"unknown category", BuildingQuery{CategorySlugs: []string{"nothing"}}, nil},
+		{"box over the farm", BuildingQuery{BoundingBox: &BoundingBox{X1: 1, Y1: 1, X2: 5, Y2: 5}}, []uint{farm.ID}},
+		{"box over the rotated mill", BuildingQuery{BoundingBox: &BoundingBox{X1: 13, Y1: 10, X2: 20, Y2: 11}}, []uint{mill.ID}},
+		{"box past the rotated mill", BuildingQuery{BoundingBox: &BoundingBox{X1: 10, Y1: 11, X2: 20, Y2: 20}}, nil},
+	} {
+		buildings, err := store.Buildings().List(ctx, test.query)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if got := ids(buildings); !slices.Equal(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+
+	buildings, err := store.Buildings().List(ctx, BuildingQuery{Name: "Farm"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buildings) != 1 || len(buildings[0].Tasks) != 2 || len(buildings[0].Rates) != 1 || len(buildings[0].Categories) != 1 {
+		t.Errorf("listed building is missing associations: %+v", buildings)
+	}
+}
+
+func testListBuildingsTrimmed(t *testing.T, store Store) {
+	ctx := context.Background()
+	farm := newBuilding("Farm")
+	farm.X, farm.Width, farm.Height = 3, 2, 2
+	farm = createBuilding(t, store, farm)
+
+	buildings, err := store.Buildings().List(ctx, BuildingQuery{
+		Name:    "Farm",
+		Preload: []string{RelationCategories},
+		Columns: []string{"name", "x"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buildings) != 1 {
+		t.Fatalf("got %d buildings, want 1", len(buildings))
+	}
+	building := buildings[0]
+	if building.ID != farm.ID || building.Name != "Farm" || building.X != 3 {
+		t.Errorf("selected columns not read: %+v", building)
+	}
+	if building.Description != "" || building.Width != 0 || building.VillageID != nil {
+		t.Errorf("unselected columns read: %+v", building)
+	}
+	if len(building.Categories) != 1 || building.Rates != nil || building.Tasks != nil {
+		t.Errorf("got categories %v, rates %v, tasks %v; want only categories", building.Categories, building.Rates, building.Tasks)
+	}
+
+	if buildings, err = store.Buildings().List(ctx, BuildingQuery{Preload: []string{}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(buildings) != 1 || buildings[0].Categories != nil || buildings[0].Tasks != nil || buildings[0].Description == "" {
+		t.Errorf("empty preload should read every column and no relations: %+v", buildings)
+	}
+}
+
+func testBuildingSummaries(t *testing.T, store Store) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+	lastActivity := now.Add(-time.Minute)
+
+	farm := newBuilding("Farm")
+	farm.Tasks = []models.Task{
+		{Name: "sow", Description: "late", EstimatedMinutes: 60, CreatedAt: now.Add(-2 * time.Hour), UpdatedAt: now.Add(-2 * time.Hour)},
+		{Name: "weed", Description: "in time", EstimatedMinutes: 60, CreatedAt: now.Add(-time.Hour / 2), UpdatedAt: lastActivity},
+		{Name: "rest", Description: "no estimate", CreatedAt: now.Add(-48 * time.Hour), UpdatedAt: now.Add(-48 * time.Hour)},
+		{Name: "reap", Description: "done", EstimatedMinutes: 1, IsCompleted: true, CreatedAt: now.Add(-3 * time.Hour), UpdatedAt: now.Add(-3 * time.Hour)},
+	}
+	farm = createBuilding(t, store, farm)
+	empty := newBuilding("Shed")
+	empty.Tasks = nil
+	empty = createBuilding(t, store, empty)
+
+	summaries, err := store.Buildings().Summaries(ctx, BuildingQuery{}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summaries) != 2 {
+		t.Fatalf("got %d summaries, want 2", len(summaries))
+	}
+	got := summaries[0]
+	if got.BuildingID != farm.ID || got.Open != 3 || got.Completed != 1 || got.Overdue != 1 {
+		t.Errorf("farm summary = %+v", got)
+	}
+	if got.LastActivity == nil || !got.LastActivity.Equal(lastActivity) {
+		t.Errorf("last activity = %v, want %v", got.LastActivity, lastActivity)
+	}
+	if got := summaries[1]; got.BuildingID != empty.ID || got.Open != 0 || got.Completed != 0 || got.LastActivity != nil {
+		t.Errorf("empty summary = %+v", got)
+	}
+
+	if summaries, err = store.Buildings().Summaries(ctx, BuildingQuery{CategorySlugs: []string{"shed category"}}, now); err != nil {
+		t.Fatal(err)
+	}
+	if len(summaries) != 1 || summaries[0].BuildingID != empty.ID {
+		t.Errorf("filtered summaries = %+v", summaries)
+	}
+}
+
+func testUpdateBuilding(t *testing.T, store Store) {
+	ctx := context.Background()
+	farm := createBuilding(t, store, newBuilding("Farm"))
+	mill := createBuilding(t, store, newBuilding("Mill"))
+
+	farm.Name = "Big Farm"
+	farm.Level = 2
+	farm.Status = models.BuildingStatusUnderConstruction
+	farm.Width, farm.Height = 3, 3
+	farm.Rates = []models.BuildingRate{{Resource: models.ResourceFood, PerTick: 5}, {Resource: models.ResourceGold, PerTick: -1}}
+	farm.Categories = []models.Category{mill.Categories[0]}
+	farm.Tasks = nil
+	if err := store.Buildings().Update(ctx, farm); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	building, err := store.Buildings().Get(ctx, farm.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if building.Name != "Big Farm" || building.Level != 2 || building.Status != models.BuildingStatusUnderConstruction || building.Width != 3 {
+		t.Errorf("fields not saved: %+v", building)
+	}
+	if len(building.Rates) != 2 || building.Rates[0].Resource != models.ResourceFood || building.Rates[1].PerTick != -1 {
+		t.Errorf("rates not replaced: %+v", building.Rates)
+	}
+	if len(building.Categories) != 1 || building.Categories[0].ID != mill.Categories[0].ID {
+		t.Errorf("categories not replaced: %+v", building.Categories)
+	}
+	if len(building.Tasks) != 2 {
+		t.Errorf("update touched the tasks: %+v", building.Tasks)
+	}
+
+	building.Categories = nil
+	building.Rates = nil
+	if err := store.Buildings().Update(ctx, building); err != nil {
+		t.Fatal(err)
+	}
+	if building, err = store.Buildings().Get(ctx, farm.ID); err != nil {
+		t.Fatal(err)
+	}
+	if len(building.Categories) != 0 || len(building.Rates) != 0 {
+		t.Errorf("categories and rates not cleared: %+v / %+v", building.Categories, building.Rates)
+	}
+
+	// the category itself is shared and stays with the mill
+	if mill, err = store.Buildings().Get(ctx, mill.ID); err != nil {
+		t.Fatal(err)
+	}
+	if len(mill.Categories) != 1 {
+		t.Errorf("mill lost its category: %+v", mill.Categories)
+	}
+}
+
+func testDeleteBuildingCascades(t *testing.T, store Store) {
+	ctx := context.Background()
+	farm := createBuilding(t, store, newBuilding("Farm"))
+	mill := createBuilding(t, store, newBuilding("Mill"))
+
+	if err := store.Buildings().Delete(ctx, farm.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := store.Buildings().Get(ctx, farm.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("get after delete: got %v, want ErrNotFound", err)
+	}
+	for _, task := range farm.Tasks {
+		if _, err := store.Tasks().Get(ctx, task.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("task %d outlived its building: %v", task.ID, err)
+		}
+	}
+	tasks, err := store.Tasks().List(ctx, TaskQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != len(mill.Tasks) {
+		t.Errorf("got %d tasks, want only the mill's %d", len(tasks), len(mill.Tasks))
+	}
+	if err := store.Buildings().Delete(ctx, farm.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second delete: got %v, want ErrNotFound", err)
+	}
+
+	// a new building reusing the category doesn't inherit the deleted links
+	barn := newBuilding("Barn")
+	barn.Categories = farm.Categories
+	barn = createBuilding(t, store, barn)
+	listed, err := store.Buildings().List(ctx, BuildingQuery{CategorySlugs: []string{"farm category"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 1 || listed[0].ID != barn.ID {
+		t.Errorf("category lists %+v, want only the barn", listed)
+	}
+}
+
+func testTaskLifecycle(t *testing.T, store Store) {
+	ctx := context.Background()
+	farm := createBuilding(t, store, newBuilding("Farm"))
+	mill := createBuilding(t, store, newBuilding("Mill"))
+
+	task := models.Task{Name: "weed", Description: "pull weeds", BuildingId: farm.ID, EstimatedMinutes: 30}
+	if err := store.Tasks().Create(ctx, &task); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if task.ID == 0 || task.Kind != models.TaskKindChore {
+		t.Errorf("created task = %+v", task)
+	}
+
+	got, err := store.Tasks().Get(ctx, task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "weed" || got.EstimatedMinutes != 30 || got.IsCompleted || got.CompletedAt != nil || got.CreatedAt.IsZero() {
+		t.Errorf("stored task = %+v", got)
+	}
+	if got.Building.ID != 0 {
+		t.Error("task came with its building")
+	}
+
+	completedAt := time.Now().UTC().Truncate(time.Second)
+	got.Name = "weed again"
+	got.BuildingId = mill.ID
+	got.Kind = models.TaskKindConstruction
+	got.IsCompleted = true
+	got.CompletedAt = &completedAt
+	if err := store.Tasks().Update(ctx, got); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if got, err = store.Tasks().Get(ctx, task.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "weed again" || got.BuildingId != mill.ID || got.Kind != models.TaskKindConstruction || !got.IsCompleted {
+		t.Errorf("update not saved: %+v", got)
+	}
+	if got.CompletedAt == nil || !got.CompletedAt.Equal(completedAt) {
+		t.Errorf("completed at = %v, want %v", got.CompletedAt, completedAt)
+	}
+
+	got.IsCompleted = false
+	got.CompletedAt = nil
+	if err := store.Tasks().Update(ctx, got); err != nil {
+		t.Fatal(err)
+	}
+	if got, err = store.Tasks().Get(ctx, task.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got.IsCompleted || got.CompletedAt != nil {
+		t.Errorf("completion not cleared: %+v", got)
+	}
+
+	if err := store.Tasks().Delete(ctx, task.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := store.Tasks().Get(ctx, task.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("get after delete: got %v, want ErrNotFound", err)
+	}
+	if err := store.Tasks().Delete(ctx, task.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second delete: got %v, want ErrNotFound", err)
+	}
+	if err := store.Tasks().Update(ctx, got); !errors.Is(err, ErrNotFound) {
+		t.Errorf("update after delete: got %v, want ErrNotFound", err)
+	}
+}
+
+func testTaskNeedsBuilding(t *testing.T, store Store) {
+	ctx := context.Background()
+	task := models.Task{Name: "orphan", BuildingId: 999}
+	if err := store.Tasks().Create(ctx, &task); !errors.Is(err, ErrMissingBuilding) {
+		t.Errorf("create: got %v, want ErrMissingBuilding", err)
+	}
+
+	farm := createBuilding(t, store, newBuilding("Farm"))
+	moved := farm.Tasks[0]
+	moved.BuildingId = 999
+	if err := store.Tasks().Update(ctx, moved); !errors.Is(err, ErrMissingBuilding) {
+		t.Errorf("update: got %v, want ErrMissingBuilding", err)
+	}
+	got, err := store.Tasks().Get(ctx, moved.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.BuildingId != farm.ID {
+		t.Errorf("refused update moved the task to %d", got.BuildingId)
+	}
+}
+
+func testListTasks(t *testing.T, store Store) {
+	ctx := context.Background()
+	farm := createBuilding(t, store, newBuilding("Farm"))
+	homeless := newBuilding("Camp")
+	homeless.VillageID = nil
+	camp := createBuilding(t, store, homeless)
+
+	done := farm.Tasks[0]
+	done.IsCompleted = true
+	if err := store.Tasks().Update(ctx, done); err != nil {
+		t.Fatal(err)
+	}
+
+	completed, open := true, false
+	for _, test := range []struct {
+		name  string
+		query TaskQuery
+		want  []uint
+	}{
+		{"everything", TaskQuery{}, []uint{farm.Tasks[0].ID, farm.Tasks[1].ID, camp.Tasks[0].ID, camp.Tasks[1].ID}},
+		{"building", TaskQuery{BuildingID: &camp.ID}, []uint{camp.Tasks[0].ID, camp.Tasks[1].ID}},
+		{"village", TaskQuery{VillageID: &villageID}, []uint{farm.Tasks[0].ID, farm.Tasks[1].ID}},
+		{"completed", TaskQuery{IsCompleted: &completed}, []uint{farm.Tasks[0].ID}},
+		{"open construction", TaskQuery{IsCompleted: &open, Kind: models.TaskKindConstruction}, []uint{farm.Tasks[1].ID, camp.Tasks[1].ID}},
+	} {
+		tasks, err := store.Tasks().List(ctx, test.query)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		var got []uint
+		for _, task := range tasks {
+			got = append(got, task.ID)
+		}
+		if !slices.Equal(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func testResolveCategories(t *testing.T, store Store) {
+	ctx := context.Background()
+	resolved, err := store.Categories().Resolve(ctx, []models.Category{{Name: " Farm  Land "}, {Name: "farm land"}, {Name: " "}, {Name: "Mill"}})
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if len(resolved) != 2 || resolved[0].Name != "Farm Land" || resolved[0].Slug != "farm land" || resolved[1].Slug != "mill" {
+		t.Fatalf("unexpected categories %+v", resolved)
+	}
+	if resolved[0].ID == 0 || resolved[0].ID == resolved[1].ID {
+		t.Fatalf("categories without their own ids: %+v", resolved)
+	}
+
+	again, err := store.Categories().Resolve(ctx, []models.Category{{Name: "FARM LAND"}})
+	if err != nil {
+		t.Fatalf("resolve again: %v", err)
+	}
+	if len(again) != 1 || again[0].ID != resolved[0].ID || again[0].Name != "Farm Land" {
+		t.Fatalf("an existing category was not reused: %+v", again)
+	}
+}
+
+func testRecordVillageActions(t *testing.T, store Store) {
+	ctx := context.Background()
+	village := models.Village{Name: "Hamlet", Seed: 7, StateHash: sim.Hash(sim.State{})}
+	if err := store.Villages().Create(ctx, &village); err != nil {
+		t.Fatalf("create village: %v", err)
+	}
+	if got, err := store.Villages().Get(ctx, village.ID); err != nil || got.Name != "Hamlet" || got.Seed != 7 {
+		t.Fatalf("get village: %v %+v", err, got)
+	}
+	farm := newBuilding("Farm")
+	farm.VillageID = &village.ID
+	farm = createBuilding(t, store, farm)
+	site := newBuilding("Site")
+	site.VillageID = &village.ID
+	site.Status = models.BuildingStatusUnderConstruction
+	createBuilding(t, store, site)
+
+	// whatever producers the client sends, the active buildings' rates are used
+	actions := []sim.Action{
+		{Kind: sim.ActionAdjust, Payload: `{"resource":"food","delta":10}`},
+		{Kind: sim.ActionAdvance, Payload: `{"ticks":30,"producers":[{"buildingId":99,"rates":{"gold":5}}]}`},
+	}
+	for _, action := range actions {
+		recorded, err := store.Villages().Record(ctx, village.ID, models.VillageAction{Kind: action.Kind, Payload: action.Payload})
+		if err != nil {
+			t.Fatalf("record %s: %v", action.Kind, err)
+		}
+		village = recorded
+	}
+	actions[1].Payload = fmt.Sprintf(`{"ticks":30,"producers":[{"buildingId":%d,"rates":{"wood":2}}]}`, farm.ID)
+	replayed, err := sim.Replay(village.Seed, actions)
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if village.Tick != 30 || village.StateHash != sim.Hash(replayed) {
+		t.Fatalf("recorded tick %d hash %s, replayed tick %d hash %s", village.Tick, village.StateHash, replayed.Tick, sim.Hash(replayed))
+	}
+	state, err := store.Villages().State(ctx, village.ID)
+	if err != nil {
+		t.Fatalf("state: %v", err)
+	}
+	if sim.Hash(state) != village.StateHash {
+		t.Fatalf("saved state hashes to %s, village says %s", sim.Hash(state), village.StateHash)
+	}
+
+	reserve := models.VillageAction{Kind: sim.ActionReserve, Payload: `{"buildingId":1,"cost":{"gold":1000}}`}
+	if _, err := store.Villages().Record(ctx, village.ID, reserve); !errors.Is(err, sim.ErrInsufficientStock) {
+		t.Fatalf("reserving more than there is: got %v, want ErrInsufficientStock", err)
+	}
+	if state, err = store.Villages().State(ctx, village.ID); err != nil || sim.Hash(state) != village.StateHash {
+		t.Fatalf("a refused action changed the state: %v", err)
+	}
+	if _, err := store.Villages().Record(ctx, village.ID+100, models.VillageAction{Kind: actions[0].Kind, Payload: actions[0].Payload}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("recording on a missing village: got %v, want ErrNotFound", err)
+	}
+}
+
+func testTransaction(t *testing.T, store Store) {
+	ctx := context.Background()
+	errAbort := errors.New("abort")
+
+	var kept, discarded models.Building
+	err := store.Transaction(ctx, func(store Store) error {
+		kept = createBuilding(t, store, newBuilding("Kept"))
+		innerErr := store.Transaction(ctx, func(store Store) error {
+			discarded = createBuilding(t, store, newBuilding("Discarded"))
+			return errAbort
+		})
+		if !errors.Is(innerErr, errAbort) {
+			t.Errorf("inner transaction: got %v, want errAbort", innerErr)
+		}
+		// reads inside a transaction see its own writes
+		if _, err := store.Buildings().Get(ctx, kept.ID); err != nil {
+			t.Errorf("transaction can't read its own write: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("transaction: %v", err)
+	}
+	if _, err := store.Buildings().Get(ctx, kept.ID); err != nil {
+		t.Errorf("committed building: %v", err)
+	}
+	if _, err := store.Buildings().Get(ctx, discarded.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("building of the failed inner transaction: got %v, want ErrNotFound", err)
+	}
+
+	var rolledBack models.Building
+	err = store.Transaction(ctx, func(store Store) error {
+		rolledBack = createBuilding(t, store, newBuilding("Rolled Back"))
+		if err := store.Buildings().Delete(ctx, kept.ID); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("transaction: got %v, want errAbort", err)
+	}
+	if _, err := store.Buildings().Get(ctx, rolledBack.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("building of the failed transaction: got %v, want ErrNotFound", err)
+	}
+	building, err := store.Buildings().Get(ctx, kept.ID)
+	if err != nil {
+		t.Fatalf("delete was not rolled back: %v", err)
+	}
+	if len(building.Tasks) != 2 {
+		t.Errorf("cascade was not rolled back: %d tasks", len(building.Tasks))
+	}
+}
+
+func testCancelledContext(t *testing.T, store Store) {
+	farm := createBuilding(t, store, newBuilding("Farm"))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := store.Buildings().Get(ctx, farm.ID); err == nil {
+		t.Error("get ran with a cancelled context")
+	}
+	if err := store.Tasks().Delete(ctx, farm.Tasks[0].ID); err == nil {
+		t.Error("delete ran with a cancelled context")
+	}
+	if _, err := store.Tasks().Get(context.Background(), farm.Tasks[0].ID); err != nil {
+		t.Errorf("task deleted despite the cancelled context: %v", err)
+	}
+}
@@ -0,0 +1,618 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/Stckrz/villageApi/internal/db/models"
+	"github.com/Stckrz/villageApi/internal/sim"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// extentSQL is the rotated footprint size as SQL, mirroring models.Building.Extent.
+const (
+	extentWidthSQL  = "(CASE WHEN rotation IN (90, 270) THEN height ELSE width END)"
+	extentHeightSQL = "(CASE WHEN rotation IN (90, 270) THEN width ELSE height END)"
+)
+
+// Intersecting narrows a buildings query to placed buildings whose footprint
+// overlaps box.
+func Intersecting(query *gorm.DB, box BoundingBox) *gorm.DB {
+	return query.
+		Where("width > 0 AND height > 0").
+		Where("x < ? AND x + "+extentWidthSQL+" > ?", box.X2, box.X1).
+		Where("y < ? AND y + "+extentHeightSQL+" > ?", box.Y2, box.Y1)
+}
+
+type gormStore struct {
+	db *gorm.DB
+}
+
+// NewGormStore returns a store on db. db may be a transaction, which lets code
+// already inside one use the repositories as part of it.
+func NewGormStore(db *gorm.DB) Store {
+	return &gormStore{db: db}
+}
+
+func (s *gormStore) Buildings() BuildingRepository {
+	return gormBuildings{db: s.db}
+}
+
+func (s *gormStore) Tasks() TaskRepository {
+	return gormTasks{db: s.db}
+}
+
+func (s *gormStore) Categories() CategoryRepository {
+	return gormCategories{db: s.db}
+}
+
+func (s *gormStore) Villages() VillageRepository {
+	return gormVillages{db: s.db}
+}
+
+func (s *gormStore) Transaction(ctx context.Context, fn func(store Store) error) error {
+	return s.db.WithContext(ctx).Transaction(func(transaction *gorm.DB) error {
+		return fn(&gormStore{db: transaction})
+	})
+}
+
+type gormBuildings struct {
+	db *gorm.DB
+}
+
+func (r gormBuildings) aggregate(ctx context.Context) *gorm.DB {
+	return r.preload(ctx, []string{RelationCategories, RelationRates, RelationTasks})
+}
+
+// preload reads buildings with the given relations, each ordered by id.
+func (r gormBuildings) preload(ctx context.Context, relations []string) *gorm.DB {
+	statement := r.db.WithContext(ctx)
+	for _, relation := range relations {
+		switch relation {
+		case RelationCategories:
+			statement = statement.Preload(relation, func(db *gorm.DB) *gorm.DB { return db.Order("categories.id") })
+		default:
+			statement = statement.Preload(relation, func(db *gorm.DB) *gorm.DB { return db.Order("id") })
+		}
+	}
+	return statement
+}
+
+func (r gormBuildings) Get(ctx context.Context, id uint) (models.Building, error) {
+	var building models.Building
+	if err := r.aggregate(ctx).First(&building, id).Error; err != nil {
+		return models.Building{}, err
+	}
+	return building, nil
+}
+
+func (r gormBuildings) List(ctx context.Context, query BuildingQuery) ([]models.Building, error) {
+	statement := r.aggregate(ctx)
+	if query.Preload != nil {
+		statement = r.preload(ctx, query.Preload)
+	}
+	if query.Columns != nil {
+		// the id is needed to attach preloaded relations
+		statement = statement.Select(append([]string{"id"}, query.Columns...))
+	}
+
+	var buildings []models.Building
+	if err := r.filter(ctx, statement, query).Order("id").Find(&buildings).Error; err != nil {
+		return nil, err
+	}
+	return buildings, nil
+}
+
+// filter narrows a buildings statement to the ones query matches.
+func (r gormBuildings) filter(ctx context.Context, statement *gorm.DB, query BuildingQuery) *gorm.DB {
+	if query.ID != 0 {
+		statement = statement.Where("id = ?", query.ID)
+	}
+	if query.Name != "" {
+		statement = statement.Where("name = ?", query.Name)
+	}
+	if len(query.CategorySlugs) > 0 {
+		statement = statement.Where("id IN (?)", r.db.WithContext(ctx).
+			Table("building_category_links").
+			Select("building_category_links.building_id").
+			Joins("JOIN categories ON categories.id = building_category_links.category_id").
+			Where("categories.slug IN ?", query.CategorySlugs).
+			Group("building_category_links.building_id").
+			Having("COUNT(DISTINCT categories.id) = ?", len(query.CategorySlugs)))
+	}
+	if query.BoundingBox != nil {
+		statement = Intersecting(statement, *query.BoundingBox)
+	}
+	return statement
+}
+
+func (r gormBuildings) Summaries(ctx context.Context, query BuildingQuery, now time.Time) ([]BuildingSummary, error) {
+	db := r.db.WithContext(ctx)
+	matching := r.filter(ctx, db.Model(&models.Building{}).Select("id"), query)
+
+	// timestamps are compared as unix seconds, which SQLite can work out from
+	// the stored text
+	var rows []struct {
+		BuildingID   uint
+		Open         int64
+		Completed    int64
+		Overdue      int64
+		LastActivity *int64
+	}
+	if err := db.
+		Table("buildings").
+		Select(`buildings.id AS building_id,
+			SUM(CASE WHEN NOT tasks.is_completed THEN 1 ELSE 0 END) AS open,
+			SUM(CASE WHEN tasks.is_completed THEN 1 ELSE 0 END) AS completed,
+			SUM(CASE WHEN NOT tasks.is_completed AND tasks.estimated_minutes > 0
+				AND CAST(strftime('%s', tasks.created_at) AS INTEGER) + tasks.estimated_minutes * 60 < ?
+				THEN 1 ELSE 0 END) AS overdue,
+			MAX(CAST(strftime('%s', tasks.updated_at) AS INTEGER)) AS last_activity`, now.Unix()).
+		Joins("LEFT JOIN tasks ON tasks.building_id = buildings.id").
+		Where("buildings.id IN (?)", matching).
+		Group("buildings.id").
+		Order("buildings.id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	summaries := make([]BuildingSummary, 0, len(rows))
+	for _, row := range rows {
+		summary := BuildingSummary{
+			BuildingID: row.BuildingID,
+			Open:       row.Open,
+			Completed:  row.Completed,
+			Overdue:    row.Overdue,
+		}
+		if row.LastActivity != nil {
+			lastActivity := time.Unix(*row.LastActivity, 0)
+			summary.LastActivity = &lastActivity
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, nil
+}
+
+func (r gormBuildings) Create(ctx context.Context, building *models.Building) error {
+	building.ID = 0
+	if building.Status == "" {
+		building.Status = models.BuildingStatusActive
+	}
+	for index := range building.Rates {
+		building.Rates[index].ID = 0
+	}
+	for index := range building.Tasks {
+		building.Tasks[index].ID = 0
+		building.Tasks[index].Building = models.Building{}
+		if building.Tasks[index].Kind == "" {
+			building.Tasks[index].Kind = models.TaskKindChore
+		}
+	}
+	return r.db.WithContext(ctx).Create(building).Error
+}
+
+func (r gormBuildings) Update(ctx context.Context, building models.Building) error {
+	return r.db.WithContext(ctx).Transaction(func(transaction *gorm.DB) error {
+		result := transaction.
+			Model(&models.Building{}).
+			Where("id = ?", building.ID).
+			Updates(map[string]any{
+				"village_id":     building.VillageID,
+				"name":           building.Name,
+				"description":    building.Description,
+				"thumbnail_path": building.ThumbnailPath,
+				"image_path":     building.ImagePath,
+				"type":           building.Type,
+				"level":          building.Level,
+				"target_level":   building.TargetLevel,
+				"status":         building.Status,
+				"x":              building.X,
+				"y":              building.Y,
+				"width":          building.Width,
+				"height":         building.Height,
+				"rotation":       building.Rotation,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+
+		if err := transaction.Where("building_id = ?", building.ID).Delete(&models.BuildingRate{}).Error; err != nil {
+			return err
+		}
+		if len(building.Rates) > 0 {
+			rates := make([]models.BuildingRate, 0, len(building.Rates))
+			for _, rate := range building.Rates {
+				rates = append(rates, models.BuildingRate{BuildingID: building.ID, Resource: rate.Resource, PerTick: rate.PerTick})
+			}
+			if err := transaction.Create(&rates).Error; err != nil {
+				return err
+			}
+		}
+
+		categories := transaction.Model(&models.Building{ID: building.ID}).Association("Categories")
+		if len(building.Categories) == 0 {
+			if err := categories.Clear(); err != nil {
+				return err
+			}
+		} else if err := categories.Replace(building.Categories); err != nil {
+			return err
+		}
+		return FollowRoads(transaction, building.ID)
+	})
+}
+
+func (r gormBuildings) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Transaction(func(transaction *gorm.DB) error {
+		result := transaction.Delete(&models.Building{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		// foreign keys aren't enforced, so the cascade is done by hand
+		for _, dependent := range []any{&models.Task{}, &models.BuildingRate{}} {
+			if err := transaction.Where("building_id = ?", id).Delete(dependent).Error; err != nil {
+				return err
+			}
+		}
+		if err := transaction.Exec("DELETE FROM building_category_links WHERE building_id = ?", id).Error; err != nil {
+			return err
+		}
+		return transaction.
+			Where("from_building_id = ? OR to_building_id = ?", id, id).
+			Delete(&models.RoadSegment{}).Error
+	})
+}
+
+// FollowRoads keeps the road segments ending at a building in step with it once
+// it has changed. Segments are dropped when the building has left their
+// village, since roads stay within one, and the computed travel times of the
+// rest are worked out again from where it now stands; a segment with an end
+// that is off the map keeps the time it has.
+func FollowRoads(db *gorm.DB, buildingID uint) error {
+	var building models.Building
+	if err := db.First(&building, buildingID).Error; err != nil {
+		return err
+	}
+	var segments []models.RoadSegment
+	if err := db.
+		Where("from_building_id = ? OR to_building_id = ?", buildingID, buildingID).
+		Find(&segments).Error; err != nil {
+		return err
+	}
+
+	for _, segment := range segments {
+		if !sameVillage(segment.VillageID, building.VillageID) {
+			if err := db.Delete(&models.RoadSegment{}, segment.ID).Error; err != nil {
+				return err
+			}
+			continue
+		}
+		if !segment.TravelComputed {
+			continue
+		}
+		fromX, fromY, fromPlaced, err := roadEnd(db, segment.FromBuildingID, segment.FromX, segment.FromY)
+		if err != nil {
+			return err
+		}
+		toX, toY, toPlaced, err := roadEnd(db, segment.ToBuildingID, segment.ToX, segment.ToY)
+		if err != nil {
+			return err
+		}
+		if !fromPlaced || !toPlaced {
+			continue
+		}
+		if err := db.
+			Model(&models.RoadSegment{}).
+			Where("id = ?", segment.ID).
+			Update("travel_minutes", math.Hypot(toX-fromX, toY-fromY)*models.MinutesPerTile).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// roadEnd is where one end of a segment sits on the map, placed being false for
+// a building that isn't on it.
+func roadEnd(db *gorm.DB, buildingID *uint, x int, y int) (float64, float64, bool, error) {
+	if buildingID == nil {
+		return float64(x), float64(y), true, nil
+	}
+	var building models.Building
+	if err := db.First(&building, *buildingID).Error; err != nil {
+		return 0, 0, false, err
+	}
+	if !building.Placed() {
+		return 0, 0, false, nil
+	}
+	centreX, centreY := building.Centre()
+	return centreX, centreY, true, nil
+}
+
+func sameVillage(a *uint, b *uint) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+type gormTasks struct {
+	db *gorm.DB
+}
+
+func (r gormTasks) Get(ctx context.Context, id uint) (models.Task, error) {
+	var task models.Task
+	if err := r.db.WithContext(ctx).First(&task, id).Error; err != nil {
+		return models.Task{}, err
+	}
+	return task, nil
+}
+
+func (r gormTasks) List(ctx context.Context, query TaskQuery) ([]models.Task, error) {
+	statement := r.db.WithContext(ctx)
+	if query.BuildingID != nil {
+		statement = statement.Where("building_id = ?", *query.BuildingID)
+	}
+	if query.VillageID != nil {
+		statement = statement.Where("building_id IN (?)", r.db.WithContext(ctx).
+			Model(&models.Building{}).
+			Select("id").
+			Where("village_id = ?", *query.VillageID))
+	}
+	if query.IsCompleted != nil {
+		statement = statement.Where("is_completed = ?", *query.IsCompleted)
+	}
+	if query.Kind != "" {
+		statement = statement.Where("kind = ?", query.Kind)
+	}
+
+	var tasks []models.Task
+	if err := statement.Order("id").Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+func (r gormTasks) Create(ctx context.Context, task *models.Task) error {
+	db := r.db.WithContext(ctx)
+	if err := buildingExists(db, task.BuildingId); err != nil {
+		return err
+	}
+	task.ID = 0
+	task.Building = models.Building{}
+	if task.Kind == "" {
+		task.Kind = models.TaskKindChore
+	}
+	return db.Create(task).Error
+}
+
+func (r gormTasks) Update(ctx context.Context, task models.Task) error {
+	db := r.db.WithContext(ctx)
+	if err := db.Select("id").First(&models.Task{}, task.ID).Error; err != nil {
+		return err
+	}
+	if err := buildingExists(db, task.BuildingId); err != nil {
+		return err
+	}
+	return db.
+		Model(&models.Task{}).
+		Where("id = ?", task.ID).
+		Updates(map[string]any{
+			"name":              task.Name,
+			"description":       task.Description,
+			"building_id":       task.BuildingId,
+			"kind":              task.Kind,
+			"estimated_minutes": task.EstimatedMinutes,
+			"is_completed":      task.IsCompleted,
+			"completed_at":      task.CompletedAt,
+		}).Error
+}
+
+func (r gormTasks) Delete(ctx context.Context, id uint) error {
+	result := r.db.WithContext(ctx).Delete(&models.Task{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func buildingExists(db *gorm.DB, id uint) error {
+	var count int64
+	if err := db.Model(&models.Building{}).Where("id = ?", id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrMissingBuilding
+	}
+	return nil
+}
+
+type gormCategories struct {
+	db *gorm.DB
+}
+
+func (r gormCategories) Resolve(ctx context.Context, categories []models.Category) ([]models.Category, error) {
+	db := r.db.WithContext(ctx)
+	resolved := make([]models.Category, 0, len(categories))
+	seen := map[string]bool{}
+	for _, category := range categories {
+		name := strings.Join(strings.Fields(category.Name), " ")
+		slug := models.CategorySlug(name)
+		if slug == "" || seen[slug] {
+			continue
+		}
+		seen[slug] = true
+
+		var existing models.Category
+		err := db.Where("slug = ?", slug).Take(&existing).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// DoNothing covers a concurrent insert of the same slug; re-read either way
+			if err := db.
+				Clauses(clause.OnConflict{DoNothing: true}).
+				Create(&models.Category{Name: name, Slug: slug}).Error; err != nil {
+				return nil, err
+			}
+			err = db.Where("slug = ?", slug).Take(&existing).Error
+		}
+		if err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, existing)
+	}
+	return resolved, nil
+}
+
+type gormVillages struct {
+	db *gorm.DB
+}
+
+func (r gormVillages) Get(ctx context.Context, id uint) (models.Village, error) {
+	var village models.Village
+	if err := r.db.WithContext(ctx).First(&village, id).Error; err != nil {
+		return models.Village{}, err
+	}
+	return village, nil
+}
+
+func (r gormVillages) Create(ctx context.Context, village *models.Village) error {
+	village.ID = 0
+	return r.db.WithContext(ctx).Create(village).Error
+}
+
+func (r gormVillages) Record(ctx context.Context, id uint, action models.VillageAction) (models.Village, error) {
+	var village models.Village
+	err := r.db.WithContext(ctx).Transaction(func(transaction *gorm.DB) error {
+		if err := transaction.First(&village, id).Error; err != nil {
+			return err
+		}
+		state, err := villageState(transaction, village)
+		if err != nil {
+			return err
+		}
+
+		if action.Kind == sim.ActionAdvance {
+			// buildings still under construction don't produce
+			var rates []models.BuildingRate
+			if err := transaction.
+				Joins("JOIN buildings ON buildings.id = building_rates.building_id").
+				Where("buildings.village_id = ? AND buildings.status = ?", id, models.BuildingStatusActive).
+				Order("building_rates.building_id, building_rates.resource").
+				Find(&rates).Error; err != nil {
+				return err
+			}
+			if action.Payload, err = withProducers(action.Payload, producers(rates)); err != nil {
+				return err
+			}
+		}
+		simAction := sim.Action{Kind: action.Kind, Payload: action.Payload}
+		if err := sim.Validate(simAction); err != nil {
+			return err
+		}
+
+		var count int64
+		if err := transaction.Model(&models.VillageAction{}).
+			Where("village_id = ?", id).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		action.ID = 0
+		action.VillageID = id
+		action.Seq = uint(count) + 1
+		if err := transaction.Create(&action).Error; err != nil {
+			return err
+		}
+
+		rolledBefore := len(state.Events)
+		stockBefore := maps.Clone(state.Stock)
+		changes, err := sim.Apply(village.Seed, &state, simAction)
+		if err != nil {
+			return err
+		}
+		entries, err := ledgerEntries(id, stockBefore, changes)
+		if err != nil {
+			return err
+		}
+		if len(entries) > 0 {
+			if err := transaction.CreateInBatches(&entries, 500).Error; err != nil {
+				return err
+			}
+		}
+
+		if rolled := state.Events[rolledBefore:]; len(rolled) > 0 {
+			events := make([]models.VillageEvent, 0, len(rolled))
+			for _, event := range rolled {
+				events = append(events, models.VillageEvent{VillageID: id, Tick: event.Tick, Kind: event.Kind})
+			}
+			if err := transaction.Create(&events).Error; err != nil {
+				return err
+			}
+		}
+		village.Tick = state.Tick
+		village.StateHash = sim.Hash(state)
+		return transaction.
+			Model(&models.Village{}).
+			Where("id = ?", id).
+			Updates(map[string]any{
+				"tick":       village.Tick,
+				"state_hash": village.StateHash,
+			}).Error
+	})
+	if err != nil {
+		return models.Village{}, err
+	}
+	return village, nil
+}
+
+func (r gormVillages) State(ctx context.Context, id uint) (sim.State, error) {
+	db := r.db.WithContext(ctx)
+	var village models.Village
+	if err := db.First(&village, id).Error; err != nil {
+		return sim.State{}, err
+	}
+	return villageState(db, village)
+}
+
+// villageState reads the village's events and sums its ledger into stock.
+func villageState(db *gorm.DB, village models.Village) (sim.State, error) {
+	var events []models.VillageEvent
+	if err := db.
+		Where("village_id = ?", village.ID).
+		Order("tick, id").
+		Find(&events).Error; err != nil {
+		return sim.State{}, err
+	}
+
+	var totals []struct {
+		Resource string
+		Quantity int64
+	}
+	if err := db.
+		Model(&models.InventoryEntry{}).
+		Select("resource, SUM(delta) AS quantity").
+		Where("village_id = ?", village.ID).
+		Group("resource").
+		Scan(&totals).Error; err != nil {
+		return sim.State{}, err
+	}
+
+	state := sim.State{Tick: village.Tick, Stock: map[string]int64{}, Events: make([]sim.Event, 0, len(events))}
+	for _, total := range totals {
+		state.Stock[total.Resource] = total.Quantity
+	}
+	for _, event := range events {
+		state.Events = append(state.Events, sim.Event{Tick: event.Tick, Kind: event.Kind})
+	}
+	return state, nil
+}
@@ -0,0 +1,740 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Stckrz/villageApi/internal/db/models"
+	"github.com/Stckrz/villageApi/internal/sim"
+)
+
+// memoryData is everything a memory store holds. Rows are kept bare, without
+// their associations, and assembled on read, the way the tables are joined.
+type memoryData struct {
+	lastBuildingID uint
+	lastTaskID     uint
+	lastRateID     uint
+	lastCategoryID uint
+	lastVillageID  uint
+
+	buildings  map[uint]models.Building
+	rates      map[uint][]models.BuildingRate // by building id
+	links      map[uint][]uint                // building id to category ids
+	categories map[uint]models.Category
+	tasks      map[uint]models.Task
+	villages   map[uint]memoryVillage
+}
+
+// memoryVillage is a village row with its simulation state. The ledger and the
+// action log aren't kept; the state is what they add up to.
+type memoryVillage struct {
+	village models.Village
+	state   sim.State
+}
+
+func newMemoryData() *memoryData {
+	return &memoryData{
+		buildings:  map[uint]models.Building{},
+		rates:      map[uint][]models.BuildingRate{},
+		links:      map[uint][]uint{},
+		categories: map[uint]models.Category{},
+		tasks:      map[uint]models.Task{},
+		villages:   map[uint]memoryVillage{},
+	}
+}
+
+// clone copies data deeply enough that writes to one never show in the other.
+// Rows, village states included, are values and only ever replaced whole, so
+// copying the maps will do.
+func (d *memoryData) clone() *memoryData {
+	copied := *d
+	copied.buildings = cloneMap(d.buildings)
+	copied.categories = cloneMap(d.categories)
+	copied.tasks = cloneMap(d.tasks)
+	copied.villages = cloneMap(d.villages)
+	copied.rates = make(map[uint][]models.BuildingRate, len(d.rates))
+	for id, rates := range d.rates {
+		copied.rates[id] = slices.Clone(rates)
+	}
+	copied.links = make(map[uint][]uint, len(d.links))
+	for id, links := range d.links {
+		copied.links[id] = slices.Clone(links)
+	}
+	return &copied
+}
+
+func cloneMap[V any](source map[uint]V) map[uint]V {
+	copied := make(map[uint]V, len(source))
+	for key, value := range source {
+		copied[key] = value
+	}
+	return copied
+}
+
+type memoryStore struct {
+	mu   *sync.Mutex
+	data *memoryData
+	// inTransaction is set on the store handed to a transaction, which already
+	// holds mu for its whole run.
+	inTransaction bool
+}
+
+// NewMemoryStore returns an empty store that lives in memory. Transactions are
+// serialized: one holds the store until it is done.
+func NewMemoryStore() Store {
+	return &memoryStore{mu: &sync.Mutex{}, data: newMemoryData()}
+}
+
+func (s *memoryStore) Buildings() BuildingRepository {
+	return memoryBuildings{store: s}
+}
+
+func (s *memoryStore) Tasks() TaskRepository {
+	return memoryTasks{store: s}
+}
+
+func (s *memoryStore) Categories() CategoryRepository {
+	return memoryCategories{store: s}
+}
+
+func (s *memoryStore) Villages() VillageRepository {
+	return memoryVillages{store: s}
+}
+
+// enter checks ctx and takes the lock unless a transaction already holds it.
+// The returned func releases it.
+func (s *memoryStore) enter(ctx context.Context) (func(), error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if s.inTransaction {
+		return func() {}, nil
+	}
+	s.mu.Lock()
+	return s.mu.Unlock, nil
+}
+
+func (s *memoryStore) Transaction(ctx context.Context, fn func(store Store) error) error {
+	leave, err := s.enter(ctx)
+	if err != nil {
+		return err
+	}
+	defer leave()
+
+	saved := s.data.clone()
+	committed := false
+	// a panic rolls back too, like it does in a gorm transaction
+	defer func() {
+		if !committed {
+			*s.data = *saved
+		}
+	}()
+	if err := fn(&memoryStore{mu: s.mu, data: s.data, inTransaction: true}); err != nil {
+		return err
+	}
+	committed = true
+	return nil
+}
+
+// building assembles the stored building id with its associations.
+func (d *memoryData) building(id uint) (models.Building, bool) {
+	building, ok := d.buildings[id]
+	if !ok {
+		return models.Building{}, false
+	}
+	building = bareBuilding(building)
+
+	building.Categories = []models.Category{}
+	for _, categoryID := range d.links[id] {
+		building.Categories = append(building.Categories, d.categories[categoryID])
+	}
+	slices.SortFunc(building.Categories, func(a, b models.Category) int { return cmp.Compare(a.ID, b.ID) })
+	building.Rates = append([]models.BuildingRate{}, d.rates[id]...)
+	building.Tasks = []models.Task{}
+	for _, taskID := range sortedIDs(d.tasks) {
+		if task := d.tasks[taskID]; task.BuildingId == id {
+			building.Tasks = append(building.Tasks, bareTask(task))
+		}
+	}
+	return building, true
+}
+
+// saveCategories stores categories that aren't stored yet, giving new ones an
+// id, and links them to the building.
+func (d *memoryData) saveCategories(buildingID uint, categories []models.Category, now time.Time) {
+	links := []uint{}
+	for _, category := range categories {
+		if category.ID == 0 {
+			d.lastCategoryID++
+			category.ID = d.lastCategoryID
+		}
+		if _, ok := d.categories[category.ID]; !ok {
+			stampCreated(&category.CreatedAt, &category.UpdatedAt, now)
+			d.categories[category.ID] = category
+			d.lastCategoryID = max(d.lastCategoryID, category.ID)
+		}
+		if !slices.Contains(links, category.ID) {
+			links = append(links, category.ID)
+		}
+	}
+	d.links[buildingID] = links
+}
+
+func (d *memoryData) saveRates(buildingID uint, rates []models.BuildingRate, now time.Time) {
+	saved := make([]models.BuildingRate, 0, len(rates))
+	for _, rate := range rates {
+		d.lastRateID++
+		saved = append(saved, models.BuildingRate{
+			ID:         d.lastRateID,
+			BuildingID: buildingID,
+			Resource:   rate.Resource,
+			PerTick:    rate.PerTick,
+			CreatedAt:  now,
+			UpdatedAt:  now,
+		})
+	}
+	d.rates[buildingID] = saved
+}
+
+func (d *memoryData) insertTask(task *models.Task, now time.Time) {
+	d.lastTaskID++
+	task.ID = d.lastTaskID
+	task.Building = models.Building{}
+	if task.Kind == "" {
+		task.Kind = models.TaskKindChore
+	}
+	stampCreated(&task.CreatedAt, &task.UpdatedAt, now)
+	d.tasks[task.ID] = bareTask(*task)
+}
+
+type memoryBuildings struct {
+	store *memoryStore
+}
+
+func (r memoryBuildings) Get(ctx context.Context, id uint) (models.Building, error) {
+	leave, err := r.store.enter(ctx)
+	if err != nil {
+		return models.Building{}, err
+	}
+	defer leave()
+
+	building, ok := r.store.data.building(id)
+	if !ok {
+		return models.Building{}, ErrNotFound
+	}
+	return building, nil
+}
+
+func (r memoryBuildings) List(ctx context.Context, query BuildingQuery) ([]models.Building, error) {
+	leave, err := r.store.enter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer leave()
+
+	buildings := []models.Building{}
+	for _, building := range r.store.data.matching(query) {
+		buildings = append(buildings, trimBuilding(building, query))
+	}
+	return buildings, nil
+}
+
+func (r memoryBuildings) Summaries(ctx context.Context, query BuildingQuery, now time.Time) ([]BuildingSummary, error) {
+	leave, err := r.store.enter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer leave()
+
+	summaries := []BuildingSummary{}
+	for _, building := range r.store.data.matching(query) {
+		summary := BuildingSummary{BuildingID: building.ID}
+		for _, task := range building.Tasks {
+			switch {
+			case task.IsCompleted:
+				summary.Completed++
+			default:
+				summary.Open++
+				deadline := task.CreatedAt.Add(time.Duration(task.EstimatedMinutes) * time.Minute)
+				if task.EstimatedMinutes > 0 && deadline.Unix() < now.Unix() {
+					summary.Overdue++
+				}
+			}
+			if summary.LastActivity == nil || task.UpdatedAt.Unix() > summary.LastActivity.Unix() {
+				lastActivity := time.Unix(task.UpdatedAt.Unix(), 0)
+				summary.LastActivity = &lastActivity
+			}
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, nil
+}
+
+// matching assembles the buildings query matches, ordered by id.
+func (d *memoryData) matching(query BuildingQuery) []models.Building {
+	buildings := []models.Building{}
+	for _, id := range sortedIDs(d.buildings) {
+		building, _ := d.building(id)
+		if query.ID != 0 && building.ID != query.ID {
+			continue
+		}
+		if query.Name != "" && building.Name != query.Name {
+			continue
+		}
+		if !carriesAll(building.Categories, query.CategorySlugs) {
+			continue
+		}
+		if query.BoundingBox != nil && !intersects(building, *query.BoundingBox) {
+			continue
+		}
+		buildings = append(buildings, building)
+	}
+	return buildings
+}
+
+func (r memoryBuildings) Create(ctx context.Context, building *models.Building) error {
+	leave, err := r.store.enter(ctx)
+	if err != nil {
+		return err
+	}
+	defer leave()
+
+	data, now := r.store.data, time.Now()
+	data.lastBuildingID++
+	building.ID = data.lastBuildingID
+	if building.Status == "" {
+		building.Status = models.BuildingStatusActive
+	}
+	stampCreated(&building.CreatedAt, &building.UpdatedAt, now)
+	data.buildings[building.ID] = bareBuilding(*building)
+
+	data.saveCategories(building.ID, building.Categories, now)
+	data.saveRates(building.ID, building.Rates, now)
+	for index := range building.Tasks {
+		building.Tasks[index].BuildingId = building.ID
+		data.insertTask(&building.Tasks[index], now)
+	}
+
+	stored, _ := data.building(building.ID)
+	building.Categories = stored.Categories
+	building.Rates = stored.Rates
+	return nil
+}
+
+func (r memoryBuildings) Update(ctx context.Context, building models.Building) error {
+	leave, err := r.store.enter(ctx)
+	if err != nil {
+		return err
+	}
+	defer leave()
+
+	data, now := r.store.data, time.Now()
+	existing, ok := data.buildings[building.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	building.CreatedAt = existing.CreatedAt
+	building.UpdatedAt = now
+	data.buildings[building.ID] = bareBuilding(building)
+	data.saveCategories(building.ID, building.Categories, now)
+	data.saveRates(building.ID, building.Rates, now)
+	return nil
+}
+
+func (r memoryBuildings) Delete(ctx context.Context, id uint) error {
+	leave, err := r.store.enter(ctx)
+	if err != nil {
+		return err
+	}
+	defer leave()
+
+	data := r.store.data
+	if _, ok := data.buildings[id]; !ok {
+		return ErrNotFound
+	}
+	delete(data.buildings, id)
+	delete(data.rates, id)
+	delete(data.links, id)
+	for taskID, task := range data.tasks {
+		if task.BuildingId == id {
+			delete(data.tasks, taskID)
+		}
+	}
+	return nil
+}
+
+type memoryTasks struct {
+	store *memoryStore
+}
+
+func (r memoryTasks) Get(ctx context.Context, id uint) (models.Task, error) {
+	leave, err := r.store.enter(ctx)
+	if err != nil {
+		return models.Task{}, err
+	}
+	defer leave()
+
+	task, ok := r.store.data.tasks[id]
+	if !ok {
+		return models.Task{}, ErrNotFound
+	}
+	return bareTask(task), nil
+}
+
+func (r memoryTasks) List(ctx context.Context, query TaskQuery) ([]models.Task, error) {
+	leave, err := r.store.enter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer leave()
+
+	data := r.store.data
+	tasks := []models.Task{}
+	for _, id := range sortedIDs(data.tasks) {
+		task := data.tasks[id]
+		if query.BuildingID != nil && task.BuildingId != *query.BuildingID {
+			continue
+		}
+		if query.VillageID != nil {
+			villageID := data.buildings[task.BuildingId].VillageID
+			if villageID == nil || *villageID != *query.VillageID {
+				continue
+			}
+		}
+		if query.IsCompleted != nil && task.IsCompleted != *query.IsCompleted {
+			continue
+		}
+		if query.Kind != "" && task.Kind != query.Kind {
+			continue
+		}
+		tasks = append(tasks, bareTask(task))
+	}
+	return tasks, nil
+}
+
+func (r memoryTasks) Create(ctx context.Context, task *models.Task) error {
+	leave, err := r.store.enter(ctx)
+	if err != nil {
+		return err
+	}
+	defer leave()
+
+	if _, ok := r.store.data.buildings[task.BuildingId]; !ok {
+		return ErrMissingBuilding
+	}
+	r.store.data.insertTask(task, time.Now())
+	return nil
+}
+
+func (r memoryTasks) Update(ctx context.Context, task models.Task) error {
+	leave, err := r.store.enter(ctx)
+	if err != nil {
+		return err
+	}
+	defer leave()
+
+	data := r.store.data
+	existing, ok := data.tasks[task.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	if _, ok := data.buildings[task.BuildingId]; !ok {
+		return ErrMissingBuilding
+	}
+	task.CreatedAt = existing.CreatedAt
+	task.UpdatedAt = time.Now()
+	data.tasks[task.ID] = bareTask(task)
+	return nil
+}
+
+func (r memoryTasks) Delete(ctx context.Context, id uint) error {
+	leave, err := r.store.enter(ctx)
+	if err != nil {
+		return err
+	}
+	defer leave()
+
+	if _, ok := r.store.data.tasks[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.store.data.tasks, id)
+	return nil
+}
+
+type memoryCategories struct {
+	store *memoryStore
+}
+
+func (r memoryCategories) Resolve(ctx context.Context, categories []models.Category) ([]models.Category, error) {
+	leave, err := r.store.enter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer leave()
+
+	data, now := r.store.data, time.Now()
+	resolved := make([]models.Category, 0, len(categories))
+	seen := map[string]bool{}
+	for _, category := range categories {
+		name := strings.Join(strings.Fields(category.Name), " ")
+		slug := models.CategorySlug(name)
+		if slug == "" || seen[slug] {
+			continue
+		}
+		seen[slug] = true
+
+		if existing, ok := data.categoryBySlug(slug); ok {
+			resolved = append(resolved, existing)
+			continue
+		}
+		data.lastCategoryID++
+		stored := models.Category{ID: data.lastCategoryID, Name: name, Slug: slug}
+		stampCreated(&stored.CreatedAt, &stored.UpdatedAt, now)
+		data.categories[stored.ID] = stored
+		resolved = append(resolved, stored)
+	}
+	return resolved, nil
+}
+
+func (d *memoryData) categoryBySlug(slug string) (models.Category, bool) {
+	for _, category := range d.categories {
+		if category.Slug == slug {
+			return category, true
+		}
+	}
+	return models.Category{}, false
+}
+
+type memoryVillages struct {
+	store *memoryStore
+}
+
+func (r memoryVillages) Get(ctx context.Context, id uint) (models.Village, error) {
+	leave, err := r.store.enter(ctx)
+	if err != nil {
+		return models.Village{}, err
+	}
+	defer leave()
+
+	stored, ok := r.store.data.villages[id]
+	if !ok {
+		return models.Village{}, ErrNotFound
+	}
+	return stored.village, nil
+}
+
+func (r memoryVillages) Create(ctx context.Context, village *models.Village) error {
+	leave, err := r.store.enter(ctx)
+	if err != nil {
+		return err
+	}
+	defer leave()
+
+	data := r.store.data
+	data.lastVillageID++
+	village.ID = data.lastVillageID
+	stampCreated(&village.CreatedAt, &village.UpdatedAt, time.Now())
+	stored := *village
+	stored.Actions, stored.Events = nil, nil
+	data.villages[village.ID] = memoryVillage{village: stored, state: sim.State{Tick: village.Tick}}
+	return nil
+}
+
+func (r memoryVillages) Record(ctx context.Context, id uint, action models.VillageAction) (models.Village, error) {
+	leave, err := r.store.enter(ctx)
+	if err != nil {
+		return models.Village{}, err
+	}
+	defer leave()
+
+	data := r.store.data
+	stored, ok := data.villages[id]
+	if !ok {
+		return models.Village{}, ErrNotFound
+	}
+	if action.Kind == sim.ActionAdvance {
+		// buildings still under construction don't produce
+		var rates []models.BuildingRate
+		for _, buildingID := range sortedIDs(data.buildings) {
+			building := data.buildings[buildingID]
+			if building.VillageID == nil || *building.VillageID != id || building.Status != models.BuildingStatusActive {
+				continue
+			}
+			buildingRates := slices.Clone(data.rates[buildingID])
+			slices.SortFunc(buildingRates, func(a, b models.BuildingRate) int { return cmp.Compare(a.Resource, b.Resource) })
+			rates = append(rates, buildingRates...)
+		}
+		if action.Payload, err = withProducers(action.Payload, producers(rates)); err != nil {
+			return models.Village{}, err
+		}
+	}
+	simAction := sim.Action{Kind: action.Kind, Payload: action.Payload}
+	if err := sim.Validate(simAction); err != nil {
+		return models.Village{}, err
+	}
+
+	state := sim.State{
+		Tick:   stored.state.Tick,
+		Stock:  maps.Clone(stored.state.Stock),
+		Events: slices.Clone(stored.state.Events),
+	}
+	changes, err := sim.Apply(stored.village.Seed, &state, simAction)
+	if err != nil {
+		return models.Village{}, err
+	}
+	if _, err := ledgerEntries(id, stored.state.Stock, changes); err != nil {
+		return models.Village{}, err
+	}
+	stored.state = state
+	stored.village.Tick = state.Tick
+	stored.village.StateHash = sim.Hash(state)
+	stored.village.UpdatedAt = time.Now()
+	data.villages[id] = stored
+	return stored.village, nil
+}
+
+func (r memoryVillages) State(ctx context.Context, id uint) (sim.State, error) {
+	leave, err := r.store.enter(ctx)
+	if err != nil {
+		return sim.State{}, err
+	}
+	defer leave()
+
+	stored, ok := r.store.data.villages[id]
+	if !ok {
+		return sim.State{}, ErrNotFound
+	}
+	return sim.State{
+		Tick:   stored.state.Tick,
+		Stock:  maps.Clone(stored.state.Stock),
+		Events: slices.Clone(stored.state.Events),
+	}, nil
+}
+
+// bareBuilding drops the associations and copies the pointer fields, so the
+// stored row shares nothing with the caller's value.
+func bareBuilding(building models.Building) models.Building {
+	building.Categories = nil
+	building.Rates = nil
+	building.Tasks = nil
+	if building.VillageID != nil {
+		villageID := *building.VillageID
+		building.VillageID = &villageID
+	}
+	return building
+}
+
+// trimBuilding drops the relations and columns query doesn't read, leaving
+// them zero the way gorm does.
+func trimBuilding(building models.Building, query BuildingQuery) models.Building {
+	if query.Preload != nil {
+		if !slices.Contains(query.Preload, RelationCategories) {
+			building.Categories = nil
+		}
+		if !slices.Contains(query.Preload, RelationRates) {
+			building.Rates = nil
+		}
+		if !slices.Contains(query.Preload, RelationTasks) {
+			building.Tasks = nil
+		}
+	}
+	if query.Columns == nil {
+		return building
+	}
+
+	trimmed := models.Building{
+		ID:         building.ID,
+		Categories: building.Categories,
+		Rates:      building.Rates,
+		Tasks:      building.Tasks,
+	}
+	for _, column := range query.Columns {
+		switch column {
+		case "village_id":
+			trimmed.VillageID = building.VillageID
+		case "name":
+			trimmed.Name = building.Name
+		case "description":
+			trimmed.Description = building.Description
+		case "thumbnail_path":
+			trimmed.ThumbnailPath = building.ThumbnailPath
+		case "image_path":
+			trimmed.ImagePath = building.ImagePath
+		case "type":
+			trimmed.Type = building.Type
+		case "level":
+			trimmed.Level = building.Level
+		case "target_level":
+			trimmed.TargetLevel = building.TargetLevel
+		case "status":
+			trimmed.Status = building.Status
+		case "x":
+			trimmed.X = building.X
+		case "y":
+			trimmed.Y = building.Y
+		case "width":
+			trimmed.Width = building.Width
+		case "height":
+			trimmed.Height = building.Height
+		case "rotation":
+			trimmed.Rotation = building.Rotation
+		case "created_at":
+			trimmed.CreatedAt = building.CreatedAt
+		case "updated_at":
+			trimmed.UpdatedAt = building.UpdatedAt
+		}
+	}
+	return trimmed
+}
+
+func bareTask(task models.Task) models.Task {
+	task.Building = models.Building{}
+	if task.CompletedAt != nil {
+		completedAt := *task.CompletedAt
+		task.CompletedAt = &completedAt
+	}
+	return task
+}
+
+// stampCreated fills in the timestamps of a new row the way gorm does, keeping
+// any the caller set.
+func stampCreated(createdAt *time.Time, updatedAt *time.Time, now time.Time) {
+	if createdAt.IsZero() {
+		*createdAt = now
+	}
+	if updatedAt.IsZero() {
+		*updatedAt = now
+	}
+}
+
+func carriesAll(categories []models.Category, slugs []string) bool {
+	for _, slug := range slugs {
+		if !slices.ContainsFunc(categories, func(category models.Category) bool { return category.Slug == slug }) {
+			return false
+		}
+	}
+	return true
+}
+
+func intersects(building models.Building, box BoundingBox) bool {
+	if !building.Placed() {
+		return false
+	}
+	width, height := building.Extent()
+	return building.X < box.X2 && building.X+width > box.X1 &&
+		building.Y < box.Y2 && building.Y+height > box.Y1
+}
+
+func sortedIDs[V any](rows map[uint]V) []uint {
+	ids := make([]uint, 0, len(rows))
+	for id := range rows {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
@@ -0,0 +1,142 @@
+// Package repository is the storage the services run on for buildings and
+// tasks, along with the categories and villages they hang off. The gorm store
+// backs the API; the memory store keeps everything in maps so services can run
+// without SQLite. Both follow the same contract, which conformance_test.go
+// checks against each of them. The other services still run on *gorm.DB and
+// open a gorm store on their transaction when they need its rules.
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Stckrz/villageApi/internal/db/models"
+	"github.com/Stckrz/villageApi/internal/sim"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrNotFound is returned for a missing building, task or village. It is gorm's own
+	// error, so callers already checking gorm.ErrRecordNotFound keep working.
+	ErrNotFound = gorm.ErrRecordNotFound
+	// ErrMissingBuilding is returned when a task is written for a building that
+	// does not exist.
+	ErrMissingBuilding = errors.New("building does not exist")
+)
+
+// Store hands out the repositories. Transaction runs fn against a store whose
+// writes are kept if fn returns nil and discarded otherwise; transactions nest,
+// an inner failure only discarding the inner writes.
+type Store interface {
+	Buildings() BuildingRepository
+	Tasks() TaskRepository
+	Categories() CategoryRepository
+	Villages() VillageRepository
+	Transaction(ctx context.Context, fn func(store Store) error) error
+}
+
+// BoundingBox is a viewport on the map grid, corners inclusive of X1, Y1 and
+// exclusive of X2, Y2.
+type BoundingBox struct {
+	X1 int
+	Y1 int
+	X2 int
+	Y2 int
+}
+
+// Relations a building read can preload, named as the model's fields.
+const (
+	RelationCategories = "Categories"
+	RelationRates      = "Rates"
+	RelationTasks      = "Tasks"
+)
+
+// BuildingQuery narrows BuildingRepository.List; zero fields don't filter.
+// A building has to carry every one of CategorySlugs, and BoundingBox keeps only
+// placed buildings whose rotated footprint intersects it.
+//
+// Preload and Columns trim what is read. A nil Preload loads every relation and
+// an empty one none; a nil Columns reads every column, otherwise only the listed
+// ones and the id. Fields that aren't read are left zero.
+type BuildingQuery struct {
+	ID            uint
+	Name          string
+	CategorySlugs []string
+	BoundingBox   *BoundingBox
+	Preload       []string
+	Columns       []string
+}
+
+// BuildingSummary counts a building's tasks. A task is overdue when it is open
+// and its estimate has run out since it was created. LastActivity is when one of
+// the building's tasks last changed, to the second, and nil without tasks.
+type BuildingSummary struct {
+	BuildingID   uint
+	Open         int64
+	Completed    int64
+	Overdue      int64
+	LastActivity *time.Time
+}
+
+// BuildingRepository stores buildings as aggregates: reads come with their
+// categories, rates and tasks, ordered by id, unless a List query trims them.
+type BuildingRepository interface {
+	Get(ctx context.Context, id uint) (models.Building, error)
+	List(ctx context.Context, query BuildingQuery) ([]models.Building, error)
+	// Summaries counts the tasks of every building query matches, as of now,
+	// without reading them. Preload and Columns don't apply.
+	Summaries(ctx context.Context, query BuildingQuery, now time.Time) ([]BuildingSummary, error)
+	// Create assigns the building a new id and stores it with its rates and
+	// tasks. Categories are linked by id and saved along as given.
+	Create(ctx context.Context, building *models.Building) error
+	// Update saves the building's own fields and replaces its rates and
+	// category links. Its tasks are left alone. Where the store keeps road
+	// segments, those ending at the building follow it as FollowRoads says.
+	Update(ctx context.Context, building models.Building) error
+	// Delete removes the building with everything hanging off it: tasks, rates,
+	// category links and, where the store keeps them, road segments.
+	Delete(ctx context.Context, id uint) error
+}
+
+// TaskQuery narrows TaskRepository.List; nil fields don't filter.
+type TaskQuery struct {
+	BuildingID  *uint
+	VillageID   *uint
+	IsCompleted *bool
+	Kind        string
+}
+
+// TaskRepository stores tasks on their own; Building is never filled in.
+// Lists are ordered by id.
+type TaskRepository interface {
+	Get(ctx context.Context, id uint) (models.Task, error)
+	List(ctx context.Context, query TaskQuery) ([]models.Task, error)
+	// Create assigns the task a new id. Its building has to exist.
+	Create(ctx context.Context, task *models.Task) error
+	// Update saves every field of the task. Its building has to exist.
+	Update(ctx context.Context, task models.Task) error
+	Delete(ctx context.Context, id uint) error
+}
+
+// CategoryRepository holds the categories buildings share.
+type CategoryRepository interface {
+	// Resolve returns the stored category for each of categories, matched by
+	// the slug of its name, creating the ones that don't exist yet. Blank and
+	// repeated names are dropped.
+	Resolve(ctx context.Context, categories []models.Category) ([]models.Category, error)
+}
+
+// VillageRepository holds villages as far as buildings need them: that they
+// exist and their simulation, whose stock construction draws on.
+type VillageRepository interface {
+	Get(ctx context.Context, id uint) (models.Village, error)
+	// Create stores the village as given, with its seed, tick and state hash.
+	Create(ctx context.Context, village *models.Village) error
+	// Record appends action to the village's log and applies it to the saved
+	// state, moving stock through the ledger, all or nothing. An advance has the
+	// rates of the village's active buildings stamped in as its producers.
+	Record(ctx context.Context, id uint, action models.VillageAction) (models.Village, error)
+	// State is the village's saved simulation state.
+	State(ctx context.Context, id uint) (sim.State, error)
+}
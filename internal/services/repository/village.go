@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/Stckrz/villageApi/internal/db/models"
+	"github.com/Stckrz/villageApi/internal/sim"
+)
+
+// maxAdvanceEntries bounds the ledger entries one recorded advance may write:
+// every tick writes one per producing building and resource. Replays aren't
+// bound by it, only by the tick cap of the simulation, so older logs still
+// replay.
+const maxAdvanceEntries = 100000
+
+// withProducers replaces the producers of an advance payload, whatever the
+// client sent, and refuses an advance that could write more than
+// maxAdvanceEntries ledger entries.
+func withProducers(payload string, producers []sim.Producer) (string, error) {
+	var advance sim.AdvancePayload
+	if err := json.Unmarshal([]byte(payload), &advance); err != nil {
+		return "", fmt.Errorf("%w: %v", sim.ErrInvalidAction, err)
+	}
+	advance.Producers = producers
+
+	var rates uint64
+	for _, producer := range advance.Producers {
+		rates += uint64(len(producer.Rates))
+	}
+	if rates > 0 && advance.Ticks > maxAdvanceEntries/rates {
+		return "", fmt.Errorf("%w: %d producing rates can advance at most %d ticks at once",
+			sim.ErrInvalidAction, rates, maxAdvanceEntries/rates)
+	}
+
+	encoded, err := json.Marshal(advance)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+// producers groups rates ordered by building and resource into one producer per
+// building.
+func producers(rates []models.BuildingRate) []sim.Producer {
+	var grouped []sim.Producer
+	for _, rate := range rates {
+		if len(grouped) == 0 || grouped[len(grouped)-1].BuildingID != rate.BuildingID {
+			grouped = append(grouped, sim.Producer{
+				BuildingID: rate.BuildingID,
+				Rates:      map[string]int64{},
+			})
+		}
+		grouped[len(grouped)-1].Rates[rate.Resource] = rate.PerTick
+	}
+	return grouped
+}
+
+// ledgerEntries turns changes into ledger entries, carrying running balances
+// forward from stockBefore, and refuses any that would leave a resource
+// negative.
+func ledgerEntries(villageID uint, stockBefore map[string]int64, changes []sim.Change) ([]models.InventoryEntry, error) {
+	balances := map[string]int64{}
+	for resource, amount := range stockBefore {
+		balances[resource] = amount
+	}
+
+	entries := make([]models.InventoryEntry, 0, len(changes))
+	for _, change := range changes {
+		balances[change.Resource] += change.Delta
+		if balances[change.Resource] < 0 {
+			return nil, fmt.Errorf("%w: %s", sim.ErrInsufficientStock, change.Resource)
+		}
+		entry := models.InventoryEntry{
+			VillageID: villageID,
+			Resource:  change.Resource,
+			Delta:     change.Delta,
+			Balance:   balances[change.Resource],
+			Reason:    change.Reason,
+			Tick:      change.Tick,
+		}
+		if change.BuildingID != 0 {
+			buildingID := change.BuildingID
+			entry.BuildingID = &buildingID
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
@@ -0,0 +1,309 @@
+package services
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/Stckrz/villageApi/internal/db/models"
+	"github.com/Stckrz/villageApi/internal/services/repository"
+	"gorm.io/gorm"
+)
+
+// MinutesPerTile is the travel time along one grid tile of road, used when a
+// segment is created without an explicit travel time.
+const MinutesPerTile = models.MinutesPerTile
+
+var (
+	ErrNoPath           = errors.New("no road connects the two buildings")
+	ErrInvalidRoad      = errors.New("invalid road segment")
+	ErrUnplacedEndpoint = errors.New("road ends at a building without a map position; give travelMinutes explicitly")
+	ErrEndpointNotFound = errors.New("road ends at a building that does not exist")
+)
+
+type RoadService interface {
+	ListRoads(ctx context.Context, villageID *uint) ([]models.RoadSegment, error)
+	CreateRoad(ctx context.Context, segment models.RoadSegment, travelMinutes *float64) (models.RoadSegment, error)
+	DeleteRoad(ctx context.Context, id uint) error
+	FindPath(ctx context.Context, fromBuildingID uint, toBuildingID uint) (Path, error)
+	EstimateTask(ctx context.Context, taskID uint, homeBuildingID uint) (TaskEstimate, error)
+}
+
+// PathNode is one stop along a route, either a building or a tile.
+type PathNode struct {
+	BuildingID *uint `json:"buildingId,omitempty"`
+	X          *int  `json:"x,omitempty"`
+	Y          *int  `json:"y,omitempty"`
+}
+
+type Path struct {
+	FromBuildingID uint       `json:"fromBuildingId"`
+	ToBuildingID   uint       `json:"toBuildingId"`
+	Nodes          []PathNode `json:"nodes"`
+	Segments       []uint     `json:"segments"`
+	TravelMinutes  float64    `json:"travelMinutes"`
+}
+
+// TaskEstimate is how long a servitor living in HomeBuildingID needs for a task:
+// the walk there, the work, and the walk back.
+type TaskEstimate struct {
+	TaskID         uint    `json:"taskId"`
+	HomeBuildingID uint    `json:"homeBuildingId"`
+	TaskMinutes    float64 `json:"taskMinutes"`
+	TravelMinutes  float64 `json:"travelMinutes"`
+	TotalMinutes   float64 `json:"totalMinutes"`
+	Route          Path    `json:"route"`
+}
+
+type roadService struct {
+	db *gorm.DB
+}
+
+func NewRoadService(db *gorm.DB) RoadService {
+	return &roadService{db: db}
+}
+
+func (s *roadService) ListRoads(ctx context.Context, villageID *uint) ([]models.RoadSegment, error) {
+	query := s.db.WithContext(ctx)
+	if villageID != nil {
+		query = query.Where("village_id = ?", *villageID)
+	}
+
+	var segments []models.RoadSegment
+	if err := query.Order("id").Find(&segments).Error; err != nil {
+		return nil, err
+	}
+	return segments, nil
+}
+
+// CreateRoad stores a segment between buildings of its village or tiles. Without
+// a travel time, it is worked out from the straight distance between the two
+// ends, measured from building centres; zero is a valid travel time.
+func (s *roadService) CreateRoad(ctx context.Context, segment models.RoadSegment, travelMinutes *float64) (models.RoadSegment, error) {
+	if travelMinutes != nil && *travelMinutes < 0 {
+		return models.RoadSegment{}, fmt.Errorf("%w: travel time must not be negative", ErrInvalidRoad)
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(transaction *gorm.DB) error {
+		if err := villageExists(ctx, repository.NewGormStore(transaction), segment.VillageID); err != nil {
+			return err
+		}
+		fromX, fromY, fromPlaced, err := roadEnd(transaction, segment.VillageID, segment.FromBuildingID, segment.FromX, segment.FromY)
+		if err != nil {
+			return err
+		}
+		toX, toY, toPlaced, err := roadEnd(transaction, segment.VillageID, segment.ToBuildingID, segment.ToX, segment.ToY)
+		if err != nil {
+			return err
+		}
+		if roadNodeKey(segment.FromBuildingID, segment.FromX, segment.FromY) == roadNodeKey(segment.ToBuildingID, segment.ToX, segment.ToY) {
+			return fmt.Errorf("%w: both ends are the same", ErrInvalidRoad)
+		}
+
+		if travelMinutes != nil {
+			segment.TravelMinutes = *travelMinutes
+			segment.TravelComputed = false
+		} else {
+			if !fromPlaced || !toPlaced {
+				return ErrUnplacedEndpoint
+			}
+			segment.TravelMinutes = math.Hypot(toX-fromX, toY-fromY) * MinutesPerTile
+			segment.TravelComputed = true
+		}
+		return transaction.Create(&segment).Error
+	})
+	if err != nil {
+		return models.RoadSegment{}, err
+	}
+	return segment, nil
+}
+
+func (s *roadService) DeleteRoad(ctx context.Context, id uint) error {
+	result := s.db.WithContext(ctx).Delete(&models.RoadSegment{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// FindPath runs Dijkstra over the road network of the starting building's
+// village, weighted by travel time.
+func (s *roadService) FindPath(ctx context.Context, fromBuildingID uint, toBuildingID uint) (Path, error) {
+	var from, to models.Building
+	if err := s.db.WithContext(ctx).First(&from, fromBuildingID).Error; err != nil {
+		return Path{}, err
+	}
+	if err := s.db.WithContext(ctx).First(&to, toBuildingID).Error; err != nil {
+		return Path{}, err
+	}
+	return shortestPath(s.db.WithContext(ctx), from, to)
+}
+
+func (s *roadService) EstimateTask(ctx context.Context, taskID uint, homeBuildingID uint) (TaskEstimate, error) {
+	var task models.Task
+	if err := s.db.WithContext(ctx).First(&task, taskID).Error; err != nil {
+		return TaskEstimate{}, err
+	}
+	route, err := s.FindPath(ctx, homeBuildingID, task.BuildingId)
+	if err != nil {
+		return TaskEstimate{}, err
+	}
+
+	estimate := TaskEstimate{
+		TaskID:         task.ID,
+		HomeBuildingID: homeBuildingID,
+		TaskMinutes:    float64(task.EstimatedMinutes),
+		TravelMinutes:  route.TravelMinutes,
+		Route:          route,
+	}
+	estimate.TotalMinutes = estimate.TaskMinutes + 2*estimate.TravelMinutes
+	return estimate, nil
+}
+
+func shortestPath(db *gorm.DB, from models.Building, to models.Building) (Path, error) {
+	path := Path{FromBuildingID: from.ID, ToBuildingID: to.ID, Nodes: []PathNode{}, Segments: []uint{}}
+	start := roadNodeKey(&from.ID, 0, 0)
+	goal := roadNodeKey(&to.ID, 0, 0)
+	if start == goal {
+		path.Nodes = append(path.Nodes, PathNode{BuildingID: &from.ID})
+		return path, nil
+	}
+
+	query := db.Model(&models.RoadSegment{})
+	if from.VillageID == nil {
+		query = query.Where("village_id IS NULL")
+	} else {
+		query = query.Where("village_id = ?", *from.VillageID)
+	}
+	var segments []models.RoadSegment
+	if err := query.Find(&segments).Error; err != nil {
+		return Path{}, err
+	}
+
+	type edge struct {
+		to      string
+		segment uint
+		minutes float64
+	}
+	graph := map[string][]edge{}
+	nodes := map[string]PathNode{}
+	for _, segment := range segments {
+		fromKey := roadNodeKey(segment.FromBuildingID, segment.FromX, segment.FromY)
+		toKey := roadNodeKey(segment.ToBuildingID, segment.ToX, segment.ToY)
+		nodes[fromKey] = roadNode(segment.FromBuildingID, segment.FromX, segment.FromY)
+		nodes[toKey] = roadNode(segment.ToBuildingID, segment.ToX, segment.ToY)
+		graph[fromKey] = append(graph[fromKey], edge{to: toKey, segment: segment.ID, minutes: segment.TravelMinutes})
+		graph[toKey] = append(graph[toKey], edge{to: fromKey, segment: segment.ID, minutes: segment.TravelMinutes})
+	}
+
+	distances := map[string]float64{start: 0}
+	previous := map[string]edge{}
+	queue := &roadQueue{{key: start}}
+	for queue.Len() > 0 {
+		current := heap.Pop(queue).(roadQueueItem)
+		if current.minutes > distances[current.key] {
+			continue
+		}
+		if current.key == goal {
+			break
+		}
+		for _, next := range graph[current.key] {
+			minutes := current.minutes + next.minutes
+			if known, ok := distances[next.to]; ok && known <= minutes {
+				continue
+			}
+			distances[next.to] = minutes
+			previous[next.to] = edge{to: current.key, segment: next.segment}
+			heap.Push(queue, roadQueueItem{key: next.to, minutes: minutes})
+		}
+	}
+
+	minutes, ok := distances[goal]
+	if !ok {
+		return Path{}, ErrNoPath
+	}
+
+	for key := goal; key != start; key = previous[key].to {
+		path.Nodes = append(path.Nodes, nodes[key])
+		path.Segments = append(path.Segments, previous[key].segment)
+	}
+	path.Nodes = append(path.Nodes, PathNode{BuildingID: &from.ID})
+	for i, j := 0, len(path.Nodes)-1; i < j; i, j = i+1, j-1 {
+		path.Nodes[i], path.Nodes[j] = path.Nodes[j], path.Nodes[i]
+	}
+	for i, j := 0, len(path.Segments)-1; i < j; i, j = i+1, j-1 {
+		path.Segments[i], path.Segments[j] = path.Segments[j], path.Segments[i]
+	}
+	path.TravelMinutes = minutes
+	return path, nil
+}
+
+// roadEnd resolves one end of a segment of villageID to map coordinates.
+// Buildings are measured from the centre of their footprint; placed is false for
+// buildings that aren't on the map. A building of another village is refused,
+// since paths only follow the roads of the starting building's village.
+func roadEnd(db *gorm.DB, villageID *uint, buildingID *uint, x int, y int) (float64, float64, bool, error) {
+	if buildingID == nil {
+		return float64(x), float64(y), true, nil
+	}
+	var building models.Building
+	if err := db.First(&building, *buildingID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, 0, false, ErrEndpointNotFound
+		}
+		return 0, 0, false, err
+	}
+	if !sameVillage(building.VillageID, villageID) {
+		return 0, 0, false, fmt.Errorf("%w: building %d is not in the road's village", ErrInvalidRoad, building.ID)
+	}
+	if !building.Placed() {
+		return 0, 0, false, nil
+	}
+	centreX, centreY := building.Centre()
+	return centreX, centreY, true, nil
+}
+
+func sameVillage(a *uint, b *uint) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+func roadNodeKey(buildingID *uint, x int, y int) string {
+	if buildingID != nil {
+		return fmt.Sprintf("b:%d", *buildingID)
+	}
+	return fmt.Sprintf("t:%d,%d", x, y)
+}
+
+func roadNode(buildingID *uint, x int, y int) PathNode {
+	if buildingID != nil {
+		return PathNode{BuildingID: buildingID}
+	}
+	return PathNode{X: &x, Y: &y}
+}
+
+type roadQueueItem struct {
+	key     string
+	minutes float64
+}
+
+// roadQueue is a min-heap on travel time for Dijkstra.
+type roadQueue []roadQueueItem
+
+func (q roadQueue) Len() int           { return len(q) }
+func (q roadQueue) Less(i, j int) bool { return q[i].minutes < q[j].minutes }
+func (q roadQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *roadQueue) Push(item any)     { *q = append(*q, item.(roadQueueItem)) }
+func (q *roadQueue) Pop() any {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
@@ -0,0 +1,194 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/Stckrz/villageApi/internal/catalog"
+	"github.com/Stckrz/villageApi/internal/db/models"
+	"github.com/Stckrz/villageApi/internal/services/repository"
+)
+
+func TestFindPath(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t)
+	villages := NewVillageService(database)
+	buildings := NewBuildingService(repository.NewGormStore(database), catalog.Default())
+	roads := NewRoadService(database)
+
+	village, err := villages.CreateVillage(ctx, models.Village{Name: "Village"}, nil)
+	if err != nil {
+		t.Fatalf("create village: %v", err)
+	}
+	neighbour, err := villages.CreateVillage(ctx, models.Village{Name: "Neighbour"}, nil)
+	if err != nil {
+		t.Fatalf("create neighbour: %v", err)
+	}
+	place := func(name string, villageID uint, x int) models.Building {
+		building, err := buildings.CreateBuilding(ctx, models.Building{Name: name, VillageID: &villageID, X: x, Width: 2, Height: 2})
+		if err != nil {
+			t.Fatalf("create %s: %v", name, err)
+		}
+		return building
+	}
+	home := place("Home", village.ID, 0)
+	well := place("Well", village.ID, 10)
+	farm := place("Farm", village.ID, 20)
+	hermit := place("Hermit", village.ID, 40)
+	abroad := place("Abroad", neighbour.ID, 60)
+
+	road := func(from uint, to uint, minutes *float64) models.RoadSegment {
+		segment, err := roads.CreateRoad(ctx, models.RoadSegment{VillageID: &village.ID, FromBuildingID: &from, ToBuildingID: &to}, minutes)
+		if err != nil {
+			t.Fatalf("road %d to %d: %v", from, to, err)
+		}
+		return segment
+	}
+	free, slow := 0.0, 50.0
+	walk := road(home.ID, well.ID, nil)
+	if walk.TravelMinutes != 10 {
+		t.Fatalf("computed travel time %v, want the distance 10", walk.TravelMinutes)
+	}
+	cart := road(well.ID, farm.ID, &free)
+	if cart.TravelMinutes != 0 {
+		t.Fatalf("explicit zero travel time stored as %v", cart.TravelMinutes)
+	}
+	road(home.ID, farm.ID, &slow)
+
+	path, err := roads.FindPath(ctx, home.ID, farm.ID)
+	if err != nil {
+		t.Fatalf("find path: %v", err)
+	}
+	if path.TravelMinutes != 10 || !slices.Equal(path.Segments, []uint{walk.ID, cart.ID}) {
+		t.Fatalf("path %+v, want through the well in 10 minutes", path)
+	}
+	if len(path.Nodes) != 3 || *path.Nodes[1].BuildingID != well.ID {
+		t.Fatalf("nodes %+v", path.Nodes)
+	}
+
+	if _, err := roads.FindPath(ctx, home.ID, hermit.ID); !errors.Is(err, ErrNoPath) {
+		t.Fatalf("path to an unconnected building: %v", err)
+	}
+	if _, err := roads.CreateRoad(ctx, models.RoadSegment{VillageID: &village.ID, FromBuildingID: &farm.ID, ToBuildingID: &abroad.ID}, nil); !errors.Is(err, ErrInvalidRoad) {
+		t.Fatalf("road to another village: %v", err)
+	}
+	negative := -1.0
+	if _, err := roads.CreateRoad(ctx, models.RoadSegment{VillageID: &village.ID, FromBuildingID: &farm.ID, ToBuildingID: &hermit.ID}, &negative); !errors.Is(err, ErrInvalidRoad) {
+		t.Fatalf("negative travel time: %v", err)
+	}
+}
+
+func TestFindPathAfterVillageDelete(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t)
+	villages := NewVillageService(database)
+	buildings := NewBuildingService(repository.NewGormStore(database), catalog.Default())
+	roads := NewRoadService(database)
+
+	village, err := villages.CreateVillage(ctx, models.Village{Name: "Village"}, nil)
+	if err != nil {
+		t.Fatalf("create village: %v", err)
+	}
+	home, err := buildings.CreateBuilding(ctx, models.Building{Name: "Home", VillageID: &village.ID, Width: 2, Height: 2})
+	if err != nil {
+		t.Fatalf("create home: %v", err)
+	}
+	well, err := buildings.CreateBuilding(ctx, models.Building{Name: "Well", VillageID: &village.ID, X: 10, Width: 2, Height: 2})
+	if err != nil {
+		t.Fatalf("create well: %v", err)
+	}
+	segment, err := roads.CreateRoad(ctx, models.RoadSegment{VillageID: &village.ID, FromBuildingID: &home.ID, ToBuildingID: &well.ID}, nil)
+	if err != nil {
+		t.Fatalf("create road: %v", err)
+	}
+
+	if err := villages.DeleteVillage(ctx, village.ID); err != nil {
+		t.Fatalf("delete village: %v", err)
+	}
+
+	var stale int64
+	if err := database.Model(&models.RoadSegment{}).Where("village_id = ?", village.ID).Count(&stale).Error; err != nil {
+		t.Fatalf("count roads: %v", err)
+	}
+	if stale != 0 {
+		t.Fatalf("%d roads left on the deleted village", stale)
+	}
+	path, err := roads.FindPath(ctx, home.ID, well.ID)
+	if err != nil {
+		t.Fatalf("find path between detached buildings: %v", err)
+	}
+	if !slices.Equal(path.Segments, []uint{segment.ID}) {
+		t.Fatalf("path %+v, want the detached road", path)
+	}
+}
+
+func TestRoadsFollowAMovedBuilding(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t)
+	villages := NewVillageService(database)
+	buildings := NewBuildingService(repository.NewGormStore(database), catalog.Default())
+	roads := NewRoadService(database)
+
+	village, err := villages.CreateVillage(ctx, models.Village{Name: "Village"}, nil)
+	if err != nil {
+		t.Fatalf("create village: %v", err)
+	}
+	neighbour, err := villages.CreateVillage(ctx, models.Village{Name: "Neighbour"}, nil)
+	if err != nil {
+		t.Fatalf("create neighbour: %v", err)
+	}
+	home, err := buildings.CreateBuilding(ctx, models.Building{Name: "Home", VillageID: &village.ID, Width: 2, Height: 2})
+	if err != nil {
+		t.Fatalf("create home: %v", err)
+	}
+	well, err := buildings.CreateBuilding(ctx, models.Building{Name: "Well", VillageID: &village.ID, X: 10, Width: 2, Height: 2})
+	if err != nil {
+		t.Fatalf("create well: %v", err)
+	}
+	farm, err := buildings.CreateBuilding(ctx, models.Building{Name: "Farm", VillageID: &village.ID, Y: 10, Width: 2, Height: 2})
+	if err != nil {
+		t.Fatalf("create farm: %v", err)
+	}
+	if _, err := roads.CreateRoad(ctx, models.RoadSegment{VillageID: &village.ID, FromBuildingID: &home.ID, ToBuildingID: &well.ID}, nil); err != nil {
+		t.Fatalf("computed road: %v", err)
+	}
+	fixed := 4.0
+	if _, err := roads.CreateRoad(ctx, models.RoadSegment{VillageID: &village.ID, FromBuildingID: &home.ID, ToBuildingID: &farm.ID}, &fixed); err != nil {
+		t.Fatalf("fixed road: %v", err)
+	}
+
+	// moving the home closer shortens the computed road but not the given one
+	home.X = 4
+	if err := buildings.UpdateBuilding(ctx, home, home.ID, UpdateOptions{}); err != nil {
+		t.Fatalf("move home: %v", err)
+	}
+	path, err := roads.FindPath(ctx, home.ID, well.ID)
+	if err != nil {
+		t.Fatalf("path to the well: %v", err)
+	}
+	if path.TravelMinutes != 6 {
+		t.Fatalf("travel time %v after the move, want 6", path.TravelMinutes)
+	}
+	if path, err = roads.FindPath(ctx, home.ID, farm.ID); err != nil || path.TravelMinutes != fixed {
+		t.Fatalf("path to the farm: %+v, %v", path, err)
+	}
+
+	// moving the well to another village drops its road rather than leaving it
+	// behind in the old one
+	well.VillageID = &neighbour.ID
+	if err := buildings.UpdateBuilding(ctx, well, well.ID, UpdateOptions{SetVillage: true}); err != nil {
+		t.Fatalf("move well: %v", err)
+	}
+	if _, err := roads.FindPath(ctx, home.ID, well.ID); !errors.Is(err, ErrNoPath) {
+		t.Fatalf("path to a building of another village: %v", err)
+	}
+	left, err := roads.ListRoads(ctx, nil)
+	if err != nil {
+		t.Fatalf("list roads: %v", err)
+	}
+	if len(left) != 1 || *left[0].ToBuildingID != farm.ID {
+		t.Fatalf("roads left %+v, want only the one to the farm", left)
+	}
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/Stckrz/villageApi/internal/db"
+	"gorm.io/gorm"
+)
+
+const (
+	SearchTypeBuilding = "building"
+	SearchTypeTask     = "task"
+
+	// DefaultSearchLimit and MaxSearchLimit bound the hits returned per type.
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+
+	searchMarkOpen  = "<mark>"
+	searchMarkClose = "</mark>"
+	// FTS5 brackets matches with these private use characters; they only turn
+	// into <mark> tags once the text around them has been HTML escaped.
+	searchMatchOpen  = "\ue000"
+	searchMatchClose = "\ue001"
+)
+
+var (
+	ErrSearchUnavailable = errors.New("search is not available: the server was built without SQLite FTS5")
+	ErrEmptySearch       = errors.New("search query must not be empty")
+	ErrUnknownSearchType = errors.New("unknown search type")
+)
+
+type SearchService interface {
+	Search(ctx context.Context, query string, options SearchOptions) (SearchResults, error)
+}
+
+// SearchOptions narrows a search. Empty Types searches everything; a zero Limit
+// means DefaultSearchLimit.
+type SearchOptions struct {
+	Types     []string
+	VillageID *uint
+	Limit     int
+}
+
+// SearchHit is one ranked match. Name and Snippet are HTML escaped, with the
+// matched terms wrapped in <mark></mark>. Higher scores rank better.
+type SearchHit struct {
+	ID         uint    `json:"id"`
+	BuildingID *uint   `json:"buildingId,omitempty"`
+	VillageID  *uint   `json:"villageId"`
+	Name       string  `json:"name"`
+	Snippet    string  `json:"snippet"`
+	Score      float64 `json:"score"`
+}
+
+type SearchResults struct {
+	Query     string      `json:"query"`
+	Buildings []SearchHit `json:"buildings"`
+	Tasks     []SearchHit `json:"tasks"`
+}
+
+type searchService struct {
+	db *gorm.DB
+}
+
+func NewSearchService(db *gorm.DB) SearchService {
+	return &searchService{db: db}
+}
+
+// Search matches every term of query as a prefix, across building names,
+// descriptions and categories and task names and descriptions. Name matches
+// weigh most, then categories, then descriptions.
+func (s *searchService) Search(ctx context.Context, query string, options SearchOptions) (SearchResults, error) {
+	match := searchMatch(query)
+	if match == "" {
+		return SearchResults{}, ErrEmptySearch
+	}
+	if !db.SearchAvailable(s.db.WithContext(ctx)) {
+		return SearchResults{}, ErrSearchUnavailable
+	}
+
+	wanted := map[string]bool{}
+	for _, searchType := range options.Types {
+		if searchType != SearchTypeBuilding && searchType != SearchTypeTask {
+			return SearchResults{}, fmt.Errorf("%w: %q", ErrUnknownSearchType, searchType)
+		}
+		wanted[searchType] = true
+	}
+	if len(wanted) == 0 {
+		wanted[SearchTypeBuilding] = true
+		wanted[SearchTypeTask] = true
+	}
+	limit := options.Limit
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	}
+	limit = min(limit, MaxSearchLimit)
+
+	results := SearchResults{Query: query, Buildings: []SearchHit{}, Tasks: []SearchHit{}}
+	if wanted[SearchTypeBuilding] {
+		statement := s.db.WithContext(ctx).
+			Table("building_search").
+			Select(
+				"buildings.id, buildings.village_id, highlight(building_search, 0, ?, ?) AS name, "+
+					"snippet(building_search, -1, ?, ?, '…', 12) AS snippet, "+
+					"-bm25(building_search, 10.0, 1.0, 4.0) AS score",
+				searchMatchOpen, searchMatchClose, searchMatchOpen, searchMatchClose,
+			).
+			Joins("JOIN buildings ON buildings.id = building_search.rowid").
+			Where("building_search MATCH ?", match)
+		if options.VillageID != nil {
+			statement = statement.Where("buildings.village_id = ?", *options.VillageID)
+		}
+		if err := statement.Order("score DESC").Limit(limit).Scan(&results.Buildings).Error; err != nil {
+			return SearchResults{}, err
+		}
+	}
+	if wanted[SearchTypeTask] {
+		statement := s.db.WithContext(ctx).
+			Table("task_search").
+			Select(
+				"tasks.id, tasks.building_id, buildings.village_id, highlight(task_search, 0, ?, ?) AS name, "+
+					"snippet(task_search, -1, ?, ?, '…', 12) AS snippet, "+
+					"-bm25(task_search, 10.0, 1.0) AS score",
+				searchMatchOpen, searchMatchClose, searchMatchOpen, searchMatchClose,
+			).
+			Joins("JOIN tasks ON tasks.id = task_search.rowid").
+			Joins("LEFT JOIN buildings ON buildings.id = tasks.building_id").
+			Where("task_search MATCH ?", match)
+		if options.VillageID != nil {
+			statement = statement.Where("buildings.village_id = ?", *options.VillageID)
+		}
+		if err := statement.Order("score DESC").Limit(limit).Scan(&results.Tasks).Error; err != nil {
+			return SearchResults{}, err
+		}
+	}
+	markSearchHits(results.Buildings)
+	markSearchHits(results.Tasks)
+	return results, nil
+}
+
+// markSearchHits HTML escapes the hits' text and only then turns the match
+// brackets into <mark> tags, so names and descriptions can't smuggle markup.
+func markSearchHits(hits []SearchHit) {
+	marks := strings.NewReplacer(searchMatchOpen, searchMarkOpen, searchMatchClose, searchMarkClose)
+	for index := range hits {
+		hits[index].Name = marks.Replace(html.EscapeString(hits[index].Name))
+		hits[index].Snippet = marks.Replace(html.EscapeString(hits[index].Snippet))
+	}
+}
+
+// searchMatch turns free text into an FTS5 query. Every term is quoted so FTS5
+// operators typed by users are taken literally, and matched as a prefix so
+// results show up while typing.
+func searchMatch(query string) string {
+	var terms []string
+	for _, term := range strings.Fields(query) {
+		term = strings.ReplaceAll(term, `"`, `""`)
+		terms = append(terms, `"`+term+`"*`)
+	}
+	return strings.Join(terms, " ")
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/Stckrz/villageApi/internal/catalog"
+	"github.com/Stckrz/villageApi/internal/db"
+	"github.com/Stckrz/villageApi/internal/db/models"
+	"github.com/Stckrz/villageApi/internal/services/repository"
+)
+
+func TestSearchEscapesMatchedText(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t)
+	if !db.SearchAvailable(database) {
+		t.Skip("sqlite was built without FTS5; run with -tags sqlite_fts5")
+	}
+	_, err := NewBuildingService(repository.NewGormStore(database), catalog.Default()).CreateBuilding(ctx, models.Building{
+		Name:        `<script>alert("barn")</script> Barn`,
+		Description: `<img src=x onerror=alert(1)> a barn`,
+	})
+	if err != nil {
+		t.Fatalf("create building: %v", err)
+	}
+
+	results, err := NewSearchService(database).Search(ctx, "barn", SearchOptions{})
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(results.Buildings) != 1 {
+		t.Fatalf("expected one building, got %+v", results.Buildings)
+	}
+	hit := results.Buildings[0]
+	if want := `&lt;script&gt;alert(&#34;<mark>barn</mark>&#34;)&lt;/script&gt; <mark>Barn</mark>`; hit.Name != want {
+		t.Fatalf("name %q, want %q", hit.Name, want)
+	}
+	unmarked := strings.NewReplacer(searchMarkOpen, "", searchMarkClose, "").Replace(hit.Snippet)
+	if !strings.Contains(hit.Snippet, searchMarkOpen) || strings.ContainsAny(unmarked, `<>"`) {
+		t.Fatalf("snippet %q carries markup besides its marks", hit.Snippet)
+	}
+}
+
+func TestMarkSearchHitsEscapesBeforeMarking(t *testing.T) {
+	hits := []SearchHit{{
+		Name:    "<b>" + searchMatchOpen + "Barn" + searchMatchClose + "</b>",
+		Snippet: "…" + searchMatchOpen + "&" + searchMatchClose,
+	}}
+	markSearchHits(hits)
+	if want := "&lt;b&gt;<mark>Barn</mark>&lt;/b&gt;"; hits[0].Name != want {
+		t.Fatalf("name %q, want %q", hits[0].Name, want)
+	}
+	if want := "…<mark>&amp;</mark>"; hits[0].Snippet != want {
+		t.Fatalf("snippet %q, want %q", hits[0].Snippet, want)
+	}
+}
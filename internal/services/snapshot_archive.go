@@ -0,0 +1,258 @@
+package services
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// A snapshot archive is a gzipped tar holding snapshot.json first and, under
+// assets/, every image the buildings reference that exists below the image
+// root, at the same relative path.
+const (
+	snapshotArchiveFile   = "snapshot.json"
+	snapshotArchiveImages = "assets/"
+	// maxArchiveImageSize caps a single image read back from an archive.
+	maxArchiveImageSize = 20 << 20
+	// maxArchiveSnapshotSize caps snapshot.json read back from an archive.
+	maxArchiveSnapshotSize = 50 << 20
+	// maxArchiveSize caps the whole archive once decompressed, entries that are
+	// skipped included.
+	maxArchiveSize = 500 << 20
+)
+
+// WriteSnapshotArchive writes the archive form of a snapshot. Images that can't
+// be found under imageRoot are left out; the paths stay in the snapshot.
+func WriteSnapshotArchive(w io.Writer, snapshot Snapshot, imageRoot string) error {
+	compressed := gzip.NewWriter(w)
+	archive := tar.NewWriter(compressed)
+
+	contents, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeArchiveFile(archive, snapshotArchiveFile, contents); err != nil {
+		return err
+	}
+
+	for _, image := range snapshotImages(snapshot) {
+		contents, err := os.ReadFile(filepath.Join(imageRoot, filepath.FromSlash(image)))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := writeArchiveFile(archive, snapshotArchiveImages+image, contents); err != nil {
+			return err
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return err
+	}
+	return compressed.Close()
+}
+
+// ReadSnapshotArchive reads an archive back. Images are returned by their
+// relative path and only if the snapshot references them, so snapshot.json has
+// to come first; images that aren't referenced are skipped without reading them.
+func ReadSnapshotArchive(r io.Reader) (Snapshot, map[string][]byte, error) {
+	compressed, err := gzip.NewReader(r)
+	if err != nil {
+		return Snapshot{}, nil, fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+	}
+	defer compressed.Close()
+
+	var snapshot Snapshot
+	var referenced map[string]bool
+	images := map[string][]byte{}
+	archive := tar.NewReader(&archiveLimitReader{r: compressed, remaining: maxArchiveSize})
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Snapshot{}, nil, fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if referenced == nil {
+			if header.Name != snapshotArchiveFile {
+				return Snapshot{}, nil, fmt.Errorf("%w: archive doesn't start with %s", ErrInvalidSnapshot, snapshotArchiveFile)
+			}
+			if header.Size > maxArchiveSnapshotSize {
+				return Snapshot{}, nil, fmt.Errorf("%w: %s is too large", ErrInvalidSnapshot, snapshotArchiveFile)
+			}
+			if err := json.NewDecoder(io.LimitReader(archive, maxArchiveSnapshotSize)).Decode(&snapshot); err != nil {
+				return Snapshot{}, nil, fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+			}
+			referenced = map[string]bool{}
+			for _, image := range snapshotImages(snapshot) {
+				referenced[image] = true
+			}
+			continue
+		}
+
+		if !strings.HasPrefix(header.Name, snapshotArchiveImages) {
+			continue
+		}
+		image, ok := cleanImagePath(strings.TrimPrefix(header.Name, snapshotArchiveImages))
+		if !ok || !referenced[image] || header.Size > maxArchiveImageSize {
+			continue
+		}
+		contents, err := io.ReadAll(io.LimitReader(archive, maxArchiveImageSize))
+		if err != nil {
+			return Snapshot{}, nil, fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+		}
+		images[image] = contents
+	}
+	if referenced == nil {
+		return Snapshot{}, nil, fmt.Errorf("%w: archive has no %s", ErrInvalidSnapshot, snapshotArchiveFile)
+	}
+	return snapshot, images, nil
+}
+
+// archiveLimitReader fails once more than remaining bytes have been read, where
+// io.LimitReader would end the stream and pass it off as complete.
+type archiveLimitReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (l *archiveLimitReader) Read(p []byte) (int, error) {
+	if l.remaining <= 0 {
+		return 0, errors.New("archive is too large once decompressed")
+	}
+	if int64(len(p)) > l.remaining {
+		p = p[:l.remaining]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	return n, err
+}
+
+// StagedImages are archive images written to a staging directory below the
+// image root, so an import can move them into place once it has committed and
+// throw them away if it hasn't.
+type StagedImages struct {
+	root   string
+	dir    string
+	images []string
+}
+
+// StageSnapshotImages writes archive images to a fresh staging directory below
+// imageRoot. Staging on the same filesystem keeps Commit down to renames.
+func StageSnapshotImages(imageRoot string, images map[string][]byte) (*StagedImages, error) {
+	staged := &StagedImages{root: imageRoot}
+	if len(images) == 0 {
+		return staged, nil
+	}
+	if err := os.MkdirAll(imageRoot, 0o755); err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp(imageRoot, ".import-")
+	if err != nil {
+		return nil, err
+	}
+	staged.dir = dir
+	for image, contents := range images {
+		target := filepath.Join(dir, filepath.FromSlash(image))
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			staged.Discard()
+			return nil, err
+		}
+		if err := os.WriteFile(target, contents, 0o644); err != nil {
+			staged.Discard()
+			return nil, err
+		}
+		staged.images = append(staged.images, image)
+	}
+	return staged, nil
+}
+
+// Commit moves the staged images into place and removes the staging directory.
+// Files that already exist are left alone, so an import never overwrites
+// artwork. It keeps going past a failed image and reports them all.
+func (s *StagedImages) Commit() error {
+	if s.dir == "" {
+		return nil
+	}
+	var problems []error
+	for _, image := range s.images {
+		target := filepath.Join(s.root, filepath.FromSlash(image))
+		if _, err := os.Stat(target); err == nil {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			problems = append(problems, err)
+			continue
+		}
+		if err := os.Rename(filepath.Join(s.dir, filepath.FromSlash(image)), target); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	problems = append(problems, s.Discard())
+	return errors.Join(problems...)
+}
+
+// Discard removes the staging directory and whatever is still in it.
+func (s *StagedImages) Discard() error {
+	if s.dir == "" {
+		return nil
+	}
+	return os.RemoveAll(s.dir)
+}
+
+// snapshotImages lists the image paths the snapshot references, relative to the
+// image root and without duplicates.
+func snapshotImages(snapshot Snapshot) []string {
+	var images []string
+	seen := map[string]bool{}
+	for _, building := range snapshot.Buildings {
+		for _, reference := range []string{building.ThumbnailPath, building.ImagePath} {
+			image, ok := cleanImagePath(reference)
+			if ok && !seen[image] {
+				seen[image] = true
+				images = append(images, image)
+			}
+		}
+	}
+	return images
+}
+
+// cleanImagePath turns an image reference into a path below the image root,
+// refusing anything that would climb out of it.
+func cleanImagePath(reference string) (string, bool) {
+	if reference == "" || strings.Contains(reference, "://") {
+		return "", false
+	}
+	cleaned := strings.TrimPrefix(path.Clean("/"+strings.ReplaceAll(reference, "\\", "/")), "/")
+	if cleaned == "" || cleaned == "." {
+		return "", false
+	}
+	return cleaned, true
+}
+
+func writeArchiveFile(archive *tar.Writer, name string, contents []byte) error {
+	if err := archive.WriteHeader(&tar.Header{
+		Name:     name,
+		Mode:     0o644,
+		Size:     int64(len(contents)),
+		Typeflag: tar.TypeReg,
+	}); err != nil {
+		return err
+	}
+	_, err := archive.Write(contents)
+	return err
+}
@@ -0,0 +1,492 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Stckrz/villageApi/internal/db/models"
+	"github.com/Stckrz/villageApi/internal/services/repository"
+	"github.com/Stckrz/villageApi/internal/sim"
+	"gorm.io/gorm"
+)
+
+// SnapshotVersion is bumped whenever the snapshot layout changes in a way older
+// readers can't follow. Imports of any other version are refused.
+const SnapshotVersion = 1
+
+var (
+	ErrSnapshotVersion = errors.New("unsupported snapshot version")
+	ErrInvalidSnapshot = errors.New("invalid snapshot")
+)
+
+type SnapshotService interface {
+	ExportVillage(ctx context.Context, id uint) (Snapshot, error)
+	ImportVillage(ctx context.Context, snapshot Snapshot) (models.Village, error)
+}
+
+// Snapshot is a village with everything hanging off it, detached from database
+// ids. Buildings are identified by Ref, which is only meaningful inside the
+// snapshot; roads and ledger entries point at buildings through it.
+//
+// Action payloads are kept verbatim. The building ids inside them are those of
+// the source database and only order producers during replay, which the import
+// preserves by creating buildings in Ref order.
+type Snapshot struct {
+	Version    int                      `json:"version"`
+	ExportedAt time.Time                `json:"exportedAt"`
+	Village    SnapshotVillage          `json:"village"`
+	Buildings  []SnapshotBuilding       `json:"buildings"`
+	Roads      []SnapshotRoad           `json:"roads"`
+	Inventory  []SnapshotInventoryEntry `json:"inventory"`
+}
+
+type SnapshotVillage struct {
+	Name      string           `json:"name"`
+	Seed      int64            `json:"seed"`
+	Tick      uint64           `json:"tick"`
+	StateHash string           `json:"stateHash"`
+	Actions   []SnapshotAction `json:"actions"`
+	Events    []sim.Event      `json:"events"`
+}
+
+type SnapshotAction struct {
+	Seq     uint   `json:"seq"`
+	Kind    string `json:"kind"`
+	Payload string `json:"payload"`
+}
+
+type SnapshotBuilding struct {
+	Ref           uint             `json:"ref"`
+	Name          string           `json:"name"`
+	Description   string           `json:"description"`
+	Categories    []string         `json:"categories"`
+	ThumbnailPath string           `json:"thumbnailPath"`
+	ImagePath     string           `json:"imagePath"`
+	Type          string           `json:"type,omitempty"`
+	Level         int              `json:"level"`
+	TargetLevel   int              `json:"targetLevel"`
+	Status        string           `json:"status"`
+	X             int              `json:"x"`
+	Y             int              `json:"y"`
+	Width         int              `json:"width"`
+	Height        int              `json:"height"`
+	Rotation      int              `json:"rotation"`
+	Rates         map[string]int64 `json:"rates,omitempty"`
+	Tasks         []SnapshotTask   `json:"tasks"`
+}
+
+type SnapshotTask struct {
+	Name             string     `json:"name"`
+	Description      string     `json:"description"`
+	Kind             string     `json:"kind"`
+	EstimatedMinutes uint       `json:"estimatedMinutes"`
+	IsCompleted      bool       `json:"isCompleted"`
+	CompletedAt      *time.Time `json:"completedAt,omitempty"`
+}
+
+type SnapshotRoad struct {
+	FromRef       *uint   `json:"fromRef,omitempty"`
+	FromX         int     `json:"fromX"`
+	FromY         int     `json:"fromY"`
+	ToRef         *uint   `json:"toRef,omitempty"`
+	ToX           int     `json:"toX"`
+	ToY           int     `json:"toY"`
+	TravelMinutes float64 `json:"travelMinutes"`
+	// TravelComputed was added after version 1 was published; older snapshots
+	// import their travel times as given.
+	TravelComputed bool `json:"travelComputed,omitempty"`
+}
+
+type SnapshotInventoryEntry struct {
+	Resource    string `json:"resource"`
+	Delta       int64  `json:"delta"`
+	Balance     int64  `json:"balance"`
+	Reason      string `json:"reason"`
+	BuildingRef *uint  `json:"buildingRef,omitempty"`
+	Tick        uint64 `json:"tick"`
+}
+
+type snapshotService struct {
+	db *gorm.DB
+}
+
+func NewSnapshotService(db *gorm.DB) SnapshotService {
+	return &snapshotService{db: db}
+}
+
+func (s *snapshotService) ExportVillage(ctx context.Context, id uint) (Snapshot, error) {
+	var village models.Village
+	if err := s.db.WithContext(ctx).
+		Preload("Actions", func(db *gorm.DB) *gorm.DB { return db.Order("seq") }).
+		Preload("Events", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		First(&village, id).Error; err != nil {
+		return Snapshot{}, err
+	}
+
+	snapshot := Snapshot{
+		Version:    SnapshotVersion,
+		ExportedAt: time.Now().UTC(),
+		Village: SnapshotVillage{
+			Name:      village.Name,
+			Seed:      village.Seed,
+			Tick:      village.Tick,
+			StateHash: village.StateHash,
+			Actions:   make([]SnapshotAction, 0, len(village.Actions)),
+			Events:    make([]sim.Event, 0, len(village.Events)),
+		},
+		Buildings: []SnapshotBuilding{},
+		Roads:     []SnapshotRoad{},
+		Inventory: []SnapshotInventoryEntry{},
+	}
+	for _, action := range village.Actions {
+		snapshot.Village.Actions = append(snapshot.Village.Actions, SnapshotAction{Seq: action.Seq, Kind: action.Kind, Payload: action.Payload})
+	}
+	for _, event := range village.Events {
+		snapshot.Village.Events = append(snapshot.Village.Events, sim.Event{Tick: event.Tick, Kind: event.Kind})
+	}
+
+	var buildings []models.Building
+	if err := s.db.WithContext(ctx).
+		Preload("Categories").
+		Preload("Rates").
+		Preload("Tasks", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Where("village_id = ?", id).
+		Order("id").
+		Find(&buildings).Error; err != nil {
+		return Snapshot{}, err
+	}
+	// refs are the source ids, so they are unique and keep the source order
+	refs := make(map[uint]bool, len(buildings))
+	for _, building := range buildings {
+		refs[building.ID] = true
+		exported := SnapshotBuilding{
+			Ref:           building.ID,
+			Name:          building.Name,
+			Description:   building.Description,
+			Categories:    make([]string, 0, len(building.Categories)),
+			ThumbnailPath: building.ThumbnailPath,
+			ImagePath:     building.ImagePath,
+			Type:          building.Type,
+			Level:         building.Level,
+			TargetLevel:   building.TargetLevel,
+			Status:        building.Status,
+			X:             building.X,
+			Y:             building.Y,
+			Width:         building.Width,
+			Height:        building.Height,
+			Rotation:      building.Rotation,
+			Tasks:         make([]SnapshotTask, 0, len(building.Tasks)),
+		}
+		for _, category := range building.Categories {
+			exported.Categories = append(exported.Categories, category.Name)
+		}
+		sort.Strings(exported.Categories)
+		if len(building.Rates) > 0 {
+			exported.Rates = make(map[string]int64, len(building.Rates))
+			for _, rate := range building.Rates {
+				exported.Rates[rate.Resource] = rate.PerTick
+			}
+		}
+		for _, task := range building.Tasks {
+			exported.Tasks = append(exported.Tasks, SnapshotTask{
+				Name:             task.Name,
+				Description:      task.Description,
+				Kind:             task.Kind,
+				EstimatedMinutes: task.EstimatedMinutes,
+				IsCompleted:      task.IsCompleted,
+				CompletedAt:      task.CompletedAt,
+			})
+		}
+		snapshot.Buildings = append(snapshot.Buildings, exported)
+	}
+	// references to buildings outside the village are dropped rather than dangling
+	ref := func(buildingID *uint) *uint {
+		if buildingID == nil || !refs[*buildingID] {
+			return nil
+		}
+		return buildingID
+	}
+
+	var roads []models.RoadSegment
+	if err := s.db.WithContext(ctx).Where("village_id = ?", id).Order("id").Find(&roads).Error; err != nil {
+		return Snapshot{}, err
+	}
+	for _, road := range roads {
+		if (road.FromBuildingID != nil && ref(road.FromBuildingID) == nil) ||
+			(road.ToBuildingID != nil && ref(road.ToBuildingID) == nil) {
+			continue
+		}
+		snapshot.Roads = append(snapshot.Roads, SnapshotRoad{
+			FromRef:        road.FromBuildingID,
+			FromX:          road.FromX,
+			FromY:          road.FromY,
+			ToRef:          road.ToBuildingID,
+			ToX:            road.ToX,
+			ToY:            road.ToY,
+			TravelMinutes:  road.TravelMinutes,
+			TravelComputed: road.TravelComputed,
+		})
+	}
+
+	var entries []models.InventoryEntry
+	if err := s.db.WithContext(ctx).Where("village_id = ?", id).Order("id").Find(&entries).Error; err != nil {
+		return Snapshot{}, err
+	}
+	for _, entry := range entries {
+		snapshot.Inventory = append(snapshot.Inventory, SnapshotInventoryEntry{
+			Resource:    entry.Resource,
+			Delta:       entry.Delta,
+			Balance:     entry.Balance,
+			Reason:      entry.Reason,
+			BuildingRef: ref(entry.BuildingID),
+			Tick:        entry.Tick,
+		})
+	}
+
+	return snapshot, nil
+}
+
+// ImportVillage recreates a snapshot as a new village. Every row gets a fresh
+// id; building refs are remapped as they are created. Nothing is written unless
+// the whole snapshot goes in.
+func (s *snapshotService) ImportVillage(ctx context.Context, snapshot Snapshot) (models.Village, error) {
+	if snapshot.Version != SnapshotVersion {
+		return models.Village{}, fmt.Errorf("%w: %d, expected %d", ErrSnapshotVersion, snapshot.Version, SnapshotVersion)
+	}
+	if err := snapshot.validate(); err != nil {
+		return models.Village{}, err
+	}
+
+	village := models.Village{
+		Name:      snapshot.Village.Name,
+		Seed:      snapshot.Village.Seed,
+		Tick:      snapshot.Village.Tick,
+		StateHash: snapshot.Village.StateHash,
+	}
+	err := s.db.WithContext(ctx).Transaction(func(transaction *gorm.DB) error {
+		if err := transaction.Create(&village).Error; err != nil {
+			return err
+		}
+
+		actions := make([]models.VillageAction, 0, len(snapshot.Village.Actions))
+		for _, action := range snapshot.Village.Actions {
+			actions = append(actions, models.VillageAction{VillageID: village.ID, Seq: action.Seq, Kind: action.Kind, Payload: action.Payload})
+		}
+		if len(actions) > 0 {
+			if err := transaction.Create(&actions).Error; err != nil {
+				return err
+			}
+		}
+		events := make([]models.VillageEvent, 0, len(snapshot.Village.Events))
+		for _, event := range snapshot.Village.Events {
+			events = append(events, models.VillageEvent{VillageID: village.ID, Tick: event.Tick, Kind: event.Kind})
+		}
+		if len(events) > 0 {
+			if err := transaction.Create(&events).Error; err != nil {
+				return err
+			}
+		}
+
+		buildings := append([]SnapshotBuilding(nil), snapshot.Buildings...)
+		sort.Slice(buildings, func(i, j int) bool { return buildings[i].Ref < buildings[j].Ref })
+		ids := make(map[uint]uint, len(buildings))
+		for _, imported := range buildings {
+			building := models.Building{
+				VillageID:     &village.ID,
+				Name:          imported.Name,
+				Description:   imported.Description,
+				ThumbnailPath: imported.ThumbnailPath,
+				ImagePath:     imported.ImagePath,
+				Type:          imported.Type,
+				Level:         imported.Level,
+				TargetLevel:   imported.TargetLevel,
+				Status:        imported.Status,
+				X:             imported.X,
+				Y:             imported.Y,
+				Width:         imported.Width,
+				Height:        imported.Height,
+				Rotation:      imported.Rotation,
+			}
+			if building.Status == "" {
+				building.Status = models.BuildingStatusActive
+			}
+			categories := make([]models.Category, 0, len(imported.Categories))
+			for _, name := range imported.Categories {
+				categories = append(categories, models.Category{Name: name})
+			}
+			resolved, err := repository.NewGormStore(transaction).Categories().Resolve(ctx, categories)
+			if err != nil {
+				return err
+			}
+			building.Categories = resolved
+			for _, resource := range models.Resources {
+				if perTick, ok := imported.Rates[resource]; ok && perTick != 0 {
+					building.Rates = append(building.Rates, models.BuildingRate{Resource: resource, PerTick: perTick})
+				}
+			}
+			for _, task := range imported.Tasks {
+				kind := task.Kind
+				if kind == "" {
+					kind = models.TaskKindChore
+				}
+				building.Tasks = append(building.Tasks, models.Task{
+					Name:             task.Name,
+					Description:      task.Description,
+					Kind:             kind,
+					EstimatedMinutes: task.EstimatedMinutes,
+					IsCompleted:      task.IsCompleted,
+					CompletedAt:      task.CompletedAt,
+				})
+			}
+			if err := transaction.Create(&building).Error; err != nil {
+				return err
+			}
+			ids[imported.Ref] = building.ID
+		}
+		remap := func(ref *uint) *uint {
+			if ref == nil {
+				return nil
+			}
+			id := ids[*ref]
+			return &id
+		}
+
+		roads := make([]models.RoadSegment, 0, len(snapshot.Roads))
+		for _, road := range snapshot.Roads {
+			roads = append(roads, models.RoadSegment{
+				VillageID:      &village.ID,
+				FromBuildingID: remap(road.FromRef),
+				FromX:          road.FromX,
+				FromY:          road.FromY,
+				ToBuildingID:   remap(road.ToRef),
+				ToX:            road.ToX,
+				ToY:            road.ToY,
+				TravelMinutes:  road.TravelMinutes,
+				TravelComputed: road.TravelComputed,
+			})
+		}
+		if len(roads) > 0 {
+			if err := transaction.Create(&roads).Error; err != nil {
+				return err
+			}
+		}
+
+		entries := make([]models.InventoryEntry, 0, len(snapshot.Inventory))
+		for _, entry := range snapshot.Inventory {
+			entries = append(entries, models.InventoryEntry{
+				VillageID:  village.ID,
+				Resource:   entry.Resource,
+				Delta:      entry.Delta,
+				Balance:    entry.Balance,
+				Reason:     entry.Reason,
+				BuildingID: remap(entry.BuildingRef),
+				Tick:       entry.Tick,
+			})
+		}
+		if len(entries) > 0 {
+			return transaction.CreateInBatches(&entries, 500).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return models.Village{}, err
+	}
+	return village, nil
+}
+
+// validate checks everything the database wouldn't: references, resources,
+// the action log, the ledger's running balances and that the saved state is
+// what the action log replays to.
+func (s Snapshot) validate() error {
+	invalid := func(format string, args ...any) error {
+		return fmt.Errorf("%w: %s", ErrInvalidSnapshot, fmt.Sprintf(format, args...))
+	}
+
+	if s.Village.Name == "" {
+		return invalid("village has no name")
+	}
+	for index, action := range s.Village.Actions {
+		if action.Seq != uint(index)+1 {
+			return invalid("action %d has seq %d", index+1, action.Seq)
+		}
+		if err := sim.Validate(sim.Action{Kind: action.Kind, Payload: action.Payload}); err != nil {
+			return invalid("action %d: %v", action.Seq, err)
+		}
+	}
+
+	refs := make(map[uint]bool, len(s.Buildings))
+	for _, building := range s.Buildings {
+		if refs[building.Ref] {
+			return invalid("building ref %d is used twice", building.Ref)
+		}
+		refs[building.Ref] = true
+		if building.Name == "" {
+			return invalid("building %d has no name", building.Ref)
+		}
+		for resource := range building.Rates {
+			if !models.IsResource(resource) {
+				return invalid("building %d has a rate for unknown resource %q", building.Ref, resource)
+			}
+		}
+		switch building.Status {
+		case "", models.BuildingStatusActive, models.BuildingStatusUnderConstruction:
+		default:
+			return invalid("building %d has unknown status %q", building.Ref, building.Status)
+		}
+		if err := validatePlacement(models.Building{Width: building.Width, Height: building.Height, Rotation: building.Rotation}); err != nil {
+			return invalid("building %d: %v", building.Ref, err)
+		}
+		for _, task := range building.Tasks {
+			if task.Name == "" {
+				return invalid("building %d has a task without a name", building.Ref)
+			}
+		}
+	}
+	known := func(ref *uint) bool { return ref == nil || refs[*ref] }
+
+	for index, road := range s.Roads {
+		if !known(road.FromRef) || !known(road.ToRef) {
+			return invalid("road %d points at a building that is not in the snapshot", index+1)
+		}
+		if road.TravelMinutes < 0 {
+			return invalid("road %d has a negative travel time", index+1)
+		}
+	}
+
+	balances := map[string]int64{}
+	for index, entry := range s.Inventory {
+		if !models.IsResource(entry.Resource) {
+			return invalid("ledger entry %d uses unknown resource %q", index+1, entry.Resource)
+		}
+		if !known(entry.BuildingRef) {
+			return invalid("ledger entry %d points at a building that is not in the snapshot", index+1)
+		}
+		balances[entry.Resource] += entry.Delta
+		if entry.Balance != balances[entry.Resource] || entry.Balance < 0 {
+			return invalid("ledger entry %d has balance %d, expected %d", index+1, entry.Balance, balances[entry.Resource])
+		}
+	}
+
+	// the saved state has to be what the action log replays to, the same check
+	// a replay of the imported village would make
+	actions := make([]sim.Action, 0, len(s.Village.Actions))
+	for _, action := range s.Village.Actions {
+		actions = append(actions, sim.Action{Kind: action.Kind, Payload: action.Payload})
+	}
+	replayed, err := sim.Replay(s.Village.Seed, actions)
+	if err != nil {
+		return invalid("replay the action log: %v", err)
+	}
+	replayedHash := sim.Hash(replayed)
+	if s.Village.StateHash != replayedHash {
+		return invalid("state hash doesn't match a replay of the action log")
+	}
+	saved := sim.State{Tick: s.Village.Tick, Stock: balances, Events: s.Village.Events}
+	if sim.Hash(saved) != replayedHash {
+		return invalid("tick, events or ledger don't match a replay of the action log")
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Stckrz/villageApi/internal/db/models"
+	"github.com/Stckrz/villageApi/internal/sim"
+)
+
+func TestImportChecksStateHashAgainstReplay(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t)
+	villages := NewVillageService(database)
+	snapshots := NewSnapshotService(database)
+	seed := int64(5)
+	village, err := villages.CreateVillage(ctx, models.Village{Name: "Village"}, &seed)
+	if err != nil {
+		t.Fatalf("create village: %v", err)
+	}
+	for _, action := range []models.VillageAction{
+		{Kind: sim.ActionAdjust, Payload: `{"resource":"wood","delta":20}`},
+		{Kind: sim.ActionAdvance, Payload: `{"ticks":400}`},
+	} {
+		if _, err := villages.RecordAction(ctx, village.ID, action); err != nil {
+			t.Fatalf("record %s: %v", action.Kind, err)
+		}
+	}
+	snapshot, err := snapshots.ExportVillage(ctx, village.ID)
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+
+	imported, err := snapshots.ImportVillage(ctx, snapshot)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if imported.StateHash != snapshot.Village.StateHash {
+		t.Fatalf("imported hash %s, exported %s", imported.StateHash, snapshot.Village.StateHash)
+	}
+
+	tampered := snapshot
+	tampered.Village.StateHash = sim.Hash(sim.State{Tick: 1})
+	if _, err := snapshots.ImportVillage(ctx, tampered); !errors.Is(err, ErrInvalidSnapshot) {
+		t.Fatalf("import with a forged hash: %v", err)
+	}
+
+	// a ledger that adds up but isn't what the actions produced
+	tampered = snapshot
+	var wood int64
+	for _, entry := range snapshot.Inventory {
+		if entry.Resource == models.ResourceWood {
+			wood = entry.Balance
+		}
+	}
+	tampered.Inventory = append(append([]SnapshotInventoryEntry(nil), snapshot.Inventory...),
+		SnapshotInventoryEntry{Resource: models.ResourceWood, Delta: 100, Balance: wood + 100, Reason: "gift"})
+	if _, err := snapshots.ImportVillage(ctx, tampered); !errors.Is(err, ErrInvalidSnapshot) {
+		t.Fatalf("import with a forged ledger: %v", err)
+	}
+}
+
+func TestStagedImagesOnlyLandOnCommit(t *testing.T) {
+	root := t.TempDir()
+	existing := filepath.Join(root, "buildings", "kept.png")
+	if err := os.MkdirAll(filepath.Dir(existing), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(existing, []byte("original"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	images := map[string][]byte{
+		"buildings/kept.png": []byte("imported"),
+		"buildings/new.png":  []byte("new"),
+	}
+
+	discarded, err := StageSnapshotImages(root, images)
+	if err != nil {
+		t.Fatalf("stage: %v", err)
+	}
+	if err := discarded.Discard(); err != nil {
+		t.Fatalf("discard: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "buildings", "new.png")); !os.IsNotExist(err) {
+		t.Fatalf("a discarded image is in place: %v", err)
+	}
+
+	staged, err := StageSnapshotImages(root, images)
+	if err != nil {
+		t.Fatalf("stage: %v", err)
+	}
+	if err := staged.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	if contents, _ := os.ReadFile(existing); string(contents) != "original" {
+		t.Fatalf("existing image overwritten with %q", contents)
+	}
+	if contents, _ := os.ReadFile(filepath.Join(root, "buildings", "new.png")); string(contents) != "new" {
+		t.Fatalf("new image has %q", contents)
+	}
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("staging directories left behind: %v", entries)
+	}
+}
+
+func TestReadSnapshotArchiveKeepsReferencedImages(t *testing.T) {
+	root := t.TempDir()
+	for name, contents := range map[string]string{"kept.png": "kept", "thumb.png": "thumb"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	snapshot := Snapshot{
+		Version:   SnapshotVersion,
+		Buildings: []SnapshotBuilding{{Name: "Hall", ImagePath: "kept.png", ThumbnailPath: "thumb.png"}},
+	}
+
+	var archive bytes.Buffer
+	if err := WriteSnapshotArchive(&archive, snapshot, root); err != nil {
+		t.Fatalf("write archive: %v", err)
+	}
+	read, images, err := ReadSnapshotArchive(&archive)
+	if err != nil {
+		t.Fatalf("read archive: %v", err)
+	}
+	if len(read.Buildings) != 1 || string(images["kept.png"]) != "kept" || string(images["thumb.png"]) != "thumb" {
+		t.Fatalf("read back %+v with images %v", read.Buildings, images)
+	}
+
+	// an unreferenced image is skipped, and snapshot.json has to come first
+	entries := func(names ...string) *bytes.Buffer {
+		var buffer bytes.Buffer
+		compressed := gzip.NewWriter(&buffer)
+		writer := tar.NewWriter(compressed)
+		for _, name := range names {
+			contents := []byte("image")
+			if name == snapshotArchiveFile {
+				contents = []byte(`{"buildings":[{"imagePath":"kept.png"}]}`)
+			}
+			if err := writeArchiveFile(writer, name, contents); err != nil {
+				t.Fatal(err)
+			}
+		}
+		writer.Close()
+		compressed.Close()
+		return &buffer
+	}
+	_, images, err = ReadSnapshotArchive(entries(snapshotArchiveFile, "assets/kept.png", "assets/other.png"))
+	if err != nil {
+		t.Fatalf("read archive: %v", err)
+	}
+	if len(images) != 1 || images["kept.png"] == nil {
+		t.Fatalf("images %v, want only the referenced one", images)
+	}
+	if _, _, err := ReadSnapshotArchive(entries("assets/kept.png", snapshotArchiveFile)); !errors.Is(err, ErrInvalidSnapshot) {
+		t.Fatalf("archive with images before the snapshot: %v", err)
+	}
+}
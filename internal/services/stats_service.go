@@ -0,0 +1,242 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Stckrz/villageApi/internal/db/models"
+	"gorm.io/gorm"
+)
+
+const (
+	StatsBucketDay  = "day"
+	StatsBucketWeek = "week"
+
+	// MaxStatsBuckets bounds how many buckets one stats request may span.
+	MaxStatsBuckets = 1000
+)
+
+var (
+	ErrUnknownStatsBucket = errors.New("bucket must be day or week")
+	ErrInvalidStatsRange  = errors.New("invalid stats range")
+)
+
+type StatsService interface {
+	GetStats(ctx context.Context, options StatsOptions) (Stats, error)
+}
+
+// StatsOptions picks the days the stats cover, From to To inclusive, and how
+// they are bucketed. Days are UTC; weeks start on Monday.
+type StatsOptions struct {
+	From      time.Time
+	To        time.Time
+	Bucket    string
+	VillageID *uint
+}
+
+// StatsBucket counts the tasks created and completed in the bucket starting on
+// Start.
+type StatsBucket struct {
+	Start     string `json:"start"`
+	Created   int64  `json:"created"`
+	Completed int64  `json:"completed"`
+}
+
+// CompletionTime is the mean time from creating to completing the tasks of a
+// building or category that were completed in the range.
+type CompletionTime struct {
+	ID          uint    `json:"id"`
+	Name        string  `json:"name"`
+	Completed   int64   `json:"completed"`
+	MeanMinutes float64 `json:"meanMinutes"`
+}
+
+// CompletionStreaks counts runs of consecutive buckets with at least one task
+// completed. Current is the run ending with the range's last bucket, 0 if
+// nothing was completed in it.
+type CompletionStreaks struct {
+	Current      int    `json:"current"`
+	Longest      int    `json:"longest"`
+	LongestStart string `json:"longestStart,omitempty"`
+}
+
+type Stats struct {
+	From       string            `json:"from"`
+	To         string            `json:"to"`
+	Bucket     string            `json:"bucket"`
+	Activity   []StatsBucket     `json:"activity"`
+	Buildings  []CompletionTime  `json:"buildings"`
+	Categories []CompletionTime  `json:"categories"`
+	Streaks    CompletionStreaks `json:"streaks"`
+}
+
+type statsService struct {
+	db *gorm.DB
+}
+
+func NewStatsService(db *gorm.DB) StatsService {
+	return &statsService{db: db}
+}
+
+// unixSQL reads a stored timestamp as unix seconds, whatever offset it was
+// written with.
+func unixSQL(column string) string {
+	return "CAST(strftime('%s', " + column + ") AS INTEGER)"
+}
+
+// bucketSQL is the UTC date starting the bucket a stored timestamp falls in.
+func bucketSQL(bucket string, column string) string {
+	if bucket == StatsBucketWeek {
+		// to the coming Sunday, or stay on one, then back to its Monday
+		return "date(" + column + ", 'weekday 0', '-6 days')"
+	}
+	return "date(" + column + ")"
+}
+
+// GetStats reports the tasks created and completed per bucket, the mean time to
+// completion per building and category, and the completion streaks, for tasks
+// still stored. Tasks count by when they were created and completed, not by
+// when their building was.
+func (s *statsService) GetStats(ctx context.Context, options StatsOptions) (Stats, error) {
+	switch options.Bucket {
+	case "":
+		options.Bucket = StatsBucketDay
+	case StatsBucketDay, StatsBucketWeek:
+	default:
+		return Stats{}, ErrUnknownStatsBucket
+	}
+	from := bucketStart(options.Bucket, options.From)
+	to := options.To.UTC().Truncate(24 * time.Hour)
+	if to.Before(from) {
+		return Stats{}, fmt.Errorf("%w: from is after to", ErrInvalidStatsRange)
+	}
+	var starts []string
+	for start := from; !start.After(to); start = nextBucket(options.Bucket, start) {
+		if len(starts) == MaxStatsBuckets {
+			return Stats{}, fmt.Errorf("%w: more than %d buckets", ErrInvalidStatsRange, MaxStatsBuckets)
+		}
+		starts = append(starts, start.Format(time.DateOnly))
+	}
+	// the range ends with the last day of to
+	fromUnix, toUnix := from.Unix(), to.AddDate(0, 0, 1).Unix()
+
+	tasks := func() *gorm.DB {
+		statement := s.db.WithContext(ctx).Table("tasks")
+		if options.VillageID != nil {
+			statement = statement.Where("tasks.building_id IN (?)", s.db.WithContext(ctx).
+				Model(&models.Building{}).
+				Select("id").
+				Where("village_id = ?", *options.VillageID))
+		}
+		return statement
+	}
+
+	counts := func(column string) (map[string]int64, error) {
+		var rows []struct {
+			Start string
+			Count int64
+		}
+		if err := tasks().
+			Select(bucketSQL(options.Bucket, "tasks."+column)+" AS start, COUNT(*) AS count").
+			Where(unixSQL("tasks."+column)+" >= ? AND "+unixSQL("tasks."+column)+" < ?", fromUnix, toUnix).
+			Group("start").
+			Scan(&rows).Error; err != nil {
+			return nil, err
+		}
+		byStart := make(map[string]int64, len(rows))
+		for _, row := range rows {
+			byStart[row.Start] = row.Count
+		}
+		return byStart, nil
+	}
+	created, err := counts("created_at")
+	if err != nil {
+		return Stats{}, err
+	}
+	completed, err := counts("completed_at")
+	if err != nil {
+		return Stats{}, err
+	}
+
+	// grouped by key, a table joined in by join
+	completionTimes := func(key string, join func(*gorm.DB) *gorm.DB) ([]CompletionTime, error) {
+		times := []CompletionTime{}
+		err := join(tasks()).
+			Select(key+".id AS id, "+key+".name AS name, COUNT(*) AS completed, "+
+				"AVG(("+unixSQL("tasks.completed_at")+" - "+unixSQL("tasks.created_at")+") / 60.0) AS mean_minutes").
+			Where("tasks.is_completed AND tasks.completed_at IS NOT NULL").
+			Where(unixSQL("tasks.completed_at")+" >= ? AND "+unixSQL("tasks.completed_at")+" < ?", fromUnix, toUnix).
+			Group(key + ".id").
+			Order(key + ".id").
+			Scan(&times).Error
+		return times, err
+	}
+	buildings, err := completionTimes("buildings", func(statement *gorm.DB) *gorm.DB {
+		return statement.Joins("JOIN buildings ON buildings.id = tasks.building_id")
+	})
+	if err != nil {
+		return Stats{}, err
+	}
+	categories, err := completionTimes("categories", func(statement *gorm.DB) *gorm.DB {
+		return statement.
+			Joins("JOIN building_category_links ON building_category_links.building_id = tasks.building_id").
+			Joins("JOIN categories ON categories.id = building_category_links.category_id")
+	})
+	if err != nil {
+		return Stats{}, err
+	}
+
+	stats := Stats{
+		From:       from.Format(time.DateOnly),
+		To:         to.Format(time.DateOnly),
+		Bucket:     options.Bucket,
+		Activity:   make([]StatsBucket, 0, len(starts)),
+		Buildings:  buildings,
+		Categories: categories,
+	}
+	for _, start := range starts {
+		stats.Activity = append(stats.Activity, StatsBucket{
+			Start:     start,
+			Created:   created[start],
+			Completed: completed[start],
+		})
+	}
+	stats.Streaks = completionStreaks(stats.Activity)
+	return stats, nil
+}
+
+func bucketStart(bucket string, day time.Time) time.Time {
+	day = day.UTC().Truncate(24 * time.Hour)
+	if bucket == StatsBucketWeek {
+		// Sunday is 0; Monday starts the week
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	}
+	return day
+}
+
+func nextBucket(bucket string, start time.Time) time.Time {
+	if bucket == StatsBucketWeek {
+		return start.AddDate(0, 0, 7)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+func completionStreaks(activity []StatsBucket) CompletionStreaks {
+	var streaks CompletionStreaks
+	run := 0
+	for index, bucket := range activity {
+		if bucket.Completed == 0 {
+			run = 0
+			continue
+		}
+		run++
+		if run > streaks.Longest {
+			streaks.Longest = run
+			streaks.LongestStart = activity[index-run+1].Start
+		}
+	}
+	streaks.Current = run
+	return streaks
+}
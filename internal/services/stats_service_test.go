@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Stckrz/villageApi/internal/catalog"
+	"github.com/Stckrz/villageApi/internal/db/models"
+	"github.com/Stckrz/villageApi/internal/services/repository"
+)
+
+func TestGetStatsBucketsAcrossAWeek(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t)
+	buildings := NewBuildingService(repository.NewGormStore(database), catalog.Default())
+	stats := NewStatsService(database)
+
+	farm, err := buildings.CreateBuilding(ctx, models.Building{Name: "Farm", Categories: []models.Category{{Name: "food"}}})
+	if err != nil {
+		t.Fatalf("create farm: %v", err)
+	}
+	mill, err := buildings.CreateBuilding(ctx, models.Building{Name: "Mill", Categories: []models.Category{{Name: "food"}, {Name: "craft"}}})
+	if err != nil {
+		t.Fatalf("create mill: %v", err)
+	}
+
+	// 2026-03-08 is a Sunday, so the range runs over two weeks starting on
+	// Monday 2026-03-02 and Monday 2026-03-09
+	at := func(value string) time.Time {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+	for _, task := range []struct {
+		building  uint
+		created   string
+		completed string
+	}{
+		{farm.ID, "2026-03-05T10:00:00Z", "2026-03-05T10:30:00Z"},
+		// stored with its offset, this is completed late on the 6th in UTC
+		{farm.ID, "2026-03-06T09:00:00Z", "2026-03-07T01:30:00+02:00"},
+		{farm.ID, "2026-03-07T00:00:00Z", "2026-03-07T01:00:00Z"},
+		{mill.ID, "2026-03-08T22:00:00Z", "2026-03-10T00:00:00Z"},
+		{mill.ID, "2026-03-11T06:00:00Z", "2026-03-11T07:00:00Z"},
+		// created before the first day but in the first week
+		{mill.ID, "2026-03-04T12:00:00Z", ""},
+		// created on the 8th in UTC, a Monday where it was written
+		{farm.ID, "2026-03-09T01:00:00+03:00", ""},
+	} {
+		row := models.Task{Name: "Chore", BuildingId: task.building, CreatedAt: at(task.created)}
+		if task.completed != "" {
+			completed := at(task.completed)
+			row.IsCompleted, row.CompletedAt = true, &completed
+		}
+		if err := database.Create(&row).Error; err != nil {
+			t.Fatalf("create task: %v", err)
+		}
+	}
+
+	from, to := at("2026-03-05T00:00:00Z"), at("2026-03-11T00:00:00Z")
+	days, err := stats.GetStats(ctx, StatsOptions{From: from, To: to, Bucket: StatsBucketDay})
+	if err != nil {
+		t.Fatalf("daily stats: %v", err)
+	}
+	wantDays := []StatsBucket{
+		{Start: "2026-03-05", Created: 1, Completed: 1},
+		{Start: "2026-03-06", Created: 1, Completed: 1},
+		{Start: "2026-03-07", Created: 1, Completed: 1},
+		{Start: "2026-03-08", Created: 2},
+		{Start: "2026-03-09"},
+		{Start: "2026-03-10", Completed: 1},
+		{Start: "2026-03-11", Created: 1, Completed: 1},
+	}
+	if !reflect.DeepEqual(days.Activity, wantDays) {
+		t.Fatalf("daily activity\n%+v\nwant\n%+v", days.Activity, wantDays)
+	}
+	if want := (CompletionStreaks{Current: 2, Longest: 3, LongestStart: "2026-03-05"}); days.Streaks != want {
+		t.Fatalf("daily streaks %+v, want %+v", days.Streaks, want)
+	}
+	wantBuildings := []CompletionTime{
+		{ID: farm.ID, Name: "Farm", Completed: 3, MeanMinutes: (30 + 870 + 60) / 3.0},
+		{ID: mill.ID, Name: "Mill", Completed: 2, MeanMinutes: (1560 + 60) / 2.0},
+	}
+	if !reflect.DeepEqual(days.Buildings, wantBuildings) {
+		t.Fatalf("building completion times %+v, want %+v", days.Buildings, wantBuildings)
+	}
+	meanByCategory := map[string]float64{}
+	for _, category := range days.Categories {
+		meanByCategory[category.Name] = category.MeanMinutes
+	}
+	if want := map[string]float64{"food": (30 + 870 + 60 + 1560 + 60) / 5.0, "craft": (1560 + 60) / 2.0}; !reflect.DeepEqual(meanByCategory, want) {
+		t.Fatalf("category means %v, want %v", meanByCategory, want)
+	}
+
+	weeks, err := stats.GetStats(ctx, StatsOptions{From: from, To: to, Bucket: StatsBucketWeek})
+	if err != nil {
+		t.Fatalf("weekly stats: %v", err)
+	}
+	wantWeeks := []StatsBucket{
+		{Start: "2026-03-02", Created: 6, Completed: 3},
+		{Start: "2026-03-09", Created: 1, Completed: 2},
+	}
+	if weeks.From != "2026-03-02" || !reflect.DeepEqual(weeks.Activity, wantWeeks) {
+		t.Fatalf("weekly activity from %s\n%+v\nwant\n%+v", weeks.From, weeks.Activity, wantWeeks)
+	}
+	if want := (CompletionStreaks{Current: 2, Longest: 2, LongestStart: "2026-03-02"}); weeks.Streaks != want {
+		t.Fatalf("weekly streaks %+v, want %+v", weeks.Streaks, want)
+	}
+}
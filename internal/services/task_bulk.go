@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Stckrz/villageApi/internal/db/models"
+	"github.com/Stckrz/villageApi/internal/services/repository"
+)
+
+const (
+	BulkCreate   = "create"
+	BulkUpdate   = "update"
+	BulkDelete   = "delete"
+	BulkComplete = "complete"
+
+	// MaxBulkOperations caps the operations of a single bulk request.
+	MaxBulkOperations = 500
+)
+
+var (
+	ErrBulkFailed      = errors.New("bulk operation failed, nothing was changed")
+	ErrTooManyBulkOps  = fmt.Errorf("a bulk request takes at most %d operations", MaxBulkOperations)
+	errUnknownBulkOp   = errors.New("unknown operation, expected create, update, delete or complete")
+	errBulkIDRequired  = errors.New("id is required")
+	errBulkIDForbidden = errors.New("create takes no id")
+)
+
+// BulkTaskOperation is one step of a bulk request. Task carries the fields for
+// create and update, with the same meaning as in CreateTask and UpdateTask.
+type BulkTaskOperation struct {
+	Op   string
+	ID   uint
+	Task models.Task
+}
+
+type BulkTaskResult struct {
+	Index int    `json:"index"`
+	Op    string `json:"op"`
+	ID    uint   `json:"id,omitempty"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// BulkTaskReport has one result per operation, in request order. When any
+// operation fails Committed is false and none of them took effect, including
+// the ones reported as ok.
+type BulkTaskReport struct {
+	Committed bool             `json:"committed"`
+	Results   []BulkTaskResult `json:"results"`
+}
+
+// BulkTasks runs the operations in order inside one transaction. Every
+// operation is attempted, each in its own savepoint, so a single request reports
+// every failing item; if any failed, the whole transaction is rolled back.
+// Later operations see the effect of earlier ones, so a request can create a
+// task and complete it again further down.
+func (s *taskService) BulkTasks(ctx context.Context, operations []BulkTaskOperation) (BulkTaskReport, error) {
+	if len(operations) > MaxBulkOperations {
+		return BulkTaskReport{}, ErrTooManyBulkOps
+	}
+
+	report := BulkTaskReport{Results: make([]BulkTaskResult, 0, len(operations))}
+	err := s.store.Transaction(ctx, func(transaction repository.Store) error {
+		failed := false
+		for index, operation := range operations {
+			result := BulkTaskResult{Index: index, Op: operation.Op, ID: operation.ID}
+			err := transaction.Transaction(ctx, func(savepoint repository.Store) error {
+				id, err := s.bulkTask(ctx, savepoint, operation)
+				result.ID = id
+				return err
+			})
+			if err != nil {
+				failed = true
+				result.Error = bulkTaskError(err, operation)
+			} else {
+				result.OK = true
+			}
+			report.Results = append(report.Results, result)
+		}
+		if failed {
+			return ErrBulkFailed
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, ErrBulkFailed) {
+		return BulkTaskReport{}, err
+	}
+	// operations failing because the request ended say nothing about the items
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return BulkTaskReport{}, ctxErr
+	}
+	report.Committed = err == nil
+	return report, err
+}
+
+// bulkTask applies one operation and returns the id of the task it touched.
+func (s *taskService) bulkTask(ctx context.Context, store repository.Store, operation BulkTaskOperation) (uint, error) {
+	switch operation.Op {
+	case BulkUpdate, BulkDelete, BulkComplete:
+		if operation.ID == 0 {
+			return 0, errBulkIDRequired
+		}
+	}
+
+	switch operation.Op {
+	case BulkCreate:
+		if operation.ID != 0 {
+			return 0, errBulkIDForbidden
+		}
+		task := operation.Task
+		task.Kind = models.TaskKindChore
+		if err := createTask(ctx, store, &task); err != nil {
+			return 0, err
+		}
+		return task.ID, nil
+	case BulkUpdate:
+		return operation.ID, updateTask(ctx, store, s.buildingTypes, operation.Task, operation.ID)
+	case BulkDelete:
+		return operation.ID, deleteTask(ctx, store, operation.ID)
+	case BulkComplete:
+		task, err := store.Tasks().Get(ctx, operation.ID)
+		if err != nil {
+			return operation.ID, err
+		}
+		if task.CompletedAt == nil {
+			now := time.Now()
+			task.CompletedAt = &now
+		}
+		task.IsCompleted = true
+		if err := store.Tasks().Update(ctx, task); err != nil {
+			return operation.ID, err
+		}
+		return operation.ID, finishConstruction(ctx, store, s.buildingTypes, task.BuildingId)
+	default:
+		return operation.ID, errUnknownBulkOp
+	}
+}
+
+// bulkTaskError words an error for the per-item report.
+func bulkTaskError(err error, operation BulkTaskOperation) string {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return fmt.Sprintf("task %d does not exist", operation.ID)
+	case errors.Is(err, repository.ErrMissingBuilding):
+		return fmt.Sprintf("building %d does not exist", operation.Task.BuildingId)
+	}
+	return err.Error()
+}
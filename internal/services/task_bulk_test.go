@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/Stckrz/villageApi/internal/catalog"
+	"github.com/Stckrz/villageApi/internal/db/models"
+	"github.com/Stckrz/villageApi/internal/services/repository"
+)
+
+func TestBulkTasksIsAllOrNothing(t *testing.T) {
+	for name, newStore := range map[string]func(t *testing.T) repository.Store{
+		"gorm":   func(t *testing.T) repository.Store { return repository.NewGormStore(newTestDB(t)) },
+		"memory": func(t *testing.T) repository.Store { return repository.NewMemoryStore() },
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			store := newStore(t)
+			tasks := NewTaskService(store, catalog.Default())
+
+			barn := models.Building{Name: "Barn"}
+			if err := store.Buildings().Create(ctx, &barn); err != nil {
+				t.Fatalf("create building: %v", err)
+			}
+			sweep, err := tasks.CreateTask(ctx, models.Task{Name: "Sweep", BuildingId: barn.ID})
+			if err != nil {
+				t.Fatalf("create task: %v", err)
+			}
+			feed, err := tasks.CreateTask(ctx, models.Task{Name: "Feed", BuildingId: barn.ID})
+			if err != nil {
+				t.Fatalf("create task: %v", err)
+			}
+			before, err := tasks.ListTasks(ctx, TaskFilter{})
+			if err != nil {
+				t.Fatalf("list: %v", err)
+			}
+
+			report, err := tasks.BulkTasks(ctx, []BulkTaskOperation{
+				{Op: BulkCreate, Task: models.Task{Name: "Paint", BuildingId: barn.ID}},
+				{Op: BulkComplete, ID: sweep.ID},
+				{Op: BulkUpdate, ID: 999, Task: models.Task{Name: "Ghost", BuildingId: barn.ID}},
+				{Op: BulkDelete, ID: feed.ID},
+				{Op: BulkCreate, Task: models.Task{Name: "Lost", BuildingId: 999}},
+				{Op: "rename", ID: sweep.ID},
+			})
+			if !errors.Is(err, ErrBulkFailed) {
+				t.Fatalf("mixed batch: %v", err)
+			}
+			if report.Committed || len(report.Results) != 6 {
+				t.Fatalf("report %+v", report)
+			}
+			var ok []bool
+			for index, result := range report.Results {
+				if result.Index != index {
+					t.Fatalf("result %d has index %d", index, result.Index)
+				}
+				ok = append(ok, result.OK)
+			}
+			if want := []bool{true, true, false, true, false, false}; !reflect.DeepEqual(ok, want) {
+				t.Fatalf("results ok %v, want %v: %+v", ok, want, report.Results)
+			}
+			if report.Results[2].Error != "task 999 does not exist" || report.Results[4].Error != "building 999 does not exist" {
+				t.Fatalf("item errors %+v", report.Results)
+			}
+
+			// none of it stuck, the operations reported ok included
+			after, err := tasks.ListTasks(ctx, TaskFilter{})
+			if err != nil {
+				t.Fatalf("list: %v", err)
+			}
+			if !reflect.DeepEqual(taskStates(after), taskStates(before)) {
+				t.Fatalf("tasks after a failed batch %v, want %v", taskStates(after), taskStates(before))
+			}
+
+			report, err = tasks.BulkTasks(ctx, []BulkTaskOperation{
+				{Op: BulkCreate, Task: models.Task{Name: "Paint", BuildingId: barn.ID}},
+				{Op: BulkComplete, ID: sweep.ID},
+				{Op: BulkDelete, ID: feed.ID},
+			})
+			if err != nil || !report.Committed {
+				t.Fatalf("clean batch: %+v, %v", report, err)
+			}
+			after, err = tasks.ListTasks(ctx, TaskFilter{})
+			if err != nil {
+				t.Fatalf("list: %v", err)
+			}
+			if want := []string{"Sweep done", "Paint open"}; !reflect.DeepEqual(taskStates(after), want) {
+				t.Fatalf("tasks after a clean batch %v, want %v", taskStates(after), want)
+			}
+
+			if _, err := tasks.BulkTasks(ctx, make([]BulkTaskOperation, MaxBulkOperations+1)); !errors.Is(err, ErrTooManyBulkOps) {
+				t.Fatalf("%d operations: %v", MaxBulkOperations+1, err)
+			}
+		})
+	}
+}
+
+// taskStates names each task with whether it is done, in list order.
+func taskStates(tasks []models.Task) []string {
+	states := make([]string, 0, len(tasks))
+	for _, task := range tasks {
+		state := " open"
+		if task.IsCompleted {
+			state = " done"
+		}
+		states = append(states, task.Name+state)
+	}
+	return states
+}
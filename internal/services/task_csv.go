@@ -0,0 +1,440 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Stckrz/villageApi/internal/db/models"
+	"github.com/Stckrz/villageApi/internal/services/repository"
+)
+
+var ErrInvalidTaskImport = errors.New("task import has invalid rows")
+
+// taskCSVColumns is the column order of the export. The import reads columns by
+// header name, so an exported file can be edited and sent straight back; kind
+// and completed_at are informational and ignored on import.
+var taskCSVColumns = []string{
+	"id", "name", "description", "building_id", "building", "kind",
+	"estimated_minutes", "is_completed", "completed_at",
+}
+
+// TaskImportError is one problem with one row. Row is the line in the file, so
+// the header is row 1, matching what a spreadsheet shows.
+type TaskImportError struct {
+	Row     int    `json:"row"`
+	Column  string `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+type TaskImportReport struct {
+	Created int               `json:"created"`
+	Updated int               `json:"updated"`
+	Errors  []TaskImportError `json:"errors"`
+}
+
+// WriteTasksCSV writes the tasks matching filter as CSV, with a header row.
+func (s *taskService) WriteTasksCSV(ctx context.Context, w io.Writer, filter TaskFilter) error {
+	tasks, err := s.store.Tasks().List(ctx, repository.TaskQuery(filter))
+	if err != nil {
+		return err
+	}
+	// only the names are needed, read in one go rather than building by building
+	buildings, err := s.store.Buildings().List(ctx, repository.BuildingQuery{Preload: []string{}, Columns: []string{"name"}})
+	if err != nil {
+		return err
+	}
+	buildingNames := make(map[uint]string, len(buildings))
+	for _, building := range buildings {
+		buildingNames[building.ID] = building.Name
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(taskCSVColumns); err != nil {
+		return err
+	}
+	for _, task := range tasks {
+		buildingName := buildingNames[task.BuildingId]
+		completedAt := ""
+		if task.CompletedAt != nil {
+			completedAt = task.CompletedAt.UTC().Format(time.RFC3339)
+		}
+		if err := writer.Write([]string{
+			strconv.FormatUint(uint64(task.ID), 10),
+			escapeCSVCell(task.Name),
+			escapeCSVCell(task.Description),
+			strconv.FormatUint(uint64(task.BuildingId), 10),
+			escapeCSVCell(buildingName),
+			task.Kind,
+			strconv.FormatUint(uint64(task.EstimatedMinutes), 10),
+			strconv.FormatBool(task.IsCompleted),
+			completedAt,
+		}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// formulaPrefixes are what a spreadsheet reads as the start of a formula.
+const formulaPrefixes = "=+-@\t\r"
+
+// escapeCSVCell keeps user text from being run as a formula when the export is
+// opened in a spreadsheet: a cell starting like one gets a leading ', which
+// spreadsheets take as "this is text". Cells already starting with ' get one
+// too, so unescapeCSVCell can tell the two apart.
+func escapeCSVCell(value string) string {
+	if value != "" && strings.ContainsRune(formulaPrefixes+"'", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// unescapeCSVCell undoes escapeCSVCell, so an export imports back unchanged.
+func unescapeCSVCell(value string) string {
+	if len(value) > 1 && value[0] == '\'' && strings.ContainsRune(formulaPrefixes+"'", rune(value[1])) {
+		return value[1:]
+	}
+	return value
+}
+
+// taskImportRow is a validated row, ready to be written.
+type taskImportRow struct {
+	id               uint
+	name             string
+	description      string
+	buildingID       uint
+	estimatedMinutes *uint
+	isCompleted      bool
+}
+
+// ImportTasksCSV creates a task for every row without an id and updates the
+// task of every row with one. The building is given by building_id or by its
+// name in the building column. Every row is checked in the same transaction
+// that writes them; if any row is invalid the report lists the problems and
+// nothing is imported.
+func (s *taskService) ImportTasksCSV(ctx context.Context, r io.Reader) (TaskImportReport, error) {
+	report := TaskImportReport{Errors: []TaskImportError{}}
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		report.Errors = append(report.Errors, TaskImportError{Row: 1, Message: "file is empty"})
+		return report, ErrInvalidTaskImport
+	}
+	if err != nil {
+		report.Errors = append(report.Errors, TaskImportError{Row: 1, Message: err.Error()})
+		return report, ErrInvalidTaskImport
+	}
+	// spreadsheets like to save a byte order mark in front of the first header
+	columns := map[string]int{}
+	for index, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = index
+	}
+	if _, ok := columns["name"]; !ok {
+		report.Errors = append(report.Errors, TaskImportError{Row: 1, Column: "name", Message: "header has no name column"})
+	}
+	_, hasBuildingID := columns["building_id"]
+	_, hasBuilding := columns["building"]
+	if !hasBuildingID && !hasBuilding {
+		report.Errors = append(report.Errors, TaskImportError{Row: 1, Column: "building_id", Message: "header needs a building_id or building column"})
+	}
+	if len(report.Errors) > 0 {
+		return report, ErrInvalidTaskImport
+	}
+
+	// the file is read in full first, so the transaction isn't held open on a
+	// slow upload
+	type record struct {
+		line  int
+		value func(column string) string
+	}
+	var records []record
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			report.Errors = append(report.Errors, TaskImportError{Row: parseErr.StartLine, Message: parseErr.Err.Error()})
+			continue
+		}
+		if err != nil {
+			return TaskImportReport{}, err
+		}
+		if strings.Join(fields, "") == "" {
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+		records = append(records, record{line: line, value: func(column string) string {
+			if index, ok := columns[column]; ok && index < len(fields) {
+				return unescapeCSVCell(strings.TrimSpace(fields[index]))
+			}
+			return ""
+		}})
+	}
+	if len(report.Errors) > 0 {
+		return report, ErrInvalidTaskImport
+	}
+
+	err = s.store.Transaction(ctx, func(transaction repository.Store) error {
+		// rows are validated against what the transaction sees, so a task or
+		// building removed since the upload started is a row error, not a failure
+		var rows []taskImportRow
+		seenIDs := map[uint]int{}
+		buildings := newBuildingLookup(transaction)
+		for _, record := range records {
+			row, rowErrors := parseTaskRow(ctx, transaction, buildings, record.line, record.value)
+			if row.id != 0 {
+				if first, ok := seenIDs[row.id]; ok {
+					rowErrors = append(rowErrors, TaskImportError{Row: record.line, Column: "id", Message: fmt.Sprintf("task %d is already updated by row %d", row.id, first)})
+				}
+				seenIDs[row.id] = record.line
+			}
+			if len(rowErrors) > 0 {
+				report.Errors = append(report.Errors, rowErrors...)
+				continue
+			}
+			rows = append(rows, row)
+		}
+		if len(report.Errors) > 0 {
+			return ErrInvalidTaskImport
+		}
+
+		completed := map[uint]bool{}
+		for _, row := range rows {
+			if row.id == 0 {
+				task := models.Task{
+					Name:        row.name,
+					Description: row.description,
+					BuildingId:  row.buildingID,
+					Kind:        models.TaskKindChore,
+					IsCompleted: row.isCompleted,
+				}
+				if row.estimatedMinutes != nil {
+					task.EstimatedMinutes = *row.estimatedMinutes
+				}
+				if task.IsCompleted {
+					now := time.Now()
+					task.CompletedAt = &now
+				}
+				if err := transaction.Tasks().Create(ctx, &task); err != nil {
+					return err
+				}
+				report.Created++
+				continue
+			}
+
+			existing, err := transaction.Tasks().Get(ctx, row.id)
+			if err != nil {
+				return err
+			}
+			task := existing
+			task.Name = row.name
+			task.Description = row.description
+			task.BuildingId = row.buildingID
+			task.IsCompleted = row.isCompleted
+			if row.estimatedMinutes != nil {
+				task.EstimatedMinutes = *row.estimatedMinutes
+			}
+			switch {
+			case !row.isCompleted:
+				task.CompletedAt = nil
+			case existing.CompletedAt == nil:
+				now := time.Now()
+				task.CompletedAt = &now
+			}
+			if err := transaction.Tasks().Update(ctx, task); err != nil {
+				return err
+			}
+			if task.IsCompleted && !existing.IsCompleted {
+				completed[task.BuildingId] = true
+			}
+			report.Updated++
+		}
+		// completing construction tasks through a spreadsheet finishes buildings
+		// just like completing them one by one would
+		for buildingID := range completed {
+			if err := finishConstruction(ctx, transaction, s.buildingTypes, buildingID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, ErrInvalidTaskImport) {
+		return report, err
+	}
+	if err != nil {
+		return TaskImportReport{}, err
+	}
+	return report, nil
+}
+
+// buildingLookup finds the buildings an import refers to by id or name. Only ids
+// and names are read, and each lookup once, since a file usually names the same
+// few buildings on every row.
+type buildingLookup struct {
+	store   repository.Store
+	names   map[uint]string
+	missing map[uint]bool
+	ids     map[string][]uint
+}
+
+func newBuildingLookup(store repository.Store) *buildingLookup {
+	return &buildingLookup{store: store, names: map[uint]string{}, missing: map[uint]bool{}, ids: map[string][]uint{}}
+}
+
+// name returns the name of building id, or repository.ErrNotFound.
+func (l *buildingLookup) name(ctx context.Context, id uint) (string, error) {
+	if name, ok := l.names[id]; ok {
+		return name, nil
+	}
+	if l.missing[id] {
+		return "", repository.ErrNotFound
+	}
+	buildings, err := l.list(ctx, repository.BuildingQuery{ID: id})
+	if err != nil {
+		return "", err
+	}
+	if len(buildings) == 0 {
+		l.missing[id] = true
+		return "", repository.ErrNotFound
+	}
+	return buildings[0].Name, nil
+}
+
+// named returns the ids of the buildings called name.
+func (l *buildingLookup) named(ctx context.Context, name string) ([]uint, error) {
+	if ids, ok := l.ids[name]; ok {
+		return ids, nil
+	}
+	buildings, err := l.list(ctx, repository.BuildingQuery{Name: name})
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uint, 0, len(buildings))
+	for _, building := range buildings {
+		ids = append(ids, building.ID)
+	}
+	l.ids[name] = ids
+	return ids, nil
+}
+
+func (l *buildingLookup) list(ctx context.Context, query repository.BuildingQuery) ([]models.Building, error) {
+	query.Preload, query.Columns = []string{}, []string{"name"}
+	buildings, err := l.store.Buildings().List(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	for _, building := range buildings {
+		l.names[building.ID] = building.Name
+	}
+	return buildings, nil
+}
+
+// parseTaskRow validates one row, looking referenced tasks and buildings up.
+func parseTaskRow(ctx context.Context, store repository.Store, buildings *buildingLookup, line int, value func(column string) string) (taskImportRow, []TaskImportError) {
+	var row taskImportRow
+	var existing models.Task
+	var rowErrors []TaskImportError
+	fail := func(column string, format string, args ...any) {
+		rowErrors = append(rowErrors, TaskImportError{Row: line, Column: column, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if id := value("id"); id != "" {
+		parsed, err := strconv.ParseUint(id, 10, 64)
+		if err != nil || parsed == 0 {
+			fail("id", "%q is not a task id", id)
+		} else {
+			row.id = uint(parsed)
+			var err error
+			if existing, err = store.Tasks().Get(ctx, row.id); errors.Is(err, repository.ErrNotFound) {
+				fail("id", "task %d does not exist", row.id)
+			} else if err != nil {
+				fail("id", "%v", err)
+			}
+		}
+	}
+
+	row.name = value("name")
+	if row.name == "" {
+		fail("name", "name is required")
+	}
+	row.description = value("description")
+
+	if minutes := value("estimated_minutes"); minutes != "" {
+		parsed, err := strconv.ParseUint(minutes, 10, 32)
+		if err != nil {
+			fail("estimated_minutes", "%q is not a whole number of minutes", minutes)
+		} else {
+			estimated := uint(parsed)
+			row.estimatedMinutes = &estimated
+		}
+	}
+
+	switch completed := strings.ToLower(value("is_completed")); completed {
+	case "", "no", "n":
+	case "yes", "y":
+		row.isCompleted = true
+	default:
+		parsed, err := strconv.ParseBool(completed)
+		if err != nil {
+			fail("is_completed", "%q is not true or false", completed)
+		}
+		row.isCompleted = parsed
+	}
+
+	buildingID, buildingName := value("building_id"), value("building")
+	switch {
+	case buildingID != "":
+		parsed, err := strconv.ParseUint(buildingID, 10, 64)
+		if err != nil || parsed == 0 {
+			fail("building_id", "%q is not a building id", buildingID)
+			break
+		}
+		name, err := buildings.name(ctx, uint(parsed))
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				fail("building_id", "building %d does not exist", parsed)
+			} else {
+				fail("building_id", "%v", err)
+			}
+			break
+		}
+		if buildingName != "" && buildingName != name {
+			fail("building", "building %d is named %q, not %q", parsed, name, buildingName)
+			break
+		}
+		row.buildingID = uint(parsed)
+	case buildingName != "":
+		ids, err := buildings.named(ctx, buildingName)
+		if err != nil {
+			fail("building", "%v", err)
+			break
+		}
+		switch len(ids) {
+		case 0:
+			fail("building", "no building is named %q", buildingName)
+		case 1:
+			row.buildingID = ids[0]
+		default:
+			fail("building", "more than one building is named %q; use building_id", buildingName)
+		}
+	default:
+		fail("building_id", "building_id or building is required")
+	}
+	if existing.Kind == models.TaskKindConstruction && !existing.IsCompleted && row.buildingID != 0 && row.buildingID != existing.BuildingId {
+		fail("building_id", "open construction task %d stays with building %d", existing.ID, existing.BuildingId)
+	}
+
+	return row, rowErrors
+}
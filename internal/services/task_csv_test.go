@@ -0,0 +1,129 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Stckrz/villageApi/internal/catalog"
+	"github.com/Stckrz/villageApi/internal/db/models"
+	"github.com/Stckrz/villageApi/internal/services/repository"
+)
+
+func TestTasksCSVRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore()
+	tasks := NewTaskService(store, catalog.Default())
+
+	barn := models.Building{Name: "=Barn"}
+	if err := store.Buildings().Create(ctx, &barn); err != nil {
+		t.Fatalf("create building: %v", err)
+	}
+	names := []string{`=HYPERLINK("http://example.com","x")`, "+1", "-2", "@SUM(A1)", "\tTab", "'quoted", "plain"}
+	for _, name := range names {
+		task := models.Task{Name: name, Description: "=1+1", BuildingId: barn.ID, Kind: models.TaskKindChore, EstimatedMinutes: 5}
+		if err := store.Tasks().Create(ctx, &task); err != nil {
+			t.Fatalf("create task %q: %v", name, err)
+		}
+	}
+
+	var exported bytes.Buffer
+	if err := tasks.WriteTasksCSV(ctx, &exported, TaskFilter{}); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	records, err := csv.NewReader(bytes.NewReader(exported.Bytes())).ReadAll()
+	if err != nil {
+		t.Fatalf("read export: %v", err)
+	}
+	for _, record := range records[1:] {
+		for _, cell := range []string{record[1], record[2], record[4]} {
+			if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+				t.Fatalf("cell %q would run as a formula", cell)
+			}
+		}
+	}
+
+	report, err := tasks.ImportTasksCSV(ctx, bytes.NewReader(exported.Bytes()))
+	if err != nil {
+		t.Fatalf("import the export: %v %+v", err, report.Errors)
+	}
+	if report.Created != 0 || report.Updated != len(names) {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	imported, err := tasks.ListTasks(ctx, TaskFilter{})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	for index, task := range imported {
+		if task.Name != names[index] || task.Description != "=1+1" || task.EstimatedMinutes != 5 {
+			t.Fatalf("task %d came back as %q %q %d", task.ID, task.Name, task.Description, task.EstimatedMinutes)
+		}
+	}
+
+	file := exported.String() + "999,Ghost,,1,,,,,\n,New,,,'=Barn,,,,\n"
+	report, err = tasks.ImportTasksCSV(ctx, strings.NewReader(file))
+	if !errors.Is(err, ErrInvalidTaskImport) {
+		t.Fatalf("import with a missing task: %v", err)
+	}
+	if len(report.Errors) != 1 || report.Errors[0].Row != len(names)+2 || report.Errors[0].Column != "id" {
+		t.Fatalf("unexpected errors %+v", report.Errors)
+	}
+	if after, _ := tasks.ListTasks(ctx, TaskFilter{}); len(after) != len(names) {
+		t.Fatalf("a refused import wrote %d tasks", len(after)-len(names))
+	}
+}
+
+func TestImportTasksCSVBuildingReferences(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore()
+	tasks := NewTaskService(store, catalog.Default())
+	var barn models.Building
+	for _, name := range []string{"Barn", "Shed", "Shed"} {
+		building := models.Building{Name: name}
+		if err := store.Buildings().Create(ctx, &building); err != nil {
+			t.Fatalf("create building: %v", err)
+		}
+		if name == "Barn" {
+			barn = building
+		}
+	}
+
+	file := "name,building_id,building\n" +
+		"Sweep,,Barn\n" +
+		"Paint,,Barn\n" +
+		"Oil,,Shed\n" +
+		"Feed,999,\n" +
+		"Water,999,\n" +
+		"Mend,1,Shed\n" +
+		"Dig,,Well\n"
+	report, err := tasks.ImportTasksCSV(ctx, strings.NewReader(file))
+	if !errors.Is(err, ErrInvalidTaskImport) {
+		t.Fatalf("import: %v", err)
+	}
+	var problems []string
+	for _, problem := range report.Errors {
+		problems = append(problems, problem.Column+": "+problem.Message)
+	}
+	want := []string{
+		`building: more than one building is named "Shed"; use building_id`,
+		"building_id: building 999 does not exist",
+		"building_id: building 999 does not exist",
+		`building: building 1 is named "Barn", not "Shed"`,
+		`building: no building is named "Well"`,
+	}
+	if strings.Join(problems, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("errors\n%s\nwant\n%s", strings.Join(problems, "\n"), strings.Join(want, "\n"))
+	}
+
+	report, err = tasks.ImportTasksCSV(ctx, strings.NewReader("name,building\nSweep,Barn\nPaint,Barn\n"))
+	if err != nil || report.Created != 2 {
+		t.Fatalf("import: %+v, %v", report, err)
+	}
+	created, err := tasks.ListTasks(ctx, TaskFilter{BuildingID: &barn.ID})
+	if err != nil || len(created) != 2 {
+		t.Fatalf("barn tasks %+v, %v", created, err)
+	}
+}
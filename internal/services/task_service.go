@@ -1,33 +1,45 @@
 package services
 
 import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/Stckrz/villageApi/internal/catalog"
 	"github.com/Stckrz/villageApi/internal/db/models"
-	"gorm.io/gorm"
+	"github.com/Stckrz/villageApi/internal/services/repository"
 )
 
 type TaskService interface {
-	ListTasks() ([]models.Task, error)
+	ListTasks(ctx context.Context, filter TaskFilter) ([]models.Task, error)
 	// ListTasksByBuildingId(buildingId uint) ([]models.Task, error)
-	CreateTask(task models.Task) (models.Task, error)
-	DeleteTask(id uint) error
-	UpdateTask(task models.Task, id uint) (error)
+	CreateTask(ctx context.Context, task models.Task) (models.Task, error)
+	DeleteTask(ctx context.Context, id uint) error
+	UpdateTask(ctx context.Context, task models.Task, id uint) (error)
+	WriteTasksCSV(ctx context.Context, w io.Writer, filter TaskFilter) error
+	ImportTasksCSV(ctx context.Context, r io.Reader) (TaskImportReport, error)
+	BulkTasks(ctx context.Context, operations []BulkTaskOperation) (BulkTaskReport, error)
 }
 
-type taskService struct {
-	db *gorm.DB
+// TaskFilter narrows ListTasks and the CSV export. Nil fields don't filter.
+type TaskFilter struct {
+	BuildingID  *uint
+	VillageID   *uint
+	IsCompleted *bool
+	Kind        string
 }
 
-func NewTaskService(db *gorm.DB) TaskService {
-	return &taskService{db: db}
+type taskService struct {
+	store         repository.Store
+	buildingTypes *catalog.Catalog
 }
 
-func (s *taskService) ListTasks() ([]models.Task, error) {
-	var tasks []models.Task
-	if err := s.db.Find(&tasks).Error; err != nil {
-		return nil, err
-	}
+func NewTaskService(store repository.Store, buildingTypes *catalog.Catalog) TaskService {
+	return &taskService{store: store, buildingTypes: buildingTypes}
+}
 
-	return tasks, nil
+func (s *taskService) ListTasks(ctx context.Context, filter TaskFilter) ([]models.Task, error) {
+	return s.store.Tasks().List(ctx, repository.TaskQuery(filter))
 }
 
 // func (s *taskService) ListTasksByBuildingId(buildingId uint) ([]models.Task, error) {
@@ -40,58 +52,79 @@ func (s *taskService) ListTasks() ([]models.Task, error) {
 // 	return tasks, nil
 // }
 
-func (s *taskService) CreateTask(task models.Task) (models.Task, error) {
-	var count int64
-	if err := s.db.Model(&models.Building{}).
-		Where("id = ?", task.BuildingId).
-		Count(&count).Error; err != nil {
+func (s *taskService) CreateTask(ctx context.Context, task models.Task) (models.Task, error) {
+	if err := createTask(ctx, s.store, &task); err != nil {
 		return models.Task{}, err
 	}
-	if count == 0 {
-		return models.Task{}, gorm.ErrRecordNotFound
+	return task, nil
+}
+
+// DeleteTask removes a task. Open construction tasks are refused: the building
+// only finishes once they are completed.
+func (s *taskService) DeleteTask(ctx context.Context, id uint) error {
+	return s.store.Transaction(ctx, func(store repository.Store) error {
+		return deleteTask(ctx, store, id)
+	})
+}
+
+// UpdateTask saves a task and, when completing it leaves a building with no open
+// construction tasks, finishes the building's construction.
+func (s *taskService) UpdateTask(ctx context.Context, task models.Task, id uint) error {
+	return s.store.Transaction(ctx, func(store repository.Store) error {
+		return updateTask(ctx, store, s.buildingTypes, task, id)
+	})
+}
+
+func createTask(ctx context.Context, store repository.Store, task *models.Task) error {
+	if task.IsCompleted && task.CompletedAt == nil {
+		now := time.Now()
+		task.CompletedAt = &now
 	}
+	return store.Tasks().Create(ctx, task)
+}
 
-	if err := s.db.Create(&task).Error; err != nil {
-		return models.Task{}, err
+func deleteTask(ctx context.Context, store repository.Store, id uint) error {
+	task, err := store.Tasks().Get(ctx, id)
+	if err != nil {
+		return err
 	}
-	if err := s.db.Preload("Building").First(&task, task.ID).Error; err != nil {
-		return models.Task{}, err
+	if task.Kind == models.TaskKindConstruction && !task.IsCompleted {
+		return ErrConstructionTask
 	}
-
-	return task, nil
+	return store.Tasks().Delete(ctx, id)
 }
 
-func (s *taskService) DeleteTask(id uint) error {
-	result := s.db.Delete(&models.Task{}, id)
-	if result.Error != nil {
-		return result.Error
+// updateTask saves the name, description, building and completion of task over
+// the stored one. Kind and estimate stay as they are, and the completion time
+// is kept if the task was already completed. An open construction task stays
+// with its building.
+func updateTask(ctx context.Context, store repository.Store, buildingTypes *catalog.Catalog, task models.Task, id uint) error {
+	existing, err := store.Tasks().Get(ctx, id)
+	if err != nil {
+		return err
 	}
-	if result.RowsAffected == 0 {
-		return gorm.ErrRecordNotFound
+	if existing.Kind == models.TaskKindConstruction && !existing.IsCompleted && task.BuildingId != existing.BuildingId {
+		return ErrConstructionTask
 	}
-	return nil
-}
 
-func (s *taskService) UpdateTask(task models.Task, id uint) error {
-	return s.db.Transaction(func(transaction *gorm.DB) error {
-		result := transaction.
-			Model(&models.Task{}).
-			Where("id = ?", id).
-			Updates(map[string]any{
-				"name":         task.Name,
-				"description":  task.Description,
-				"building_id":  task.BuildingId,
-				"is_completed": task.IsCompleted,
-			})
-		if result.Error != nil {
-			return result.Error
-		}
-
-		if result.RowsAffected == 0 {
-			return gorm.ErrRecordNotFound
-		}
+	updated := existing
+	updated.Name = task.Name
+	updated.Description = task.Description
+	updated.BuildingId = task.BuildingId
+	updated.IsCompleted = task.IsCompleted
+	switch {
+	case !task.IsCompleted:
+		updated.CompletedAt = nil
+	case existing.CompletedAt == nil:
+		now := time.Now()
+		updated.CompletedAt = &now
+	}
+	if err := store.Tasks().Update(ctx, updated); err != nil {
+		return err
+	}
 
+	if !updated.IsCompleted || existing.IsCompleted {
 		return nil
-
-	})
+	}
+	return finishConstruction(ctx, store, buildingTypes, updated.BuildingId)
 }
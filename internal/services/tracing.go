@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/Stckrz/villageApi/internal/db/models"
+	"github.com/Stckrz/villageApi/internal/tracing"
+)
+
+// The traced services wrap a service so each call gets a span, named after the
+// interface and method, between the request's span and its statements' spans.
+
+type tracedBuildingService struct {
+	next BuildingService
+}
+
+func TraceBuildingService(next BuildingService) BuildingService {
+	return tracedBuildingService{next: next}
+}
+
+func (s tracedBuildingService) GetBuildingByID(ctx context.Context, id uint) (building models.Building, err error) {
+	ctx, span := tracing.Start(ctx, "BuildingService.GetBuildingByID")
+	defer func() { tracing.End(span, err) }()
+	return s.next.GetBuildingByID(ctx, id)
+}
+
+func (s tracedBuildingService) GetBuilding(ctx context.Context, id uint, view BuildingView) (building models.Building, err error) {
+	ctx, span := tracing.Start(ctx, "BuildingService.GetBuilding")
+	defer func() { tracing.End(span, err) }()
+	return s.next.GetBuilding(ctx, id, view)
+}
+
+func (s tracedBuildingService) ListBuildings(ctx context.Context, filter BuildingFilter) (buildings []models.Building, err error) {
+	ctx, span := tracing.Start(ctx, "BuildingService.ListBuildings")
+	defer func() { tracing.End(span, err) }()
+	return s.next.ListBuildings(ctx, filter)
+}
+
+func (s tracedBuildingService) SummarizeBuildings(ctx context.Context, filter BuildingFilter) (summaries []BuildingSummary, err error) {
+	ctx, span := tracing.Start(ctx, "BuildingService.SummarizeBuildings")
+	defer func() { tracing.End(span, err) }()
+	return s.next.SummarizeBuildings(ctx, filter)
+}
+
+func (s tracedBuildingService) CreateBuilding(ctx context.Context, building models.Building) (created models.Building, err error) {
+	ctx, span := tracing.Start(ctx, "BuildingService.CreateBuilding")
+	defer func() { tracing.End(span, err) }()
+	return s.next.CreateBuilding(ctx, building)
+}
+
+func (s tracedBuildingService) DeleteBuilding(ctx context.Context, id uint) (err error) {
+	ctx, span := tracing.Start(ctx, "BuildingService.DeleteBuilding")
+	defer func() { tracing.End(span, err) }()
+	return s.next.DeleteBuilding(ctx, id)
+}
+
+func (s tracedBuildingService) UpdateBuilding(ctx context.Context, building models.Building, id uint, options UpdateOptions) (err error) {
+	ctx, span := tracing.Start(ctx, "BuildingService.UpdateBuilding")
+	defer func() { tracing.End(span, err) }()
+	return s.next.UpdateBuilding(ctx, building, id, options)
+}
+
+func (s tracedBuildingService) UpgradeBuilding(ctx context.Context, id uint) (building models.Building, err error) {
+	ctx, span := tracing.Start(ctx, "BuildingService.UpgradeBuilding")
+	defer func() { tracing.End(span, err) }()
+	return s.next.UpgradeBuilding(ctx, id)
+}
+
+func (s tracedBuildingService) CloneBuilding(ctx context.Context, id uint, options CloneOptions) (building models.Building, err error) {
+	ctx, span := tracing.Start(ctx, "BuildingService.CloneBuilding")
+	defer func() { tracing.End(span, err) }()
+	return s.next.CloneBuilding(ctx, id, options)
+}
+
+func (s tracedBuildingService) MergeBuilding(ctx context.Context, targetID uint, sourceID uint) (building models.Building, err error) {
+	ctx, span := tracing.Start(ctx, "BuildingService.MergeBuilding")
+	defer func() { tracing.End(span, err) }()
+	return s.next.MergeBuilding(ctx, targetID, sourceID)
+}
+
+type tracedTaskService struct {
+	next TaskService
+}
+
+func TraceTaskService(next TaskService) TaskService {
+	return tracedTaskService{next: next}
+}
+
+func (s tracedTaskService) ListTasks(ctx context.Context, filter TaskFilter) (tasks []models.Task, err error) {
+	ctx, span := tracing.Start(ctx, "TaskService.ListTasks")
+	defer func() { tracing.End(span, err) }()
+	return s.next.ListTasks(ctx, filter)
+}
+
+func (s tracedTaskService) CreateTask(ctx context.Context, task models.Task) (created models.Task, err error) {
+	ctx, span := tracing.Start(ctx, "TaskService.CreateTask")
+	defer func() { tracing.End(span, err) }()
+	return s.next.CreateTask(ctx, task)
+}
+
+func (s tracedTaskService) DeleteTask(ctx context.Context, id uint) (err error) {
+	ctx, span := tracing.Start(ctx, "TaskService.DeleteTask")
+	defer func() { tracing.End(span, err) }()
+	return s.next.DeleteTask(ctx, id)
+}
+
+func (s tracedTaskService) UpdateTask(ctx context.Context, task models.Task, id uint) (err error) {
+	ctx, span := tracing.Start(ctx, "TaskService.UpdateTask")
+	defer func() { tracing.End(span, err) }()
+	return s.next.UpdateTask(ctx, task, id)
+}
+
+func (s tracedTaskService) WriteTasksCSV(ctx context.Context, w io.Writer, filter TaskFilter) (err error) {
+	ctx, span := tracing.Start(ctx, "TaskService.WriteTasksCSV")
+	defer func() { tracing.End(span, err) }()
+	return s.next.WriteTasksCSV(ctx, w, filter)
+}
+
+func (s tracedTaskService) ImportTasksCSV(ctx context.Context, r io.Reader) (report TaskImportReport, err error) {
+	ctx, span := tracing.Start(ctx, "TaskService.ImportTasksCSV")
+	defer func() { tracing.End(span, err) }()
+	return s.next.ImportTasksCSV(ctx, r)
+}
+
+func (s tracedTaskService) BulkTasks(ctx context.Context, operations []BulkTaskOperation) (report BulkTaskReport, err error) {
+	ctx, span := tracing.Start(ctx, "TaskService.BulkTasks")
+	defer func() { tracing.End(span, err) }()
+	return s.next.BulkTasks(ctx, operations)
+}
+
+type tracedStatsService struct {
+	next StatsService
+}
+
+func TraceStatsService(next StatsService) StatsService {
+	return tracedStatsService{next: next}
+}
+
+func (s tracedStatsService) GetStats(ctx context.Context, options StatsOptions) (stats Stats, err error) {
+	ctx, span := tracing.Start(ctx, "StatsService.GetStats")
+	defer func() { tracing.End(span, err) }()
+	return s.next.GetStats(ctx, options)
+}
+
+type tracedMetricHistoryService struct {
+	next MetricHistoryService
+}
+
+func TraceMetricHistoryService(next MetricHistoryService) MetricHistoryService {
+	return tracedMetricHistoryService{next: next}
+}
+
+func (s tracedMetricHistoryService) TakeSnapshot(ctx context.Context, now time.Time) (err error) {
+	ctx, span := tracing.Start(ctx, "MetricHistoryService.TakeSnapshot")
+	defer func() { tracing.End(span, err) }()
+	return s.next.TakeSnapshot(ctx, now)
+}
+
+func (s tracedMetricHistoryService) PruneSnapshots(ctx context.Context, before time.Time) (deleted int64, err error) {
+	ctx, span := tracing.Start(ctx, "MetricHistoryService.PruneSnapshots")
+	defer func() { tracing.End(span, err) }()
+	return s.next.PruneSnapshots(ctx, before)
+}
+
+func (s tracedMetricHistoryService) History(ctx context.Context, query MetricHistoryQuery) (series []MetricSeries, err error) {
+	ctx, span := tracing.Start(ctx, "MetricHistoryService.History")
+	defer func() { tracing.End(span, err) }()
+	return s.next.History(ctx, query)
+}
@@ -0,0 +1,189 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+
+	"github.com/Stckrz/villageApi/internal/db/models"
+	"github.com/Stckrz/villageApi/internal/services/repository"
+	"github.com/Stckrz/villageApi/internal/sim"
+	"gorm.io/gorm"
+)
+
+type VillageService interface {
+	GetVillageByID(ctx context.Context, id uint) (models.Village, error)
+	ListVillages(ctx context.Context) ([]models.Village, error)
+	CreateVillage(ctx context.Context, village models.Village, seed *int64) (models.Village, error)
+	DeleteVillage(ctx context.Context, id uint) error
+	RecordAction(ctx context.Context, id uint, action models.VillageAction) (models.Village, error)
+	ReplayVillage(ctx context.Context, id uint, seed *int64) (ReplayReport, error)
+}
+
+// ReplayReport compares the saved state of a village with the state rebuilt
+// from a seed and its action log. Seed is the one replayed, the stored seed
+// unless another was asked for. A replay stops at the first action that fails,
+// which leads the divergences as actions[index], counting from 0.
+type ReplayReport struct {
+	VillageID    uint             `json:"villageId"`
+	Seed         int64            `json:"seed"`
+	Actions      int              `json:"actions"`
+	SavedTick    uint64           `json:"savedTick"`
+	ReplayedTick uint64           `json:"replayedTick"`
+	SavedHash    string           `json:"savedHash"`
+	ReplayedHash string           `json:"replayedHash"`
+	Diverged     bool             `json:"diverged"`
+	Divergences  []sim.Divergence `json:"divergences"`
+}
+
+type villageService struct {
+	db *gorm.DB
+}
+
+func NewVillageService(db *gorm.DB) VillageService {
+	return &villageService{db: db}
+}
+
+func (s *villageService) ListVillages(ctx context.Context) ([]models.Village, error) {
+	var villages []models.Village
+	if err := s.db.WithContext(ctx).Find(&villages).Error; err != nil {
+		return nil, err
+	}
+
+	return villages, nil
+}
+
+func (s *villageService) GetVillageByID(ctx context.Context, id uint) (models.Village, error) {
+	var village models.Village
+	if err := s.db.WithContext(ctx).First(&village, id).Error; err != nil {
+		return models.Village{}, err
+	}
+	return village, nil
+}
+
+// CreateVillage starts a fresh simulation. Without an explicit seed a random one
+// is picked; either way it is stored so the run can be reproduced later.
+func (s *villageService) CreateVillage(ctx context.Context, village models.Village, seed *int64) (models.Village, error) {
+	if seed != nil {
+		village.Seed = *seed
+	} else {
+		village.Seed = rand.Int64()
+	}
+	village.Tick = 0
+	village.StateHash = sim.Hash(sim.State{})
+
+	if err := s.db.WithContext(ctx).Create(&village).Error; err != nil {
+		return models.Village{}, err
+	}
+	return village, nil
+}
+
+// DeleteVillage removes the village and its logs. Its buildings are kept but
+// detached, since they can still be edited on their own, and so are the roads
+// between them so they stay in the same village as their ends. The logs are
+// deleted here rather than left to the cascade, which SQLite doesn't enforce.
+func (s *villageService) DeleteVillage(ctx context.Context, id uint) error {
+	return s.db.WithContext(ctx).Transaction(func(transaction *gorm.DB) error {
+		for _, model := range []any{&models.Building{}, &models.RoadSegment{}} {
+			if err := transaction.
+				Model(model).
+				Where("village_id = ?", id).
+				Update("village_id", nil).Error; err != nil {
+				return err
+			}
+		}
+		for _, model := range []any{
+			&models.InventoryEntry{},
+			&models.VillageAction{},
+			&models.VillageEvent{},
+		} {
+			if err := transaction.
+				Where("village_id = ?", id).
+				Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		result := transaction.Delete(&models.Village{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+// RecordAction appends an action to the village log and applies it to the saved
+// state in the same transaction, so the log and the state never drift apart
+// through this path. Reservations are refused: only construction records them,
+// for the building it starts.
+func (s *villageService) RecordAction(ctx context.Context, id uint, action models.VillageAction) (models.Village, error) {
+	if action.Kind == sim.ActionReserve {
+		return models.Village{}, fmt.Errorf("%w: %s actions are only recorded by construction", sim.ErrInvalidAction, action.Kind)
+	}
+	return repository.NewGormStore(s.db.WithContext(ctx)).Villages().Record(ctx, id, action)
+}
+
+// ReplayVillage re-runs the simulation from the recorded action log and reports
+// where the result differs from what is saved. It replays from the stored seed,
+// or from seed when one is given, to see how another seed would have played out.
+func (s *villageService) ReplayVillage(ctx context.Context, id uint, seed *int64) (ReplayReport, error) {
+	var village models.Village
+	if err := s.db.WithContext(ctx).First(&village, id).Error; err != nil {
+		return ReplayReport{}, err
+	}
+	saved, err := repository.NewGormStore(s.db.WithContext(ctx)).Villages().State(ctx, id)
+	if err != nil {
+		return ReplayReport{}, err
+	}
+
+	var actions []models.VillageAction
+	if err := s.db.WithContext(ctx).
+		Where("village_id = ?", id).
+		Order("seq").
+		Find(&actions).Error; err != nil {
+		return ReplayReport{}, err
+	}
+	simActions := make([]sim.Action, 0, len(actions))
+	for _, action := range actions {
+		simActions = append(simActions, sim.Action{Kind: action.Kind, Payload: action.Payload})
+	}
+
+	replaySeed := village.Seed
+	if seed != nil {
+		replaySeed = *seed
+	}
+	// an action that fails on replay, such as a reservation another seed can't
+	// cover, is a divergence like any other and is reported with the rest
+	replayed, err := sim.Replay(replaySeed, simActions)
+	var failed *sim.ReplayError
+	if err != nil && !errors.As(err, &failed) {
+		return ReplayReport{}, err
+	}
+
+	report := ReplayReport{
+		VillageID:    village.ID,
+		Seed:         replaySeed,
+		Actions:      len(actions),
+		SavedTick:    village.Tick,
+		ReplayedTick: replayed.Tick,
+		SavedHash:    sim.Hash(saved),
+		ReplayedHash: sim.Hash(replayed),
+		Divergences:  sim.Diff(saved, replayed),
+	}
+	if failed != nil {
+		report.Divergences = append([]sim.Divergence{{
+			Field:    fmt.Sprintf("actions[%d]", failed.Index),
+			Saved:    failed.Kind,
+			Replayed: fmt.Sprintf("%s failed: %v", failed.Kind, failed.Err),
+		}}, report.Divergences...)
+	}
+	// the stored hash is checked too, so tampering with the tick alone still shows up
+	report.Diverged = failed != nil || report.SavedHash != report.ReplayedHash || village.StateHash != report.ReplayedHash
+	if report.Divergences == nil {
+		report.Divergences = []sim.Divergence{}
+	}
+	return report, nil
+}
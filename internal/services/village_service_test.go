@@ -0,0 +1,161 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Stckrz/villageApi/internal/catalog"
+	"github.com/Stckrz/villageApi/internal/db/models"
+	"github.com/Stckrz/villageApi/internal/services/repository"
+	"github.com/Stckrz/villageApi/internal/sim"
+)
+
+func TestReplayVillageMatchesStateHash(t *testing.T) {
+	ctx := context.Background()
+	service := NewVillageService(newTestDB(t))
+	seed := int64(99)
+	village, err := service.CreateVillage(ctx, models.Village{Name: "Village"}, &seed)
+	if err != nil {
+		t.Fatalf("create village: %v", err)
+	}
+
+	for _, action := range []models.VillageAction{
+		{Kind: sim.ActionAdjust, Payload: `{"resource":"food","delta":30}`},
+		{Kind: sim.ActionAdvance, Payload: `{"ticks":250}`},
+		{Kind: sim.ActionAdjust, Payload: `{"resource":"food","delta":-5,"reason":"feast"}`},
+		{Kind: sim.ActionAdvance, Payload: `{"ticks":250}`},
+	} {
+		if village, err = service.RecordAction(ctx, village.ID, action); err != nil {
+			t.Fatalf("record %s: %v", action.Kind, err)
+		}
+	}
+
+	report, err := service.ReplayVillage(ctx, village.ID, nil)
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if report.Diverged || report.ReplayedHash != village.StateHash {
+		t.Fatalf("replayed hash %s, saved %s: %+v", report.ReplayedHash, village.StateHash, report.Divergences)
+	}
+	if report.Seed != seed || report.Actions != 4 || report.ReplayedTick != 500 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	other := seed + 1
+	report, err = service.ReplayVillage(ctx, village.ID, &other)
+	if err != nil {
+		t.Fatalf("replay with another seed: %v", err)
+	}
+	if !report.Diverged || report.Seed != other {
+		t.Fatalf("another seed didn't diverge: %+v", report)
+	}
+}
+
+func TestDeleteVillageRemovesLogs(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	service := NewVillageService(db)
+	village, err := service.CreateVillage(ctx, models.Village{Name: "Village"}, nil)
+	if err != nil {
+		t.Fatalf("create village: %v", err)
+	}
+	if _, err := service.RecordAction(ctx, village.ID, models.VillageAction{
+		Kind:    sim.ActionAdvance,
+		Payload: `{"ticks":50}`,
+	}); err != nil {
+		t.Fatalf("record advance: %v", err)
+	}
+
+	if err := service.DeleteVillage(ctx, village.ID); err != nil {
+		t.Fatalf("delete village: %v", err)
+	}
+
+	for _, model := range []any{&models.VillageAction{}, &models.VillageEvent{}} {
+		var count int64
+		if err := db.Model(model).Where("village_id = ?", village.ID).Count(&count).Error; err != nil {
+			t.Fatalf("count %T: %v", model, err)
+		}
+		if count != 0 {
+			t.Fatalf("%d %T rows left after delete", count, model)
+		}
+	}
+}
+
+func TestReplayReportsAReserveAnotherSeedStarves(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t)
+	store := repository.NewGormStore(database)
+	service := NewVillageService(database)
+	seed := int64(7)
+	village, err := service.CreateVillage(ctx, models.Village{Name: "Village"}, &seed)
+	if err != nil {
+		t.Fatalf("create village: %v", err)
+	}
+
+	// the mill's bonus rolls decide how much wood there is after the advance
+	untyped := NewBuildingService(store, catalog.Default())
+	if _, err := untyped.CreateBuilding(ctx, models.Building{
+		Name:      "Mill",
+		VillageID: &village.ID,
+		Rates:     []models.BuildingRate{{Resource: models.ResourceWood, PerTick: 1}},
+	}); err != nil {
+		t.Fatalf("create mill: %v", err)
+	}
+	if _, err := service.RecordAction(ctx, village.ID, models.VillageAction{Kind: sim.ActionAdvance, Payload: `{"ticks":100}`}); err != nil {
+		t.Fatalf("advance: %v", err)
+	}
+	state, err := store.Villages().State(ctx, village.ID)
+	if err != nil {
+		t.Fatalf("state: %v", err)
+	}
+
+	// a hut costing every bit of that wood reserves it all
+	buildingTypes, err := catalog.New([]catalog.BuildingType{{
+		ID:     "hut",
+		Name:   "Hut",
+		Levels: []catalog.Level{{Level: 1, Cost: map[string]int64{models.ResourceWood: state.Stock[models.ResourceWood]}}},
+	}})
+	if err != nil {
+		t.Fatalf("catalog: %v", err)
+	}
+	if _, err := NewBuildingService(store, buildingTypes).CreateBuilding(ctx, models.Building{Type: "hut", VillageID: &village.ID}); err != nil {
+		t.Fatalf("create hut: %v", err)
+	}
+
+	// find a seed whose rolls leave less wood than the hut took
+	var actions []sim.Action
+	var logged []models.VillageAction
+	if err := database.Where("village_id = ?", village.ID).Order("seq").Find(&logged).Error; err != nil {
+		t.Fatalf("actions: %v", err)
+	}
+	for _, action := range logged {
+		actions = append(actions, sim.Action{Kind: action.Kind, Payload: action.Payload})
+	}
+	other := seed
+	for {
+		other++
+		if other > seed+100 {
+			t.Fatal("no seed starves the reserve")
+		}
+		if _, err := sim.Replay(other, actions); errors.Is(err, sim.ErrInsufficientStock) {
+			break
+		}
+	}
+
+	report, err := service.ReplayVillage(ctx, village.ID, &other)
+	if err != nil {
+		t.Fatalf("replay with a starving seed: %v", err)
+	}
+	if !report.Diverged || len(report.Divergences) == 0 {
+		t.Fatalf("replay didn't diverge: %+v", report)
+	}
+	failed := report.Divergences[0]
+	if failed.Field != "actions[1]" || failed.Saved != sim.ActionReserve || !strings.Contains(failed.Replayed, sim.ErrInsufficientStock.Error()) {
+		t.Fatalf("first divergence %+v, want the failed reserve", failed)
+	}
+	if report.ReplayedTick != 100 {
+		t.Fatalf("replayed tick %d, want the 100 reached before the reserve", report.ReplayedTick)
+	}
+}
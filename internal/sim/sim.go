@@ -0,0 +1,393 @@
+// Package sim is the deterministic village simulation. Every roll is drawn from
+// a generator derived from the village seed and the tick being simulated, so
+// replaying the same action log from the same seed always lands on the same
+// state.
+package sim
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+
+	"github.com/Stckrz/villageApi/internal/db/models"
+)
+
+const (
+	ActionAdvance = "advance"
+	ActionAdjust  = "adjust"
+	ActionReserve = "reserve"
+
+	// MaxAdvanceTicks caps a single advance action so one request can't pin the server.
+	MaxAdvanceTicks = 10000
+
+	// chance that a producing building yields double on a tick
+	productionBonusChance = 0.1
+)
+
+var (
+	ErrInvalidAction     = errors.New("invalid action")
+	ErrInsufficientStock = errors.New("insufficient stock")
+)
+
+type Event struct {
+	Tick uint64 `json:"tick"`
+	Kind string `json:"kind"`
+}
+
+type State struct {
+	Tick   uint64           `json:"tick"`
+	Stock  map[string]int64 `json:"stock,omitempty"`
+	Events []Event          `json:"events"`
+}
+
+type Action struct {
+	Kind    string
+	Payload string
+}
+
+// Producer is a building's per-tick rates as they were when an advance was
+// recorded. Keeping them in the action payload makes the log self-contained, so
+// later edits to buildings don't change what a replay computes.
+type Producer struct {
+	BuildingID uint             `json:"buildingId"`
+	Rates      map[string]int64 `json:"rates"`
+}
+
+type AdvancePayload struct {
+	Ticks     uint64     `json:"ticks"`
+	Producers []Producer `json:"producers,omitempty"`
+}
+
+type AdjustPayload struct {
+	Resource string `json:"resource"`
+	Delta    int64  `json:"delta"`
+	Reason   string `json:"reason"`
+}
+
+// ReservePayload takes a construction cost out of stock for a building. Either
+// every resource is covered and all of it is taken, or nothing is.
+type ReservePayload struct {
+	BuildingID uint             `json:"buildingId"`
+	Cost       map[string]int64 `json:"cost"`
+}
+
+// Change is a single movement of stock, one ledger entry's worth.
+type Change struct {
+	Tick       uint64
+	Resource   string
+	Delta      int64
+	BuildingID uint
+	Reason     string
+}
+
+type Divergence struct {
+	Field    string `json:"field"`
+	Saved    string `json:"saved"`
+	Replayed string `json:"replayed"`
+}
+
+// chance of each random event firing on any given tick
+var eventTable = []struct {
+	kind   string
+	chance float64
+}{
+	{"bountiful_harvest", 0.05},
+	{"storm", 0.03},
+	{"traveling_merchant", 0.02},
+}
+
+// Rand returns the generator for one tick. Seeding per tick rather than per run
+// means a tick's rolls never depend on how many rolls earlier ticks consumed.
+func Rand(seed int64, tick uint64) *rand.Rand {
+	return rand.New(rand.NewPCG(uint64(seed), tick))
+}
+
+// Step advances state by a single tick: producers run in building order, then
+// the tick's events are rolled.
+func Step(seed int64, state *State, producers []Producer) []Change {
+	state.Tick++
+	rng := Rand(seed, state.Tick)
+	var changes []Change
+
+	for _, producer := range producers {
+		changes = append(changes, produce(state, rng, producer)...)
+	}
+
+	for _, entry := range eventTable {
+		if rng.Float64() < entry.chance {
+			state.Events = append(state.Events, Event{Tick: state.Tick, Kind: entry.kind})
+			changes = append(changes, applyEvent(state, entry.kind)...)
+		}
+	}
+	return changes
+}
+
+// produce runs one building for one tick. A building that can't cover all of its
+// consumption stalls for the tick instead of driving stock negative.
+func produce(state *State, rng *rand.Rand, producer Producer) []Change {
+	resources := sortedKeys(producer.Rates)
+	for _, resource := range resources {
+		if rate := producer.Rates[resource]; rate < 0 && state.Stock[resource] < -rate {
+			return nil
+		}
+	}
+
+	multiplier := int64(1)
+	if rng.Float64() < productionBonusChance {
+		multiplier = 2
+	}
+
+	var changes []Change
+	for _, resource := range resources {
+		delta := producer.Rates[resource]
+		reason := "consumption"
+		if delta > 0 {
+			delta *= multiplier
+			reason = "production"
+		}
+		if delta == 0 {
+			continue
+		}
+		changes = append(changes, move(state, resource, delta, producer.BuildingID, reason))
+	}
+	return changes
+}
+
+func applyEvent(state *State, kind string) []Change {
+	switch kind {
+	case "bountiful_harvest":
+		return []Change{move(state, models.ResourceFood, 20, 0, kind)}
+	case "storm":
+		if lost := min(state.Stock[models.ResourceWood], 10); lost > 0 {
+			return []Change{move(state, models.ResourceWood, -lost, 0, kind)}
+		}
+	case "traveling_merchant":
+		if state.Stock[models.ResourceFood] >= 10 {
+			return []Change{
+				move(state, models.ResourceFood, -10, 0, kind),
+				move(state, models.ResourceGold, 2, 0, kind),
+			}
+		}
+	}
+	return nil
+}
+
+func move(state *State, resource string, delta int64, buildingID uint, reason string) Change {
+	if state.Stock == nil {
+		state.Stock = map[string]int64{}
+	}
+	state.Stock[resource] += delta
+	return Change{
+		Tick:       state.Tick,
+		Resource:   resource,
+		Delta:      delta,
+		BuildingID: buildingID,
+		Reason:     reason,
+	}
+}
+
+// Validate checks an action without applying it.
+func Validate(action Action) error {
+	switch action.Kind {
+	case ActionAdvance:
+		var payload AdvancePayload
+		if err := json.Unmarshal([]byte(action.Payload), &payload); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidAction, err)
+		}
+		if payload.Ticks == 0 || payload.Ticks > MaxAdvanceTicks {
+			return fmt.Errorf("%w: ticks must be between 1 and %d", ErrInvalidAction, MaxAdvanceTicks)
+		}
+		for _, producer := range payload.Producers {
+			for resource := range producer.Rates {
+				if !models.IsResource(resource) {
+					return fmt.Errorf("%w: unknown resource %q", ErrInvalidAction, resource)
+				}
+			}
+		}
+		return nil
+	case ActionAdjust:
+		var payload AdjustPayload
+		if err := json.Unmarshal([]byte(action.Payload), &payload); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidAction, err)
+		}
+		if !models.IsResource(payload.Resource) {
+			return fmt.Errorf("%w: unknown resource %q", ErrInvalidAction, payload.Resource)
+		}
+		if payload.Delta == 0 {
+			return fmt.Errorf("%w: delta must not be zero", ErrInvalidAction)
+		}
+		return nil
+	case ActionReserve:
+		var payload ReservePayload
+		if err := json.Unmarshal([]byte(action.Payload), &payload); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidAction, err)
+		}
+		for resource, amount := range payload.Cost {
+			if !models.IsResource(resource) {
+				return fmt.Errorf("%w: unknown resource %q", ErrInvalidAction, resource)
+			}
+			if amount < 0 {
+				return fmt.Errorf("%w: cost of %s must not be negative", ErrInvalidAction, resource)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: unknown kind %q", ErrInvalidAction, action.Kind)
+	}
+}
+
+// Apply runs a single recorded action against state and returns the stock
+// changes it caused, in order.
+func Apply(seed int64, state *State, action Action) ([]Change, error) {
+	if err := Validate(action); err != nil {
+		return nil, err
+	}
+
+	var changes []Change
+	switch action.Kind {
+	case ActionAdvance:
+		var payload AdvancePayload
+		_ = json.Unmarshal([]byte(action.Payload), &payload)
+		sort.Slice(payload.Producers, func(i, j int) bool {
+			return payload.Producers[i].BuildingID < payload.Producers[j].BuildingID
+		})
+		for range payload.Ticks {
+			changes = append(changes, Step(seed, state, payload.Producers)...)
+		}
+	case ActionAdjust:
+		var payload AdjustPayload
+		_ = json.Unmarshal([]byte(action.Payload), &payload)
+		if state.Stock[payload.Resource]+payload.Delta < 0 {
+			return nil, fmt.Errorf("%w: %s", ErrInsufficientStock, payload.Resource)
+		}
+		reason := payload.Reason
+		if reason == "" {
+			reason = ActionAdjust
+		}
+		changes = append(changes, move(state, payload.Resource, payload.Delta, 0, reason))
+	case ActionReserve:
+		var payload ReservePayload
+		_ = json.Unmarshal([]byte(action.Payload), &payload)
+		resources := sortedKeys(payload.Cost)
+		for _, resource := range resources {
+			if state.Stock[resource] < payload.Cost[resource] {
+				return nil, fmt.Errorf("%w: %s", ErrInsufficientStock, resource)
+			}
+		}
+		for _, resource := range resources {
+			if payload.Cost[resource] > 0 {
+				changes = append(changes, move(state, resource, -payload.Cost[resource], payload.BuildingID, "construction"))
+			}
+		}
+	}
+	return changes, nil
+}
+
+// ReplayError is an action of the log that failed to apply during a replay.
+// Index counts from 0.
+type ReplayError struct {
+	Index int
+	Kind  string
+	Err   error
+}
+
+func (e *ReplayError) Error() string {
+	return fmt.Sprintf("action %d (%s): %v", e.Index+1, e.Kind, e.Err)
+}
+
+func (e *ReplayError) Unwrap() error {
+	return e.Err
+}
+
+// Replay rebuilds a state from scratch out of the seed and the action log. If an
+// action fails, the state reached before it is returned with a *ReplayError.
+func Replay(seed int64, actions []Action) (State, error) {
+	var state State
+	for index, action := range actions {
+		if _, err := Apply(seed, &state, action); err != nil {
+			return state, &ReplayError{Index: index, Kind: action.Kind, Err: err}
+		}
+	}
+	return state, nil
+}
+
+// Hash is a stable digest of the state, used to detect divergence cheaply.
+func Hash(state State) string {
+	if state.Events == nil {
+		state.Events = []Event{}
+	}
+	stock := map[string]int64{}
+	for resource, amount := range state.Stock {
+		if amount != 0 {
+			stock[resource] = amount
+		}
+	}
+	state.Stock = stock
+	encoded, _ := json.Marshal(state)
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:])
+}
+
+// Diff reports where a saved state and a replayed state part ways.
+func Diff(saved, replayed State) []Divergence {
+	var divergences []Divergence
+	if saved.Tick != replayed.Tick {
+		divergences = append(divergences, Divergence{
+			Field:    "tick",
+			Saved:    fmt.Sprint(saved.Tick),
+			Replayed: fmt.Sprint(replayed.Tick),
+		})
+	}
+
+	for _, resource := range models.Resources {
+		if saved.Stock[resource] != replayed.Stock[resource] {
+			divergences = append(divergences, Divergence{
+				Field:    "stock." + resource,
+				Saved:    fmt.Sprint(saved.Stock[resource]),
+				Replayed: fmt.Sprint(replayed.Stock[resource]),
+			})
+		}
+	}
+
+	// only the first differing event is reported; everything after it usually
+	// shifts along with it and would just be noise
+	for index := 0; index < max(len(saved.Events), len(replayed.Events)); index++ {
+		var savedEvent, replayedEvent string
+		if index < len(saved.Events) {
+			savedEvent = fmt.Sprintf("%s@%d", saved.Events[index].Kind, saved.Events[index].Tick)
+		}
+		if index < len(replayed.Events) {
+			replayedEvent = fmt.Sprintf("%s@%d", replayed.Events[index].Kind, replayed.Events[index].Tick)
+		}
+		if savedEvent != replayedEvent {
+			divergences = append(divergences, Divergence{
+				Field:    fmt.Sprintf("events[%d]", index),
+				Saved:    savedEvent,
+				Replayed: replayedEvent,
+			})
+			break
+		}
+	}
+	if len(saved.Events) != len(replayed.Events) {
+		divergences = append(divergences, Divergence{
+			Field:    "events.length",
+			Saved:    fmt.Sprint(len(saved.Events)),
+			Replayed: fmt.Sprint(len(replayed.Events)),
+		})
+	}
+
+	return divergences
+}
+
+func sortedKeys(rates map[string]int64) []string {
+	keys := make([]string, 0, len(rates))
+	for key := range rates {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
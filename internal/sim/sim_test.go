@@ -0,0 +1,117 @@
+package sim
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Stckrz/villageApi/internal/db/models"
+)
+
+func action(t *testing.T, kind string, payload any) Action {
+	t.Helper()
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatalf("encode %s payload: %v", kind, err)
+	}
+	return Action{Kind: kind, Payload: string(encoded)}
+}
+
+// actionLog is a village's life: some stock, a farm and a sawmill running for a
+// while, a construction reservation and some more ticks.
+func actionLog(t *testing.T) []Action {
+	producers := []Producer{
+		{BuildingID: 2, Rates: map[string]int64{models.ResourceWood: 3, models.ResourceFood: -1}},
+		{BuildingID: 1, Rates: map[string]int64{models.ResourceFood: 4}},
+	}
+	return []Action{
+		action(t, ActionAdjust, AdjustPayload{Resource: models.ResourceFood, Delta: 50}),
+		action(t, ActionAdvance, AdvancePayload{Ticks: 200, Producers: producers}),
+		action(t, ActionReserve, ReservePayload{BuildingID: 3, Cost: map[string]int64{models.ResourceWood: 40}}),
+		action(t, ActionAdjust, AdjustPayload{Resource: models.ResourceStone, Delta: 5, Reason: "gift"}),
+		action(t, ActionAdvance, AdvancePayload{Ticks: 300, Producers: producers}),
+	}
+}
+
+// record applies the log one action at a time, the way villages record it, and
+// returns the saved state and its hash.
+func record(t *testing.T, seed int64, actions []Action) (State, string) {
+	t.Helper()
+	var state State
+	for index, action := range actions {
+		if _, err := Apply(seed, &state, action); err != nil {
+			t.Fatalf("record action %d: %v", index+1, err)
+		}
+	}
+	return state, Hash(state)
+}
+
+func TestReplayMatchesRecordedState(t *testing.T) {
+	const seed = 424242
+	actions := actionLog(t)
+	saved, savedHash := record(t, seed, actions)
+	if len(saved.Events) == 0 {
+		t.Fatalf("no events rolled in %d ticks; the log doesn't exercise them", saved.Tick)
+	}
+
+	replayed, err := Replay(seed, actions)
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if hash := Hash(replayed); hash != savedHash {
+		t.Fatalf("replayed hash %s, saved %s", hash, savedHash)
+	}
+	if divergences := Diff(saved, replayed); len(divergences) != 0 {
+		t.Fatalf("divergences: %+v", divergences)
+	}
+}
+
+func TestReplayWithAnotherSeedDiverges(t *testing.T) {
+	actions := actionLog(t)
+	saved, savedHash := record(t, 1, actions)
+
+	replayed, err := Replay(2, actions)
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if Hash(replayed) == savedHash {
+		t.Fatal("another seed replayed to the same hash")
+	}
+	if len(Diff(saved, replayed)) == 0 {
+		t.Fatal("another seed replayed without divergences")
+	}
+}
+
+func TestReplayIgnoresProducerOrder(t *testing.T) {
+	actions := actionLog(t)
+	reversed := make([]Action, len(actions))
+	copy(reversed, actions)
+	var advance AdvancePayload
+	if err := json.Unmarshal([]byte(actions[1].Payload), &advance); err != nil {
+		t.Fatalf("decode advance: %v", err)
+	}
+	advance.Producers[0], advance.Producers[1] = advance.Producers[1], advance.Producers[0]
+	reversed[1] = action(t, ActionAdvance, advance)
+
+	first, err := Replay(7, actions)
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	second, err := Replay(7, reversed)
+	if err != nil {
+		t.Fatalf("replay reordered: %v", err)
+	}
+	if Hash(first) != Hash(second) {
+		t.Fatal("the order producers were recorded in changed the result")
+	}
+}
+
+func TestApplyRefusesNegativeStock(t *testing.T) {
+	var state State
+	_, err := Apply(1, &state, action(t, ActionReserve, ReservePayload{BuildingID: 1, Cost: map[string]int64{models.ResourceGold: 1}}))
+	if err == nil {
+		t.Fatal("reserved more gold than there is")
+	}
+	if state.Stock[models.ResourceGold] != 0 {
+		t.Fatalf("gold is %d after a refused reservation", state.Stock[models.ResourceGold])
+	}
+}
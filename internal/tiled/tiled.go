@@ -0,0 +1,231 @@
+// Package tiled reads the object layers of maps authored in the Tiled editor,
+// in either its JSON (.json/.tmj) or XML (.tmx) format. Only what the importer
+// needs is kept: the tile size and every object with its position, size,
+// rotation and custom properties. Tile layers, tilesets and templates are
+// ignored.
+package tiled
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var ErrInvalidMap = errors.New("invalid tiled map")
+
+// Object is one object of an object layer. Positions and sizes are in pixels,
+// rotation in degrees clockwise, as Tiled stores them.
+type Object struct {
+	ID         int
+	Name       string
+	Class      string
+	Layer      string
+	X          float64
+	Y          float64
+	Width      float64
+	Height     float64
+	Rotation   float64
+	Properties map[string]string
+}
+
+type Map struct {
+	TileWidth  int
+	TileHeight int
+	Objects    []Object
+}
+
+// Load reads a map file. .tmx is parsed as XML; .json and .tmj as JSON.
+func Load(path string) (Map, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return Map{}, err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".tmx":
+		return ParseTMX(contents)
+	case ".json", ".tmj":
+		return ParseJSON(contents)
+	default:
+		return Map{}, fmt.Errorf("%w: unsupported file type %q", ErrInvalidMap, filepath.Ext(path))
+	}
+}
+
+// Parse tells the two formats apart by their first character.
+func Parse(contents []byte) (Map, error) {
+	if trimmed := bytes.TrimSpace(contents); len(trimmed) > 0 && trimmed[0] == '<' {
+		return ParseTMX(contents)
+	}
+	return ParseJSON(contents)
+}
+
+type jsonProperty struct {
+	Name  string `json:"name"`
+	Value any    `json:"value"`
+}
+
+type jsonObject struct {
+	ID         int            `json:"id"`
+	Name       string         `json:"name"`
+	Type       string         `json:"type"`
+	Class      string         `json:"class"`
+	GID        uint32         `json:"gid"`
+	X          float64        `json:"x"`
+	Y          float64        `json:"y"`
+	Width      float64        `json:"width"`
+	Height     float64        `json:"height"`
+	Rotation   float64        `json:"rotation"`
+	Properties []jsonProperty `json:"properties"`
+}
+
+type jsonLayer struct {
+	Name    string       `json:"name"`
+	Type    string       `json:"type"`
+	Objects []jsonObject `json:"objects"`
+	Layers  []jsonLayer  `json:"layers"`
+}
+
+type jsonMap struct {
+	TileWidth  int         `json:"tilewidth"`
+	TileHeight int         `json:"tileheight"`
+	Layers     []jsonLayer `json:"layers"`
+}
+
+func ParseJSON(contents []byte) (Map, error) {
+	var file jsonMap
+	if err := json.Unmarshal(contents, &file); err != nil {
+		return Map{}, fmt.Errorf("%w: %v", ErrInvalidMap, err)
+	}
+
+	tiledMap := Map{TileWidth: file.TileWidth, TileHeight: file.TileHeight}
+	var walk func(layers []jsonLayer)
+	walk = func(layers []jsonLayer) {
+		for _, layer := range layers {
+			switch layer.Type {
+			case "objectgroup":
+				for _, object := range layer.Objects {
+					properties := make(map[string]string, len(object.Properties))
+					for _, property := range object.Properties {
+						properties[property.Name] = fmt.Sprint(property.Value)
+					}
+					tiledMap.Objects = append(tiledMap.Objects, newObject(
+						object.ID, object.Name, firstNonEmpty(object.Class, object.Type), layer.Name, object.GID,
+						object.X, object.Y, object.Width, object.Height, object.Rotation, properties,
+					))
+				}
+			case "group":
+				walk(layer.Layers)
+			}
+		}
+	}
+	walk(file.Layers)
+	return tiledMap, tiledMap.validate()
+}
+
+type tmxProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+	// multi-line string properties keep their value as the element text
+	Text string `xml:",chardata"`
+}
+
+type tmxObject struct {
+	ID         int           `xml:"id,attr"`
+	Name       string        `xml:"name,attr"`
+	Type       string        `xml:"type,attr"`
+	Class      string        `xml:"class,attr"`
+	GID        uint32        `xml:"gid,attr"`
+	X          float64       `xml:"x,attr"`
+	Y          float64       `xml:"y,attr"`
+	Width      float64       `xml:"width,attr"`
+	Height     float64       `xml:"height,attr"`
+	Rotation   float64       `xml:"rotation,attr"`
+	Properties []tmxProperty `xml:"properties>property"`
+}
+
+type tmxObjectGroup struct {
+	Name    string      `xml:"name,attr"`
+	Objects []tmxObject `xml:"object"`
+}
+
+type tmxGroup struct {
+	ObjectGroups []tmxObjectGroup `xml:"objectgroup"`
+	Groups       []tmxGroup       `xml:"group"`
+}
+
+type tmxMap struct {
+	XMLName    xml.Name `xml:"map"`
+	TileWidth  int      `xml:"tilewidth,attr"`
+	TileHeight int      `xml:"tileheight,attr"`
+	tmxGroup
+}
+
+func ParseTMX(contents []byte) (Map, error) {
+	var file tmxMap
+	if err := xml.Unmarshal(contents, &file); err != nil {
+		return Map{}, fmt.Errorf("%w: %v", ErrInvalidMap, err)
+	}
+
+	tiledMap := Map{TileWidth: file.TileWidth, TileHeight: file.TileHeight}
+	var walk func(group tmxGroup)
+	walk = func(group tmxGroup) {
+		for _, layer := range group.ObjectGroups {
+			for _, object := range layer.Objects {
+				properties := make(map[string]string, len(object.Properties))
+				for _, property := range object.Properties {
+					properties[property.Name] = firstNonEmpty(property.Value, property.Text)
+				}
+				tiledMap.Objects = append(tiledMap.Objects, newObject(
+					object.ID, object.Name, firstNonEmpty(object.Class, object.Type), layer.Name, object.GID,
+					object.X, object.Y, object.Width, object.Height, object.Rotation, properties,
+				))
+			}
+		}
+		for _, nested := range group.Groups {
+			walk(nested)
+		}
+	}
+	walk(file.tmxGroup)
+	return tiledMap, tiledMap.validate()
+}
+
+// newObject normalises an object across both formats. Tile objects (those with
+// a gid) are anchored at their bottom-left corner in Tiled; they are moved to
+// the top-left like every other object.
+func newObject(id int, name string, class string, layer string, gid uint32, x, y, width, height, rotation float64, properties map[string]string) Object {
+	if gid != 0 {
+		y -= height
+	}
+	return Object{
+		ID:         id,
+		Name:       strings.TrimSpace(name),
+		Class:      strings.TrimSpace(class),
+		Layer:      layer,
+		X:          x,
+		Y:          y,
+		Width:      width,
+		Height:     height,
+		Rotation:   rotation,
+		Properties: properties,
+	}
+}
+
+func (m Map) validate() error {
+	if m.TileWidth <= 0 || m.TileHeight <= 0 {
+		return fmt.Errorf("%w: tile width and height must be positive", ErrInvalidMap)
+	}
+	return nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
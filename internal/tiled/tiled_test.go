@@ -0,0 +1,89 @@
+package tiled
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	// both fixtures describe the same map: a tile layer that is skipped, a
+	// top-level object layer and a tile object nested in a group
+	village := Map{
+		TileWidth:  16,
+		TileHeight: 16,
+		Objects: []Object{
+			{
+				ID: 1, Name: "Town Hall", Class: "civic", Layer: "Buildings",
+				X: 32, Y: 48, Width: 64, Height: 48,
+				Properties: map[string]string{
+					"description": "Where the council meets",
+					"floors":      "2",
+					"listed":      "true",
+				},
+			},
+			{
+				ID: 3, Layer: "Buildings",
+				Width: 16, Height: 16,
+				Properties: map[string]string{},
+			},
+			{
+				// the tile object is moved from its bottom-left corner to its top-left
+				ID: 2, Name: "Farm", Class: "farm", Layer: "Farms",
+				X: 160, Y: 64, Width: 32, Height: 32, Rotation: 90,
+				Properties: map[string]string{},
+			},
+		},
+	}
+
+	for _, test := range []struct {
+		name    string
+		parse   func([]byte) (Map, error)
+		file    string
+		want    Map
+		wantErr error
+	}{
+		{name: "json", parse: ParseJSON, file: "testdata/village.tmj", want: village},
+		{name: "tmx", parse: ParseTMX, file: "testdata/village.tmx", want: village},
+		{name: "sniffed json", parse: Parse, file: "testdata/village.tmj", want: village},
+		{name: "sniffed tmx", parse: Parse, file: "testdata/village.tmx", want: village},
+		{name: "json without tile size", parse: ParseJSON, file: "testdata/no-tile-size.tmj", wantErr: ErrInvalidMap},
+		{name: "malformed tmx", parse: ParseTMX, file: "testdata/village.tmj", wantErr: ErrInvalidMap},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			contents, err := os.ReadFile(test.file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := test.parse(contents)
+			if test.wantErr != nil {
+				if !errors.Is(err, test.wantErr) {
+					t.Fatalf("error %v, want %v", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("parsed\n%+v\nwant\n%+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestLoadPicksFormatByExtension(t *testing.T) {
+	for _, file := range []string{"testdata/village.tmj", "testdata/village.tmx"} {
+		tiledMap, err := Load(file)
+		if err != nil {
+			t.Fatalf("load %s: %v", file, err)
+		}
+		if len(tiledMap.Objects) != 3 {
+			t.Fatalf("load %s: %d objects", file, len(tiledMap.Objects))
+		}
+	}
+	if _, err := Load("tiled_test.go"); !errors.Is(err, ErrInvalidMap) {
+		t.Fatalf("load a file that isn't a map: %v", err)
+	}
+}